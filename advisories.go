@@ -0,0 +1,210 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strings"
+)
+
+// securityDB is the security database (secdb) of a repository, in the Alpine format also used by Wolfi. secfixes maps
+// each package version to the vulnerabilities fixed in it, version 0 lists vulnerabilities which never affected the
+// package.
+type securityDB struct {
+	Packages []struct {
+		Pkg struct {
+			Name     string              `json:"name"`
+			Secfixes map[string][]string `json:"secfixes"`
+		} `json:"pkg"`
+	} `json:"packages"`
+}
+
+// AdvisoryFinding is a vulnerability affecting an installed package version, fixed in a later version
+type AdvisoryFinding struct {
+	Name             string
+	InstalledVersion string
+	Vulnerability    string
+	FixedVersion     string
+	// Source is the repository whose security database lists the fix
+	Source string
+	// Line is the line the installed package was read from, used to locate the finding
+	Line int `json:"-"`
+}
+
+// fetchSecurityDB reads the security database of the repository, downloading it when it is not a local file
+func fetchSecurityDB(apkRepository Repository, authToken string) (*securityDB, error) {
+	var content []byte
+	if _, err := os.Stat(apkRepository.SecurityDB); err == nil {
+		if content, err = os.ReadFile(apkRepository.SecurityDB); err != nil {
+			return nil, err
+		}
+	} else {
+		resp, err := httpGet(apkRepository.SecurityDB, apkRepository, authToken)
+		if err != nil {
+			return nil, err
+		}
+		defer resp.Body.Close()
+		if content, err = io.ReadAll(resp.Body); err != nil {
+			return nil, fmt.Errorf("failed to download %s: %w", apkRepository.SecurityDB, err)
+		}
+	}
+	var secdb securityDB
+	if err := json.Unmarshal(content, &secdb); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", apkRepository.SecurityDB, err)
+	}
+	return &secdb, nil
+}
+
+// findAdvisories returns the vulnerabilities of the installed packages which the security database lists as fixed in a
+// later version
+func findAdvisories(installedPackages []InstalledPackage, secdb *securityDB, source string) []AdvisoryFinding {
+	secfixes := make(map[string]map[string][]string, len(secdb.Packages))
+	for _, secdbPackage := range secdb.Packages {
+		secfixes[secdbPackage.Pkg.Name] = secdbPackage.Pkg.Secfixes
+	}
+	var findings []AdvisoryFinding
+	for _, installedPackage := range installedPackages {
+		for fixedVersion, vulnerabilities := range secfixes[installedPackage.Name] {
+			if fixedVersion == "0" || !versionGreaterThan(fixedVersion, installedPackage.Version) {
+				continue
+			}
+			for _, entry := range vulnerabilities {
+				// an entry may list aliases of the same vulnerability, e.g. a CVE and a GHSA
+				for _, vulnerability := range strings.Fields(entry) {
+					findings = append(findings, AdvisoryFinding{
+						Name:             installedPackage.Name,
+						InstalledVersion: installedPackage.Version,
+						Vulnerability:    vulnerability,
+						FixedVersion:     fixedVersion,
+						Source:           source,
+						Line:             installedPackage.Line,
+					})
+				}
+			}
+		}
+	}
+	return findings
+}
+
+// vulnerabilityURL returns a page describing the vulnerability, when its identifier is recognised
+func vulnerabilityURL(vulnerability string) string {
+	switch {
+	case strings.HasPrefix(vulnerability, "CVE-"):
+		return "https://nvd.nist.gov/vuln/detail/" + vulnerability
+	case strings.HasPrefix(vulnerability, "GHSA-"):
+		return "https://github.com/advisories/" + vulnerability
+	}
+	return ""
+}
+
+// advisorySARIF returns a SARIF rule for each vulnerability found and a result for each finding, located on the line of
+// inputPath the installed package was read from
+func advisorySARIF(inputPath string, findings []AdvisoryFinding) ([]sarifRule, []sarifResult) {
+	var rules []sarifRule
+	seenRules := make(map[string]struct{})
+	var results []sarifResult
+	for _, finding := range findings {
+		if _, seen := seenRules[finding.Vulnerability]; !seen {
+			seenRules[finding.Vulnerability] = struct{}{}
+			rules = append(rules, sarifRule{
+				ID:               finding.Vulnerability,
+				ShortDescription: sarifMessage{Text: finding.Vulnerability},
+				HelpURI:          vulnerabilityURL(finding.Vulnerability),
+			})
+		}
+		result := sarifResult{
+			RuleID:  finding.Vulnerability,
+			Level:   "error",
+			Message: sarifMessage{Text: fmt.Sprintf("Package %s %s is affected by %s, fixed in %s according to the %s repository security database", finding.Name, finding.InstalledVersion, finding.Vulnerability, finding.FixedVersion, finding.Source)},
+			// findings of the same package and vulnerability are tracked as one alert across runs
+			PartialFingerprints: map[string]string{"packageVulnerability": finding.Name + "/" + finding.Vulnerability},
+		}
+		if inputPath != "-" {
+			location := sarifLocation{PhysicalLocation: sarifPhysicalLocation{ArtifactLocation: sarifArtifactLocation{URI: inputPath}}}
+			if finding.Line > 0 {
+				location.PhysicalLocation.Region = &sarifRegion{StartLine: finding.Line}
+			}
+			result.Locations = []sarifLocation{location}
+		}
+		results = append(results, result)
+	}
+	return rules, results
+}
+
+// runAdvisories reports the vulnerabilities, listed in the security databases of the repositories, affecting the
+// name=version pairs read from a file, or stdin, which are fixed in later versions. It returns exitConstraintViolated when any
+// installed package is affected.
+func runAdvisories(args []string, repositories []Repository, authToken string, outputFormat string) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "Usage: %s advisories [FILE]\n", os.Args[0])
+		return exitUsage
+	}
+	inputPath := "-"
+	if len(args) == 1 {
+		inputPath = args[0]
+	}
+	lines, err := readInputLines(inputPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read installed packages: %v\n", err)
+		return exitUsage
+	}
+	installedPackages, err := parseInstalledPackages(lines)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to parse installed packages: %v\n", err)
+		return exitUsage
+	}
+
+	var findings []AdvisoryFinding
+	securityDBs := 0
+	for _, apkRepository := range repositories {
+		if apkRepository.SecurityDB == "" {
+			continue
+		}
+		secdb, err := fetchSecurityDB(apkRepository, authToken)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to load security database of %s repository: %v\n", apkRepository.Name, err)
+			continue
+		}
+		securityDBs++
+		findings = append(findings, findAdvisories(installedPackages, secdb, apkRepository.Name)...)
+	}
+	if securityDBs == 0 {
+		fmt.Fprintln(stderr, "No security database could be loaded from the repositories")
+		return exitFetchFailed
+	}
+	sort.SliceStable(findings, func(i, j int) bool {
+		if findings[i].Name != findings[j].Name {
+			return findings[i].Name < findings[j].Name
+		}
+		return findings[i].Vulnerability < findings[j].Vulnerability
+	})
+
+	switch outputFormat {
+	case "sarif":
+		rules, results := advisorySARIF(inputPath, findings)
+		if err := writeSARIF(stdout, rules, results); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "json":
+		jsonOutput, err := json.MarshalIndent(findings, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	default:
+		for _, finding := range findings {
+			fmt.Fprintf(stdout, "Package %s %s is affected by %s, fixed in %s (%s repository security database)\n", finding.Name, finding.InstalledVersion, finding.Vulnerability, finding.FixedVersion, finding.Source)
+		}
+		if len(findings) == 0 {
+			fmt.Fprintln(stdout, "No known vulnerabilities fixed in later versions")
+		}
+	}
+	if len(findings) > 0 {
+		return exitConstraintViolated
+	}
+	return 0
+}
@@ -0,0 +1,246 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha1"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// apkFile is an apk package split into its gzip compressed sections. An apk is the concatenation of an optional
+// signature section, a control section holding .PKGINFO and the install scripts, and a data section holding the files.
+type apkFile struct {
+	SignatureSection []byte
+	ControlSection   []byte
+	DataSection      []byte
+}
+
+// readAPK splits the apk read from r into its sections
+func readAPK(r io.Reader) (*apkFile, error) {
+	content, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+	// bytes.Reader implements io.ByteReader so the gzip reader never reads past the end of each gzip stream, which lets
+	// us record where each section starts and ends
+	contentReader := bytes.NewReader(content)
+	var sections [][]byte
+	for contentReader.Len() > 0 {
+		start := len(content) - contentReader.Len()
+		gzipReader, err := gzip.NewReader(contentReader)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read apk section %d: %w", len(sections)+1, err)
+		}
+		gzipReader.Multistream(false)
+		if _, err := io.Copy(io.Discard, gzipReader); err != nil {
+			return nil, fmt.Errorf("failed to read apk section %d: %w", len(sections)+1, err)
+		}
+		sections = append(sections, content[start:len(content)-contentReader.Len()])
+	}
+
+	switch len(sections) {
+	case 2:
+		return &apkFile{ControlSection: sections[0], DataSection: sections[1]}, nil
+	case 3:
+		return &apkFile{SignatureSection: sections[0], ControlSection: sections[1], DataSection: sections[2]}, nil
+	default:
+		return nil, fmt.Errorf("unexpected number of apk sections %d", len(sections))
+	}
+}
+
+// forEachEntry calls handle for each tar entry of a gzip compressed section
+func forEachEntry(section []byte, handle func(header *tar.Header, content io.Reader) error) error {
+	gzipReader, err := gzip.NewReader(bytes.NewReader(section))
+	if err != nil {
+		return err
+	}
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if err := handle(header, tarReader); err != nil {
+			return err
+		}
+	}
+}
+
+// Files returns the tar headers of the files, directories and links installed by the package
+func (a *apkFile) Files() ([]*tar.Header, error) {
+	var headers []*tar.Header
+	err := forEachEntry(a.DataSection, func(header *tar.Header, _ io.Reader) error {
+		headers = append(headers, header)
+		return nil
+	})
+	return headers, err
+}
+
+// PKGINFOField is a single key = value line of a .PKGINFO file. Keys such as depend and provides may be repeated.
+type PKGINFOField struct {
+	Key   string
+	Value string
+}
+
+// Control returns the fields of the .PKGINFO file and the names of the install scripts, e.g. .post-install, found in
+// the control section
+func (a *apkFile) Control() ([]PKGINFOField, []string, error) {
+	var fields []PKGINFOField
+	var scripts []string
+	err := forEachEntry(a.ControlSection, func(header *tar.Header, content io.Reader) error {
+		if header.Name != ".PKGINFO" {
+			scripts = append(scripts, header.Name)
+			return nil
+		}
+		pkginfo, err := io.ReadAll(content)
+		if err != nil {
+			return err
+		}
+		for _, line := range strings.Split(string(pkginfo), "\n") {
+			if line == "" || strings.HasPrefix(line, "#") {
+				continue
+			}
+			key, value, found := strings.Cut(line, " = ")
+			if !found {
+				continue
+			}
+			fields = append(fields, PKGINFOField{Key: key, Value: value})
+		}
+		return nil
+	})
+	return fields, scripts, err
+}
+
+// ControlChecksum returns the checksum of the control section in the Q1 prefixed, base64 encoded SHA-1 form recorded
+// in the APKINDEX
+func (a *apkFile) ControlChecksum() string {
+	checksum := sha1.Sum(a.ControlSection)
+	return "Q1" + base64.StdEncoding.EncodeToString(checksum[:])
+}
+
+// verifyChecksum checks the control section checksum of the apk against the checksum recorded in the APKINDEX
+func verifyChecksum(apk *apkFile, pkg *repository.Package) error {
+	indexChecksum := "Q1" + base64.StdEncoding.EncodeToString(pkg.Checksum)
+	if apkChecksum := apk.ControlChecksum(); apkChecksum != indexChecksum {
+		return fmt.Errorf("CHECKSUM MISMATCH for %s: APKINDEX records %s but the package has %s", pkg.Filename(), indexChecksum, apkChecksum)
+	}
+	return nil
+}
+
+// resolvePackage finds the named package in the repositories. When packageVersion is empty the latest version is
+// returned, preferring the repository listed first when the same version is in more than one.
+func resolvePackage(repositories []Repository, authToken string, packageName string, packageVersion string) (Repository, *repository.Package, error) {
+	var resolvedRepository Repository
+	var resolvedPackage *repository.Package
+	_, err := forEachIndexedPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if pkg.Name != packageName || (packageVersion != "" && pkg.Version != packageVersion) {
+			return
+		}
+		if resolvedPackage == nil || versionGreaterThan(pkg.Version, resolvedPackage.Version) {
+			resolvedRepository, resolvedPackage = apkRepository, pkg
+		}
+	})
+	if err != nil {
+		return Repository{}, nil, err
+	}
+	if resolvedPackage == nil {
+		if packageVersion != "" {
+			return Repository{}, nil, fmt.Errorf("package %s version %s not found", packageName, packageVersion)
+		}
+		return Repository{}, nil, fmt.Errorf("package %s not found", packageName)
+	}
+	return resolvedRepository, resolvedPackage, nil
+}
+
+// splitPackageReference splits a PACKAGE[=VERSION] reference
+func splitPackageReference(reference string) (string, string) {
+	packageName, packageVersion, _ := strings.Cut(reference, "=")
+	return packageName, packageVersion
+}
+
+// fetchAPK returns the apk of the package in the repository, using the cached copy when it has already been downloaded.
+// The apk lives alongside the APKINDEX, either remotely or on the local filesystem, or in the directory of apks.
+func fetchAPK(apkRepository Repository, pkg *repository.Package, authToken string) (*apkFile, error) {
+	localDir := apkRepository.PackagesDir
+	if _, err := os.Stat(apkRepository.URL); err == nil {
+		localDir = filepath.Dir(apkRepository.URL)
+	}
+	if localDir != "" {
+		apkLocation := filepath.Join(localDir, pkg.Filename())
+		apkReader, err := os.Open(apkLocation)
+		if err != nil {
+			return nil, err
+		}
+		defer apkReader.Close()
+		apk, err := readAPK(apkReader)
+		if err != nil {
+			return nil, err
+		}
+		return apk, verifyChecksum(apk, pkg)
+	}
+
+	cacheKey := apkCacheKey(apkRepository, pkg)
+	if cached, err := packageCache.Get(cacheKey); err == nil && cached != nil {
+		// a cached copy which no longer matches the index, e.g. the package was rebuilt with the same version, is
+		// discarded and downloaded again
+		if apk, err := readAPK(bytes.NewReader(cached.Value)); err == nil && verifyChecksum(apk, pkg) == nil {
+			return apk, nil
+		}
+		_ = packageCache.Delete(cacheKey)
+	}
+
+	// the apk is fetched from the first of the repository URL or its mirrors which serves it
+	var content []byte
+	var err error
+	var downloadErrors []error
+	for _, APKINDEXurl := range apkRepository.URLs() {
+		apkLocation := APKINDEXurl[:strings.LastIndex(APKINDEXurl, "/")+1] + pkg.Filename()
+		content, err = downloadAPK(apkLocation, apkRepository, authToken)
+		if err == nil {
+			logVerbose("Fetched %s from %s", pkg.Filename(), apkLocation)
+			break
+		}
+		logVerbose("Failed to fetch %s from %s: %v", pkg.Filename(), apkLocation, err)
+		downloadErrors = append(downloadErrors, err)
+	}
+	if content == nil {
+		return nil, errors.Join(downloadErrors...)
+	}
+	apk, err := readAPK(bytes.NewReader(content))
+	if err != nil {
+		return nil, err
+	}
+	if err := verifyChecksum(apk, pkg); err != nil {
+		return nil, err
+	}
+
+	// caching is best effort, failing to cache the apk should not fail the command
+	_ = packageCache.Put(cacheKey, content)
+	return apk, nil
+}
+
+// downloadAPK downloads the apk at apkLocation from the repository
+func downloadAPK(apkLocation string, apkRepository Repository, authToken string) ([]byte, error) {
+	resp, err := httpGet(apkLocation, apkRepository, authToken)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	content, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", apkLocation, err)
+	}
+	return content, nil
+}
@@ -0,0 +1,156 @@
+package main
+
+import (
+	"archive/tar"
+	"bufio"
+	"compress/gzip"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// maximumAPKINDEXLine is the longest line of an APKINDEX accepted, long dependency lists make some lines very long
+const maximumAPKINDEXLine = 16 * 1024 * 1024
+
+// lowMemory is set by the --low-memory flag. The APKINDEX is then decompressed to a temporary file and parsed from disk,
+// so neither the compressed nor the decompressed index is ever held in memory, keeping the peak memory use predictable
+// for very large indices on small CI runners.
+var lowMemory bool
+
+// streamAPKINDEX reads the APKINDEX.tar.gz at localAPKINDEXPath and calls handle for each package as soon as its record
+// has been read. Unlike repository.IndexFromArchive the packages are never all held in memory, a package handle does
+// not keep is garbage as soon as handle returns.
+func streamAPKINDEX(localAPKINDEXPath string, handle func(pkg *repository.Package)) error {
+	indexFile, err := os.Open(localAPKINDEXPath)
+	if err != nil {
+		return fmt.Errorf("failed to open APKINDEX file %s: %w", localAPKINDEXPath, err)
+	}
+	defer indexFile.Close()
+	return streamAPKINDEXArchive(indexFile, localAPKINDEXPath, handle)
+}
+
+// streamAPKINDEXArchive reads an APKINDEX.tar.gz named localAPKINDEXPath from r and calls handle for each package, as
+// streamAPKINDEX does
+func streamAPKINDEXArchive(r io.Reader, localAPKINDEXPath string, handle func(pkg *repository.Package)) error {
+	gzipReader, err := gzip.NewReader(r)
+	if err != nil {
+		return fmt.Errorf("failed to decompress APKINDEX file %s: %w", localAPKINDEXPath, err)
+	}
+	// the signature and the index are separate gzip streams
+	gzipReader.Multistream(true)
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return fmt.Errorf("no APKINDEX in %s", localAPKINDEXPath)
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read APKINDEX file %s: %w", localAPKINDEXPath, err)
+		}
+		if header.Name == "APKINDEX" {
+			if lowMemory {
+				return streamDecompressedPackageIndex(tarReader, localAPKINDEXPath, handle)
+			}
+			return streamPackageIndex(tarReader, handle)
+		}
+	}
+}
+
+// streamDecompressedPackageIndex writes the decompressed APKINDEX of the APKINDEX.tar.gz at localAPKINDEXPath, read
+// from r, to a temporary file and parses the packages from the file
+func streamDecompressedPackageIndex(r io.Reader, localAPKINDEXPath string, handle func(pkg *repository.Package)) error {
+	decompressedFile, err := os.CreateTemp("", "wolfi-package-status-APKINDEX-*")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary file: %w", err)
+	}
+	defer os.Remove(decompressedFile.Name())
+	defer decompressedFile.Close()
+	if _, err := io.Copy(decompressedFile, r); err != nil {
+		return fmt.Errorf("failed to decompress APKINDEX file %s: %w", localAPKINDEXPath, err)
+	}
+	if _, err := decompressedFile.Seek(0, io.SeekStart); err != nil {
+		return err
+	}
+	return streamPackageIndex(decompressedFile, handle)
+}
+
+// streamPackageIndex parses the package records of an APKINDEX, calling handle for each. Records are separated by a
+// blank line and each line is a single letter field, e.g. P:python-3.12.
+func streamPackageIndex(r io.Reader, handle func(pkg *repository.Package)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), maximumAPKINDEXLine)
+	var pkg *repository.Package
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if pkg != nil {
+				handle(pkg)
+			}
+			pkg = nil
+			continue
+		}
+		if pkg == nil {
+			pkg = &repository.Package{}
+		}
+		if len(line) < 2 || line[1] != ':' {
+			continue
+		}
+		setPackageField(pkg, line[0], line[2:])
+	}
+	if pkg != nil {
+		handle(pkg)
+	}
+	return scanner.Err()
+}
+
+// setPackageField sets the field of the package identified by the APKINDEX field letter, as repository.IndexFromArchive
+// does. Unknown fields are ignored.
+func setPackageField(pkg *repository.Package, field byte, value string) {
+	switch field {
+	case 'P':
+		pkg.Name = value
+	case 'V':
+		pkg.Version = value
+	case 'A':
+		pkg.Arch = value
+	case 'T':
+		pkg.Description = value
+	case 'L':
+		pkg.License = value
+	case 'o':
+		pkg.Origin = value
+	case 'm':
+		pkg.Maintainer = value
+	case 'U':
+		pkg.URL = value
+	case 'c':
+		pkg.RepoCommit = value
+	case 'C':
+		if checksum, found := strings.CutPrefix(value, "Q1"); found {
+			pkg.Checksum, _ = base64.StdEncoding.DecodeString(checksum)
+		}
+	case 'D':
+		pkg.Dependencies = strings.Fields(value)
+	case 'p':
+		pkg.Provides = strings.Fields(value)
+	case 'i':
+		pkg.InstallIf = strings.Fields(value)
+	case 'r':
+		pkg.Replaces = strings.Fields(value)
+	case 'S':
+		pkg.Size, _ = strconv.ParseUint(value, 10, 64)
+	case 'I':
+		pkg.InstalledSize, _ = strconv.ParseUint(value, 10, 64)
+	case 'k':
+		pkg.ProviderPriority, _ = strconv.ParseUint(value, 10, 64)
+	case 't':
+		buildTime, _ := strconv.ParseInt(value, 10, 64)
+		pkg.BuildTime = time.Unix(buildTime, 0).UTC()
+	}
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// testAPKINDEX has every field parsed, a field this tool does not know, a line which is not a field, as a wrapped
+// continuation of the previous line would be, and a last record without a blank line after it
+const testAPKINDEX = `C:Q1nY9dkVhq7zR3tXq1/8bXXYzFo0s=
+P:python-3.12
+V:3.12.5-r1
+A:x86_64
+S:25466624
+I:106323968
+T:the python programming language
+U:https://python.org/
+L:PSF-2.0
+o:python-3.12
+m:Wolfi <wolfi@example.com>
+t:1720000000
+c:0123456789abcdef0123456789abcdef01234567
+k:100
+D:so:libc.so.6 so:libpython3.12.so.1.0 !python-3.11
+p:cmd:python3=3.12.5-r1 so:libpython3.12.so.1.0=1.0
+i:python-3.12 pip
+r:python-3.11
+F:usr/bin
+  wrapped continuation line
+Z:an unknown field
+
+C:Q1abcdefghijklmnopqrstuvwxyz0=
+P:python-3.12-dev
+V:3.12.5-r1
+A:x86_64
+o:python-3.12
+D:python-3.12=3.12.5-r1
+
+
+P:glibc
+V:2.40-r0
+p:so:libc.so.6=6
+`
+
+func TestStreamPackageIndex(t *testing.T) {
+	want, err := repository.ParsePackageIndex(strings.NewReader(testAPKINDEX))
+	if err != nil {
+		t.Fatalf("repository.ParsePackageIndex() error = %v", err)
+	}
+	var got []*repository.Package
+	if err := streamPackageIndex(strings.NewReader(testAPKINDEX), func(pkg *repository.Package) {
+		got = append(got, pkg)
+	}); err != nil {
+		t.Fatalf("streamPackageIndex() error = %v", err)
+	}
+	if len(got) != 3 {
+		t.Fatalf("streamPackageIndex() parsed %d packages, want 3", len(got))
+	}
+	// the known fields of the first record are all parsed
+	if pkg := got[0]; pkg.Name != "python-3.12" || pkg.ProviderPriority != 100 || pkg.BuildTime.Unix() != 1720000000 ||
+		len(pkg.Checksum) != 20 || !reflect.DeepEqual(pkg.Provides, []string{"cmd:python3=3.12.5-r1", "so:libpython3.12.so.1.0=1.0"}) ||
+		!reflect.DeepEqual(pkg.Replaces, []string{"python-3.11"}) || !reflect.DeepEqual(pkg.InstallIf, []string{"python-3.12", "pip"}) {
+		t.Errorf("streamPackageIndex() parsed %+v", pkg)
+	}
+	if !reflect.DeepEqual(got, want) {
+		for i := range got {
+			if i < len(want) && !reflect.DeepEqual(got[i], want[i]) {
+				t.Errorf("package %d = %+v, repository.ParsePackageIndex parsed %+v", i, got[i], want[i])
+			}
+		}
+		t.Fatalf("streamPackageIndex() parsed %d packages, repository.ParsePackageIndex parsed %d", len(got), len(want))
+	}
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// nonPublicHosts are the hosts of apk repositories which need an auth token
+var nonPublicHosts = []string{"apk.cgr.dev", "packages.cgr.dev"}
+
+// readAPKRepositories returns the repositories listed, in order, in a file in the format of /etc/apk/repositories - one
+// base URL or local directory per line, optionally prefixed with an @tag, with blank lines and lines starting with #
+// ignored. The default repositories are recognised by their URL and keep their ID and name, others are identified by
+// their tag or URL.
+func readAPKRepositories(path string, arch string) ([]Repository, error) {
+	lines, err := readInputLines(path)
+	if err != nil {
+		return nil, err
+	}
+	var repositories []Repository
+	for _, line := range lines {
+		fields := strings.Fields(line)
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+		var tag string
+		tagged := strings.HasPrefix(fields[0], "@")
+		if tagged {
+			tag = strings.TrimPrefix(fields[0], "@")
+			fields = fields[1:]
+		}
+		if len(fields) != 1 || (tagged && tag == "") {
+			return nil, fmt.Errorf("invalid repository %q - expected [@TAG] URL", line)
+		}
+		apkRepository, err := parseAPKRepository(fields[0], tag, arch)
+		if err != nil {
+			return nil, err
+		}
+		repositories = append(repositories, apkRepository)
+	}
+	if len(repositories) == 0 {
+		return nil, fmt.Errorf("no repositories in %s", path)
+	}
+	return repositories, nil
+}
+
+// parseAPKRepository returns the repository of a line of /etc/apk/repositories, its base URL and tag
+func parseAPKRepository(baseURL string, tag string, arch string) (Repository, error) {
+	indexURL, expanded := expandRepositoryURL(baseURL, arch)
+	for _, defaultRepository := range defaultRepositories(arch) {
+		if defaultRepository.URL == indexURL {
+			defaultRepository.Tag = tag
+			return defaultRepository, nil
+		}
+	}
+	apkRepository := Repository{ID: strings.TrimSuffix(baseURL, "/"), Name: strings.TrimSuffix(baseURL, "/"), URL: indexURL, Tag: tag}
+	if tag != "" {
+		apkRepository.ID = tag
+	}
+	if expanded {
+		apkRepository.Arch = arch
+	}
+	if parsedURL, err := url.Parse(baseURL); err == nil && parsedURL.Host != "" {
+		if parsedURL.Scheme != "http" && parsedURL.Scheme != "https" {
+			return Repository{}, fmt.Errorf("unsupported repository URL %s", baseURL)
+		}
+		for _, host := range nonPublicHosts {
+			apkRepository.RequiresAuth = apkRepository.RequiresAuth || parsedURL.Hostname() == host
+		}
+	}
+	return apkRepository, nil
+}
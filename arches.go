@@ -0,0 +1,112 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+)
+
+// ArchPackages is the number of packages a repository publishes for an architecture, or why none could be found
+type ArchPackages struct {
+	Arch     string
+	Packages int    `json:",omitempty"`
+	Error    string `json:",omitempty"`
+}
+
+// RepositoryArches lists the architectures probed for a repository
+type RepositoryArches struct {
+	Repository string
+	Arches     []ArchPackages
+}
+
+// forArch returns the repository for the packages of another architecture, whose APKINDEX is found by swapping the
+// architecture in the URL of the repository and its mirrors
+func (r Repository) forArch(arch string) Repository {
+	swapArch := func(APKINDEXurl string) string {
+		return strings.Replace(APKINDEXurl, "/"+r.Arch+"/APKINDEX.tar.gz", "/"+arch+"/APKINDEX.tar.gz", 1)
+	}
+	archRepository := r
+	archRepository.Arch = arch
+	archRepository.URL = swapArch(r.URL)
+	archRepository.Mirrors = nil
+	for _, mirror := range r.Mirrors {
+		archRepository.Mirrors = append(archRepository.Mirrors, swapArch(mirror))
+	}
+	return archRepository
+}
+
+// supportedArches returns the apk architectures which can be queried with --arch, sorted
+func supportedArches() []string {
+	arches := make([]string, 0, len(apkArchitectures))
+	for _, apkArchitecture := range apkArchitectures {
+		arches = append(arches, apkArchitecture)
+	}
+	sort.Strings(arches)
+	return arches
+}
+
+// isSupportedArch reports whether arch is one of the apk architectures packages are published for
+func isSupportedArch(arch string) bool {
+	for _, supportedArch := range supportedArches() {
+		if arch == supportedArch {
+			return true
+		}
+	}
+	return false
+}
+
+// runArches probes each repository for the APKINDEX of every supported architecture and reports the number of
+// packages published for each, so users know which --arch values are valid. Local repositories have no architecture to
+// probe.
+func runArches(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 0 {
+		fmt.Fprintf(stderr, "Usage: %s arches\n", os.Args[0])
+		return exitUsage
+	}
+	var report []RepositoryArches
+	for _, apkRepository := range repositories {
+		if apkRepository.Arch == "" {
+			logVerbose("Skipping %s repository which has no architecture", apkRepository.Name)
+			continue
+		}
+		repositoryArches := RepositoryArches{Repository: apkRepository.Name}
+		for _, arch := range supportedArches() {
+			archPackages := ArchPackages{Arch: arch}
+			if apkIndex, err := fetchAPKINDEX(apkRepository.forArch(arch), authToken); err != nil {
+				archPackages.Error = err.Error()
+			} else {
+				archPackages.Packages = len(apkIndex.Packages)
+			}
+			repositoryArches.Arches = append(repositoryArches.Arches, archPackages)
+		}
+		report = append(report, repositoryArches)
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	if len(report) == 0 {
+		fmt.Fprintln(stdout, "No repositories with architectures to probe")
+		return 0
+	}
+	for _, repositoryArches := range report {
+		fmt.Fprintf(stdout, "Architectures of %s repository:\n", repositoryArches.Repository)
+		for _, archPackages := range repositoryArches.Arches {
+			if archPackages.Error != "" {
+				// the errors of the repository URL and each of its mirrors are joined on one line
+				fmt.Fprintf(stdout, "\t%s: not available - %s\n", archPackages.Arch, strings.ReplaceAll(archPackages.Error, "\n", "; "))
+			} else {
+				fmt.Fprintf(stdout, "\t%s: %d packages\n", archPackages.Arch, archPackages.Packages)
+			}
+		}
+	}
+	return 0
+}
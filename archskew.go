@@ -0,0 +1,119 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// ArchMismatch is a package whose latest version differs between the architectures of a repository, which usually
+// indicates a rebuild which has only completed for some architectures
+type ArchMismatch struct {
+	Name       string
+	Repository string
+	// Versions maps each architecture to the latest version of the package for it
+	Versions map[string]string
+}
+
+// findArchMismatches returns the packages, sorted by name and repository, whose latest version is not the same for
+// every architecture of a repository they are published for. latestVersions maps each repository to the latest
+// version of each package for each architecture.
+func findArchMismatches(latestVersions map[string]map[string]map[string]string) []ArchMismatch {
+	var mismatches []ArchMismatch
+	for repositoryName, packages := range latestVersions {
+		for packageName, versions := range packages {
+			distinctVersions := make(map[string]struct{})
+			for _, latestVersion := range versions {
+				distinctVersions[latestVersion] = struct{}{}
+			}
+			if len(distinctVersions) > 1 {
+				mismatches = append(mismatches, ArchMismatch{Name: packageName, Repository: repositoryName, Versions: versions})
+			}
+		}
+	}
+	sort.Slice(mismatches, func(i, j int) bool {
+		if mismatches[i].Name != mismatches[j].Name {
+			return mismatches[i].Name < mismatches[j].Name
+		}
+		return mismatches[i].Repository < mismatches[j].Repository
+	})
+	return mismatches
+}
+
+// runArchSkew fetches the packages of every supported architecture of each repository and reports the matching
+// packages, or all packages, whose latest version differs between architectures of the same repository. With strict set
+// mismatches are reported with a non zero exit code.
+func runArchSkew(args []string, repositories []Repository, authToken string, matchAsRegex bool, asJSON bool, strict bool) int {
+	var archRepositories []Repository
+	for _, apkRepository := range repositories {
+		if apkRepository.Arch == "" {
+			logVerbose("Skipping %s repository which has no architecture", apkRepository.Name)
+			continue
+		}
+		for _, arch := range supportedArches() {
+			archRepositories = append(archRepositories, apkRepository.forArch(arch))
+		}
+	}
+
+	matchers := newMatchers(args, matchAsRegex)
+	latestVersions := make(map[string]map[string]map[string]string)
+	_, err := forEachPackage(archRepositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if len(matchers) > 0 && len(matchReference(matchers, pkg)) == 0 {
+			return
+		}
+		if latestVersions[apkRepository.Name] == nil {
+			latestVersions[apkRepository.Name] = make(map[string]map[string]string)
+		}
+		versions := latestVersions[apkRepository.Name][pkg.Name]
+		if versions == nil {
+			versions = make(map[string]string)
+			latestVersions[apkRepository.Name][pkg.Name] = versions
+		}
+		if latestVersion, found := versions[apkRepository.Arch]; !found || versionGreaterThan(pkg.Version, latestVersion) {
+			versions[apkRepository.Arch] = pkg.Version
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	mismatches := findArchMismatches(latestVersions)
+	exitCode := 0
+	if strict && len(mismatches) > 0 {
+		exitCode = exitConstraintViolated
+	}
+
+	if asJSON {
+		if mismatches == nil {
+			mismatches = []ArchMismatch{}
+		}
+		jsonOutput, err := json.MarshalIndent(mismatches, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return exitCode
+	}
+	if len(mismatches) == 0 {
+		fmt.Fprintln(stdout, "No version mismatches between architectures")
+		return exitCode
+	}
+	fmt.Fprintln(stdout, "Version mismatches between architectures - usually a partially completed rebuild:")
+	for _, mismatch := range mismatches {
+		arches := make([]string, 0, len(mismatch.Versions))
+		for arch := range mismatch.Versions {
+			arches = append(arches, arch)
+		}
+		sort.Strings(arches)
+		versions := make([]string, 0, len(arches))
+		for _, arch := range arches {
+			versions = append(versions, fmt.Sprintf("%s for %s", mismatch.Versions[arch], arch))
+		}
+		fmt.Fprintf(stdout, "%s in %s repository: %s\n", mismatch.Name, mismatch.Repository, strings.Join(versions, ", "))
+	}
+	return exitCode
+}
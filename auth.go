@@ -0,0 +1,130 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+)
+
+// RepositoryAccess is whether the auth token grants access to a non public repository
+type RepositoryAccess struct {
+	Repository string
+	URL        string
+	Accessible bool
+	// Status is the HTTP status of the request for the APKINDEX, or why the request failed
+	Status string
+}
+
+// AuthCheck is the validity of the auth token and the non public repositories it grants access to
+type AuthCheck struct {
+	// Expiry is the expiry of the token read from its exp claim, when the token is a JWT
+	Expiry       *time.Time `json:",omitempty"`
+	Expired      bool       `json:",omitempty"`
+	Repositories []RepositoryAccess
+}
+
+// tokenExpiry reads the expiry from the exp claim of a JWT, without verifying its signature - only the repositories can
+// tell whether the token is valid
+func tokenExpiry(token string) (time.Time, error) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return time.Time{}, errors.New("the token is not a JWT")
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	var claims struct {
+		Expiry int64 `json:"exp"`
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return time.Time{}, fmt.Errorf("invalid JWT payload: %w", err)
+	}
+	if claims.Expiry == 0 {
+		return time.Time{}, errors.New("the token has no exp claim")
+	}
+	return time.Unix(claims.Expiry, 0).UTC(), nil
+}
+
+// checkAccess sends a HEAD request for the APKINDEX of the repository with the auth token
+func checkAccess(apkRepository Repository, authToken string) RepositoryAccess {
+	access := RepositoryAccess{Repository: apkRepository.Name, URL: apkRepository.URL}
+	req, err := newRepositoryRequest(apkRepository.URL, apkRepository, authToken)
+	if err != nil {
+		access.Status = err.Error()
+		return access
+	}
+	req.Method = http.MethodHead
+	resp, err := (&http.Client{}).Do(req)
+	if err != nil {
+		access.Status = err.Error()
+		return access
+	}
+	resp.Body.Close()
+	access.Accessible = resp.StatusCode == http.StatusOK
+	access.Status = resp.Status
+	return access
+}
+
+// runAuth validates the auth token against each configured non public repository and reports its expiry and which of
+// the repositories it can access. The exit code is 2 when any of them can not be accessed.
+func runAuth(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 1 || args[0] != "check" {
+		fmt.Fprintf(stderr, "Usage: %s auth check\n", os.Args[0])
+		return exitUsage
+	}
+	check := AuthCheck{Repositories: []RepositoryAccess{}}
+	expiry, expiryErr := tokenExpiry(authToken)
+	if expiryErr == nil {
+		check.Expiry = &expiry
+		check.Expired = expiry.Before(time.Now())
+	} else {
+		logVerbose("Unable to read the expiry of the auth token: %v", expiryErr)
+	}
+	exitCode := 0
+	for _, apkRepository := range repositories {
+		if !apkRepository.RequiresAuth {
+			continue
+		}
+		access := checkAccess(apkRepository, authToken)
+		if !access.Accessible {
+			exitCode = exitFetchFailed
+		}
+		check.Repositories = append(check.Repositories, access)
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(check, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return exitCode
+	}
+	switch {
+	case check.Expiry == nil:
+		fmt.Fprintf(stdout, "Token expiry: unknown - %v\n", expiryErr)
+	case check.Expired:
+		fmt.Fprintf(stdout, "Token expired %s\n", formatTime(*check.Expiry))
+	default:
+		fmt.Fprintf(stdout, "Token expires %s\n", formatTime(*check.Expiry))
+	}
+	if len(check.Repositories) == 0 {
+		fmt.Fprintln(stdout, "No non public repositories are configured")
+		return exitCode
+	}
+	for _, access := range check.Repositories {
+		if access.Accessible {
+			fmt.Fprintf(stdout, "%s repository: accessible\n", access.Repository)
+		} else {
+			fmt.Fprintf(stdout, "%s repository: not accessible - %s\n", access.Repository, access.Status)
+		}
+	}
+	return exitCode
+}
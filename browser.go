@@ -0,0 +1,41 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"runtime"
+)
+
+// openBrowser opens url in the default browser of the user
+func openBrowser(url string) error {
+	var command *exec.Cmd
+	switch runtime.GOOS {
+	case "darwin":
+		command = exec.Command("open", url)
+	case "windows":
+		command = exec.Command("rundll32", "url.dll,FileProtocolHandler", url)
+	default:
+		command = exec.Command("xdg-open", url)
+	}
+	return command.Start()
+}
+
+// OpenFirstMatch opens the melange build definition of the first matching package, in the order of the output, or its
+// homepage when the repository does not publish its definitions, in the default browser
+func (r *Results) OpenFirstMatch(options PrintOptions) error {
+	packageNames := r.orderedPackageNames(options)
+	if len(packageNames) == 0 {
+		return errors.New("no matching package")
+	}
+	packageMeta := r.LatestVersion[packageNames[0]]
+	url := packageMeta.DefinitionURL
+	if url == "" {
+		url = packageMeta.URL
+	}
+	if url == "" {
+		return fmt.Errorf("package %s has no definition or homepage URL", packageNames[0])
+	}
+	logVerbose("Opening %s", url)
+	return openBrowser(url)
+}
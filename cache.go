@@ -0,0 +1,133 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path"
+	"path/filepath"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// Cache stores downloaded files, such as apks and parsed indices, by key. Keys are slash separated paths such as
+// apks/wolfi/python-3.12-3.12.5-r1.apk.
+type Cache interface {
+	// Get returns the cached entry, or nil when the key is not cached
+	Get(key string) (*CacheEntry, error)
+	Put(key string, value []byte) error
+	Delete(key string) error
+	Close() error
+}
+
+// CacheEntry is a cached value and when it was stored
+type CacheEntry struct {
+	Value    []byte
+	Modified time.Time
+}
+
+// CacheConfig selects the cache backend in the configuration file
+type CacheConfig struct {
+	// Backend is filesystem, the default, or bbolt
+	Backend string `yaml:"backend"`
+	// Path is the cache directory of the filesystem backend or the database file of the bbolt backend, by default in
+	// the wolfi-package-status directory of the user cache directory
+	Path string `yaml:"path"`
+}
+
+// packageCache is the cache used by all commands, opened from the configuration
+var packageCache Cache
+
+// openCache opens the cache backend selected by the configuration
+func openCache(config CacheConfig) (Cache, error) {
+	cachePath := config.Path
+	if cachePath == "" {
+		var err error
+		if cachePath, err = cacheDir(); err != nil {
+			return nil, err
+		}
+		if config.Backend == "bbolt" {
+			cachePath = filepath.Join(cachePath, "cache.db")
+		}
+	}
+	switch config.Backend {
+	case "", "filesystem":
+		return &filesystemCache{directory: cachePath}, nil
+	case "bbolt":
+		return openBoltCache(cachePath)
+	default:
+		return nil, fmt.Errorf("unsupported cache backend %s - supported backends are filesystem and bbolt", config.Backend)
+	}
+}
+
+// filesystemCache stores each key as a file beneath a directory
+type filesystemCache struct {
+	directory string
+}
+
+func (c *filesystemCache) path(key string) string {
+	return filepath.Join(c.directory, filepath.FromSlash(key))
+}
+
+func (c *filesystemCache) Get(key string) (*CacheEntry, error) {
+	value, err := os.ReadFile(c.path(key))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	info, err := os.Stat(c.path(key))
+	if err != nil {
+		return nil, err
+	}
+	return &CacheEntry{Value: value, Modified: info.ModTime()}, nil
+}
+
+func (c *filesystemCache) Put(key string, value []byte) error {
+	if err := os.MkdirAll(filepath.Dir(c.path(key)), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(c.path(key), value, 0o644)
+}
+
+func (c *filesystemCache) Delete(key string) error {
+	if err := os.Remove(c.path(key)); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return err
+	}
+	return nil
+}
+
+func (c *filesystemCache) Close() error {
+	return nil
+}
+
+// apkCacheKey returns the cache key of a downloaded apk of the repository
+func apkCacheKey(apkRepository Repository, pkg *repository.Package) string {
+	return path.Join("apks", apkRepository.ID, apkRepository.Arch, pkg.Filename())
+}
+
+// removedBaselineKey returns the cache key of the latest version of each package of the repository as of the previous
+// run of the removed command, which removed packages are reported against
+func removedBaselineKey(apkRepository Repository) string {
+	return path.Join("removed", apkRepository.ID, apkRepository.Arch, "latest.json")
+}
+
+// parsedIndexKey returns the cache key of the packages parsed from the APKINDEX most recently fetched from the
+// repository
+func parsedIndexKey(apkRepository Repository) string {
+	return path.Join("indices", apkRepository.ID, apkRepository.Arch, "packages.gob")
+}
+
+// saveRemovedBaseline keeps the latest version of each package of the repository so the next run of the removed
+// command can compare against it. This is best effort, failing to save the baseline should not fail the command.
+func saveRemovedBaseline(apkRepository Repository, versions map[string]string) {
+	content, err := json.Marshal(versions)
+	if err != nil {
+		return
+	}
+	_ = packageCache.Put(removedBaselineKey(apkRepository), content)
+}
@@ -0,0 +1,120 @@
+//go:build !js
+
+package main
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// boltCacheBucket is the bucket of the bbolt database holding the cache
+var boltCacheBucket = []byte("cache")
+
+// boltLockTimeout bounds how long an operation waits for another process using the database to finish with it
+const boltLockTimeout = 10 * time.Second
+
+// boltCache stores the cache in a single bbolt database file, each value prefixed with when it was stored. Unlike the
+// filesystem backend it keeps the cache in one file, which suits long running server modes. bbolt locks the database
+// file while it is open, so it is only opened for the duration of each operation and other processes, such as a run
+// alongside the daemon, can use the cache in between.
+type boltCache struct {
+	path string
+	// mutex serialises the operations of this process, which would otherwise wait on each other's file locks
+	mutex sync.Mutex
+}
+
+func openBoltCache(databasePath string) (*boltCache, error) {
+	if err := os.MkdirAll(filepath.Dir(databasePath), 0o755); err != nil {
+		return nil, err
+	}
+	cache := &boltCache{path: databasePath}
+	// the database and its bucket are created up front so readers can open it read only
+	if err := cache.update(func(*bolt.Bucket) error { return nil }); err != nil {
+		return nil, err
+	}
+	return cache, nil
+}
+
+// view runs read with the bucket of the database opened read only, sharing the file lock with other readers
+func (c *boltCache) view(read func(bucket *bolt.Bucket) error) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	db, err := bolt.Open(c.path, 0o644, &bolt.Options{Timeout: boltLockTimeout, ReadOnly: true})
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to open cache database %s: %w", c.path, err)
+	}
+	defer db.Close()
+	return db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltCacheBucket)
+		if bucket == nil {
+			return nil
+		}
+		return read(bucket)
+	})
+}
+
+// update runs write with the bucket of the database opened for writing, holding the file lock exclusively
+func (c *boltCache) update(write func(bucket *bolt.Bucket) error) error {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	db, err := bolt.Open(c.path, 0o644, &bolt.Options{Timeout: boltLockTimeout})
+	if err != nil {
+		return fmt.Errorf("failed to open cache database %s: %w", c.path, err)
+	}
+	defer db.Close()
+	return db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.CreateBucketIfNotExists(boltCacheBucket)
+		if err != nil {
+			return err
+		}
+		return write(bucket)
+	})
+}
+
+func (c *boltCache) Get(key string) (*CacheEntry, error) {
+	var entry *CacheEntry
+	err := c.view(func(bucket *bolt.Bucket) error {
+		stored := bucket.Get([]byte(key))
+		if len(stored) < 8 {
+			return nil
+		}
+		// the stored value is only valid for the life of the transaction so it is copied
+		entry = &CacheEntry{
+			Value:    append([]byte(nil), stored[8:]...),
+			Modified: time.Unix(0, int64(binary.BigEndian.Uint64(stored[:8]))),
+		}
+		return nil
+	})
+	return entry, err
+}
+
+func (c *boltCache) Put(key string, value []byte) error {
+	stored := make([]byte, 8+len(value))
+	binary.BigEndian.PutUint64(stored[:8], uint64(time.Now().UnixNano()))
+	copy(stored[8:], value)
+	return c.update(func(bucket *bolt.Bucket) error {
+		return bucket.Put([]byte(key), stored)
+	})
+}
+
+func (c *boltCache) Delete(key string) error {
+	return c.update(func(bucket *bolt.Bucket) error {
+		return bucket.Delete([]byte(key))
+	})
+}
+
+// Close does nothing as the database is only open during each operation
+func (c *boltCache) Close() error {
+	return nil
+}
@@ -0,0 +1,14 @@
+//go:build js
+
+package main
+
+import "errors"
+
+// boltCache is not available in the browser, bbolt needs a filesystem with memory mapped files
+type boltCache struct {
+	Cache
+}
+
+func openBoltCache(databasePath string) (*boltCache, error) {
+	return nil, errors.New("the bbolt cache backend is not supported in the browser")
+}
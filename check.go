@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// RequirementResult is the outcome of a single requirement of the check command
+type RequirementResult struct {
+	Requirement string
+	Passed      bool
+	// Version and Repository are the highest version satisfying the requirement or, when no version does, the latest
+	// version of the package
+	Version    string `json:",omitempty"`
+	Repository string `json:",omitempty"`
+	// Reason is why the requirement failed - missing when the package is in no repository or version when no version
+	// satisfies the constraint
+	Reason string `json:",omitempty"`
+}
+
+// checkRequirements evaluates each requirement against the versions of the packages in the results. A requirement is
+// met when any version of the package satisfies its constraint.
+func checkRequirements(requirements []Constraint, results *Results) []RequirementResult {
+	requirementResults := make([]RequirementResult, 0, len(requirements))
+	for _, requirement := range requirements {
+		requirementResult := RequirementResult{Requirement: requirement.String()}
+		versions := results.sortedVersions(requirement.Name)
+		if len(versions) == 0 {
+			requirementResult.Reason = "missing"
+			requirementResults = append(requirementResults, requirementResult)
+			continue
+		}
+		// versions are ordered oldest first so the newest satisfying version is the last one found
+		for _, packageMeta := range versions {
+			if requirement.Satisfied(packageMeta.Version) {
+				requirementResult.Passed = true
+				requirementResult.Version = packageMeta.Version
+				requirementResult.Repository = packageMeta.Repository
+			}
+		}
+		if !requirementResult.Passed {
+			latestVersion := results.LatestVersion[requirement.Name]
+			requirementResult.Version = latestVersion.Version
+			requirementResult.Repository = latestVersion.Repository
+			requirementResult.Reason = "version"
+		}
+		requirementResults = append(requirementResults, requirementResult)
+	}
+	return requirementResults
+}
+
+// requirementTestCases returns a JUnit test case for each requirement
+func requirementTestCases(requirementResults []RequirementResult) []junitTestCase {
+	testCases := make([]junitTestCase, 0, len(requirementResults))
+	for _, requirementResult := range requirementResults {
+		testCase := junitTestCase{Name: requirementResult.Requirement, ClassName: "check"}
+		switch {
+		case requirementResult.Passed:
+		case requirementResult.Reason == "missing":
+			testCase.Failure = &junitMessage{Message: "not found in any repository", Type: requirementResult.Reason}
+		default:
+			testCase.Failure = &junitMessage{Message: fmt.Sprintf("latest version is %s in %s repository", requirementResult.Version, requirementResult.Repository), Type: requirementResult.Reason}
+		}
+		testCases = append(testCases, testCase)
+	}
+	return testCases
+}
+
+// requirementAnnotations returns a GitHub Actions error annotation for each requirement which is not met
+func requirementAnnotations(requirementResults []RequirementResult) []ghaAnnotation {
+	var annotations []ghaAnnotation
+	for _, requirementResult := range requirementResults {
+		switch {
+		case requirementResult.Passed:
+		case requirementResult.Reason == "missing":
+			annotations = append(annotations, ghaAnnotation{Level: "error", Title: "Requirement " + requirementResult.Requirement, Message: fmt.Sprintf("Requirement %s not met - not found in any repository", requirementResult.Requirement)})
+		default:
+			annotations = append(annotations, ghaAnnotation{Level: "error", Title: "Requirement " + requirementResult.Requirement, Message: fmt.Sprintf("Requirement %s not met - latest version is %s in %s repository", requirementResult.Requirement, requirementResult.Version, requirementResult.Repository)})
+		}
+	}
+	return annotations
+}
+
+// runCheck evaluates presence and version requirements, e.g. python-3.12>=3.12.4, specified with --require or as
+// arguments. It returns exitConstraintViolated when any requirement is not met, so it can gate release pipelines.
+func runCheck(args []string, requires []string, repositories []Repository, authToken string, outputFormat string) int {
+	var requirements []Constraint
+	for _, require := range append(requires, args...) {
+		requirement, err := parseConstraint(require)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid requirement: %v\n", err)
+			return exitUsage
+		}
+		requirement.Name = resolveAlias(requirement.Name)
+		requirements = append(requirements, requirement)
+	}
+	if len(requirements) == 0 {
+		fmt.Fprintf(stderr, "Usage: %s check --require PACKAGE[OPERATOR VERSION]... [PACKAGE[OPERATOR VERSION]...]\n", os.Args[0])
+		return exitUsage
+	}
+
+	requiredPackageNames := make(map[string]struct{})
+	for _, requirement := range requirements {
+		requiredPackageNames[requirement.Name] = struct{}{}
+	}
+	results := NewResults()
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if _, found := requiredPackageNames[pkg.Name]; found {
+			results.AddPackageMeta(pkg, apkRepository.Name, nil)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	requirementResults := checkRequirements(requirements, results)
+	failed := 0
+	for _, requirementResult := range requirementResults {
+		if !requirementResult.Passed {
+			failed++
+		}
+	}
+	switch outputFormat {
+	case "gha":
+		if err := writeGHA(stdout, requirementAnnotations(requirementResults)); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "junit":
+		if err := writeJUnit(stdout, "check", requirementTestCases(requirementResults)); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "json":
+		jsonOutput, err := json.MarshalIndent(requirementResults, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	default:
+		for _, requirementResult := range requirementResults {
+			switch {
+			case requirementResult.Passed:
+				fmt.Fprintf(stdout, "PASS %s - %s in %s repository\n", requirementResult.Requirement, requirementResult.Version, requirementResult.Repository)
+			case requirementResult.Reason == "missing":
+				fmt.Fprintf(stdout, "FAIL %s - not found in any repository\n", requirementResult.Requirement)
+			default:
+				fmt.Fprintf(stdout, "FAIL %s - latest version is %s in %s repository\n", requirementResult.Requirement, requirementResult.Version, requirementResult.Repository)
+			}
+		}
+		if failed > 0 {
+			fmt.Fprintf(stdout, "Check FAILED - %d of %d requirements not met\n", failed, len(requirementResults))
+		} else {
+			fmt.Fprintf(stdout, "Check passed - all %d requirements met\n", len(requirementResults))
+		}
+	}
+	if failed > 0 {
+		return exitConstraintViolated
+	}
+	return 0
+}
@@ -0,0 +1,97 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"regexp"
+
+	"gopkg.in/yaml.v3"
+)
+
+// Config is the configuration file, by default config.yaml in the wolfi-package-status directory of the user
+// configuration directory, e.g. ~/.config/wolfi-package-status/config.yaml
+type Config struct {
+	// Ignore are package names or regular expressions, matched against the whole package name, of packages which are
+	// always excluded from reports, e.g. .*-doc or .*-dbg sub packages or deprecated streams such as python-3.10.*
+	Ignore []string `yaml:"ignore"`
+	// Aliases map the names people think in to the package streams queried, e.g. python to python-3.13
+	Aliases map[string]string `yaml:"aliases"`
+	// Repositories are the IDs or names of the repositories queried when --repo is not specified, e.g. only wolfi so
+	// the non public repositories, and the auth token, are never needed
+	Repositories []string `yaml:"repositories"`
+	// Names map repository IDs or names to the names used in output, e.g. enterprise to cgr-private, so reports shared
+	// with stakeholders use meaningful labels
+	Names map[string]string `yaml:"names"`
+	// Orgs are cgr.dev organizations whose apk repositories are queried alongside the default repositories, as with
+	// --org
+	Orgs []string `yaml:"orgs"`
+	// Cache selects where downloaded apks and parsed indices are cached
+	Cache CacheConfig `yaml:"cache"`
+}
+
+// defaultConfigPath returns the path of the configuration file read when --config is not specified
+func defaultConfigPath() (string, error) {
+	directory, err := configDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(directory, "config.yaml"), nil
+}
+
+// loadConfig reads the configuration file at path. A missing file is only an error when the path was specified
+// explicitly, without a configuration file the defaults are used.
+func loadConfig(path string, explicit bool) (*Config, error) {
+	var config Config
+	content, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) && !explicit {
+		return &config, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// ignoredPackages are the patterns of packages excluded from reports, set from the configuration file and --ignore
+var ignoredPackages []*regexp.Regexp
+
+// setIgnoredPackages compiles the ignore patterns, each pattern must match the whole package name
+func setIgnoredPackages(patterns []string) error {
+	ignoredPackages = nil
+	for _, pattern := range patterns {
+		regex, err := regexp.Compile("^(?:" + pattern + ")$")
+		if err != nil {
+			return fmt.Errorf("invalid ignore pattern %q: %w", pattern, err)
+		}
+		ignoredPackages = append(ignoredPackages, regex)
+	}
+	return nil
+}
+
+// isIgnored reports whether the package is excluded from reports
+func isIgnored(packageName string) bool {
+	for _, regex := range ignoredPackages {
+		if regex.MatchString(packageName) {
+			return true
+		}
+	}
+	return false
+}
+
+// packageAliases map alias names to the package names queried instead, set from the configuration file
+var packageAliases map[string]string
+
+// resolveAlias returns the package name the alias stands for, or the name itself when it is not an alias
+func resolveAlias(packageName string) string {
+	if aliased, found := packageAliases[packageName]; found {
+		logVerbose("Resolved alias %s to %s", packageName, aliased)
+		return aliased
+	}
+	return packageName
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// constraintOperators are the apk version constraint operators, longest first so >= is found before >
+var constraintOperators = []string{">=", "<=", "=", ">", "<", "~"}
+
+// Constraint is an apk style package constraint such as python-3.12, python-3.12=3.12.5-r1 or python-3.12>=3.12
+type Constraint struct {
+	Name     string
+	Operator string
+	Version  string
+}
+
+// parseConstraint parses a constraint of the form NAME[OPERATOR VERSION]. The ~ operator matches versions starting with
+// the given version, as apk's fuzzy matching does.
+func parseConstraint(constraint string) (Constraint, error) {
+	for i := range constraint {
+		for _, operator := range constraintOperators {
+			if strings.HasPrefix(constraint[i:], operator) {
+				parsed := Constraint{Name: constraint[:i], Operator: operator, Version: constraint[i+len(operator):]}
+				if parsed.Name == "" || parsed.Version == "" {
+					return Constraint{}, fmt.Errorf("invalid constraint %q", constraint)
+				}
+				return parsed, nil
+			}
+		}
+	}
+	if constraint == "" {
+		return Constraint{}, fmt.Errorf("invalid empty constraint")
+	}
+	return Constraint{Name: constraint}, nil
+}
+
+// Satisfied reports whether the version satisfies the constraint
+func (c Constraint) Satisfied(packageVersion string) bool {
+	switch c.Operator {
+	case "":
+		return true
+	case "=":
+		return packageVersion == c.Version
+	case ">=":
+		return !versionGreaterThan(c.Version, packageVersion)
+	case "<=":
+		return !versionGreaterThan(packageVersion, c.Version)
+	case ">":
+		return versionGreaterThan(packageVersion, c.Version)
+	case "<":
+		return versionGreaterThan(c.Version, packageVersion)
+	case "~":
+		return packageVersion == c.Version || strings.HasPrefix(packageVersion, c.Version+".") || strings.HasPrefix(packageVersion, c.Version+"-")
+	}
+	return false
+}
+
+func (c Constraint) String() string {
+	return c.Name + c.Operator + c.Version
+}
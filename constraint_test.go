@@ -0,0 +1,62 @@
+package main
+
+import "testing"
+
+func TestParseConstraint(t *testing.T) {
+	tests := []struct {
+		constraint string
+		want       Constraint
+		wantErr    bool
+	}{
+		{constraint: "python-3.12", want: Constraint{Name: "python-3.12"}},
+		{constraint: "python-3.12=3.12.5-r1", want: Constraint{Name: "python-3.12", Operator: "=", Version: "3.12.5-r1"}},
+		{constraint: "python-3.12>=3.12", want: Constraint{Name: "python-3.12", Operator: ">=", Version: "3.12"}},
+		{constraint: "python-3.12<=3.12.5", want: Constraint{Name: "python-3.12", Operator: "<=", Version: "3.12.5"}},
+		{constraint: "python-3.12>3.12.4", want: Constraint{Name: "python-3.12", Operator: ">", Version: "3.12.4"}},
+		{constraint: "python-3.12<3.13", want: Constraint{Name: "python-3.12", Operator: "<", Version: "3.13"}},
+		{constraint: "python-3.12~3.12", want: Constraint{Name: "python-3.12", Operator: "~", Version: "3.12"}},
+		{constraint: "so:libc.so.6=6", want: Constraint{Name: "so:libc.so.6", Operator: "=", Version: "6"}},
+		{constraint: "", wantErr: true},
+		{constraint: "=3.12", wantErr: true},
+		{constraint: "python-3.12>=", wantErr: true},
+	}
+	for _, test := range tests {
+		got, err := parseConstraint(test.constraint)
+		if (err != nil) != test.wantErr {
+			t.Errorf("parseConstraint(%q) error = %v, want error %v", test.constraint, err, test.wantErr)
+			continue
+		}
+		if got != test.want {
+			t.Errorf("parseConstraint(%q) = %+v, want %+v", test.constraint, got, test.want)
+		}
+	}
+}
+
+func TestConstraintSatisfied(t *testing.T) {
+	tests := []struct {
+		constraint string
+		version    string
+		want       bool
+	}{
+		{constraint: "python-3.12", version: "3.12.5-r1", want: true},
+		{constraint: "python-3.12=3.12.5-r1", version: "3.12.5-r1", want: true},
+		{constraint: "python-3.12=3.12.5-r1", version: "3.12.5-r2", want: false},
+		{constraint: "python-3.12>=3.12.5", version: "3.12.5", want: true},
+		{constraint: "python-3.12>=3.12.5", version: "3.12.4-r9", want: false},
+		{constraint: "python-3.12<=3.12.5", version: "3.12.6", want: false},
+		{constraint: "python-3.12>3.12.5", version: "3.12.5", want: false},
+		{constraint: "python-3.12<3.13", version: "3.12.9-r0", want: true},
+		{constraint: "python-3.12~3.12", version: "3.12.5-r1", want: true},
+		{constraint: "python-3.12~3.12", version: "3.12", want: true},
+		{constraint: "python-3.12~3.1", version: "3.12.5-r1", want: false},
+	}
+	for _, test := range tests {
+		constraint, err := parseConstraint(test.constraint)
+		if err != nil {
+			t.Fatalf("parseConstraint(%q) error = %v", test.constraint, err)
+		}
+		if got := constraint.Satisfied(test.version); got != test.want {
+			t.Errorf("%s satisfied by %s = %v, want %v", test.constraint, test.version, got, test.want)
+		}
+	}
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// SharedLibraryReport lists the providers of a shared library soname and every package which depends on it
+type SharedLibraryReport struct {
+	Providers map[string]PackageMeta
+	Consumers map[string]PackageMeta
+}
+
+// dependsOn reports whether the package depends on the named dependency, e.g. so:libcrypto.so.3
+func dependsOn(pkg *repository.Package, dependencyName string) bool {
+	for _, dependency := range pkg.Dependencies {
+		if provideName(dependency) == dependencyName {
+			return true
+		}
+	}
+	return false
+}
+
+// runConsumers reports the packages providing a shared library soname and the packages depending on it, to help plan
+// rebuilds for ABI breaks. Only the latest version of each package is considered.
+func runConsumers(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s consumers so:SONAME\n", os.Args[0])
+		return exitUsage
+	}
+	soname := args[0]
+	// an older version of a package may have provided or depended on the soname, only the latest version matters
+	latestPackages := make(map[string]*repository.Package)
+	latestPackageRepositories := make(map[string]string)
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if latestPackage, found := latestPackages[pkg.Name]; !found || versionGreaterThan(pkg.Version, latestPackage.Version) {
+			latestPackages[pkg.Name] = pkg
+			latestPackageRepositories[pkg.Name] = apkRepository.Name
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	providers := NewResults()
+	consumers := NewResults()
+	sonameMatcher := providesMatcher{query: soname}
+	for packageName, pkg := range latestPackages {
+		if sonameMatcher.Matches(pkg) {
+			providers.AddPackageMeta(pkg, latestPackageRepositories[packageName], nil)
+		}
+		if dependsOn(pkg, soname) {
+			consumers.AddPackageMeta(pkg, latestPackageRepositories[packageName], nil)
+		}
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(SharedLibraryReport{Providers: providers.LatestVersion, Consumers: consumers.LatestVersion}, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	if len(providers.LatestVersion) == 0 {
+		fmt.Fprintf(stdout, "No packages provide %s\n", soname)
+	} else {
+		fmt.Fprintf(stdout, "Packages providing %s:\n", soname)
+		// providers are listed in order of preference, the one apk would install first
+		for i, packageName := range providers.sortedProviders(repositoryNames(repositories)) {
+			packageMeta := providers.LatestVersion[packageName]
+			chosen := ""
+			if i == 0 {
+				chosen = " - chosen by apk"
+			}
+			fmt.Fprintf(stdout, "\t%s %s in %s repository, provider priority %d%s\n", packageName, packageMeta.Version, packageMeta.Repository, packageMeta.ProviderPriority, chosen)
+		}
+	}
+	if len(consumers.LatestVersion) == 0 {
+		fmt.Fprintf(stdout, "No packages depend on %s\n", soname)
+	} else {
+		fmt.Fprintf(stdout, "Packages depending on %s:\n", soname)
+		for _, packageName := range consumers.sortedPackageNames() {
+			packageMeta := consumers.LatestVersion[packageName]
+			fmt.Fprintf(stdout, "\t%s %s in %s repository\n", packageName, packageMeta.Version, packageMeta.Repository)
+		}
+	}
+	return 0
+}
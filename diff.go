@@ -0,0 +1,116 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// Regression is a package whose version in a local APKINDEX is older than the version already published
+type Regression struct {
+	Name             string
+	LocalVersion     string
+	PublishedVersion string
+	Repository       string
+}
+
+// MissingPackage is a published package which is not in a local APKINDEX
+type MissingPackage struct {
+	Name             string
+	PublishedVersion string
+	Repository       string
+}
+
+// IndexDiff lists the differences of a local APKINDEX from the published repositories which would be lost by promoting it
+type IndexDiff struct {
+	Regressions []Regression
+	Missing     []MissingPackage
+}
+
+// publishedVersion is the latest version of a package across the repositories and the repository serving it
+type publishedVersion struct {
+	Version    string
+	Repository string
+}
+
+// diffIndex compares the latest version of each package of a local APKINDEX against the latest published version
+func diffIndex(local map[string]string, published map[string]publishedVersion) IndexDiff {
+	var diff IndexDiff
+	for packageName, latest := range published {
+		localVersion, found := local[packageName]
+		if !found {
+			diff.Missing = append(diff.Missing, MissingPackage{Name: packageName, PublishedVersion: latest.Version, Repository: latest.Repository})
+		} else if versionGreaterThan(latest.Version, localVersion) {
+			diff.Regressions = append(diff.Regressions, Regression{Name: packageName, LocalVersion: localVersion, PublishedVersion: latest.Version, Repository: latest.Repository})
+		}
+	}
+	sort.Slice(diff.Regressions, func(i, j int) bool {
+		return diff.Regressions[i].Name < diff.Regressions[j].Name
+	})
+	sort.Slice(diff.Missing, func(i, j int) bool {
+		return diff.Missing[i].Name < diff.Missing[j].Name
+	})
+	return diff
+}
+
+// runDiff validates a locally built APKINDEX.tar.gz, e.g. from a melange CI run, against the published repositories
+// before promotion. It returns a non zero exit code when the local index would regress the version of a package or
+// drop a package.
+func runDiff(args []string, localAPKINDEX string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 0 || localAPKINDEX == "" {
+		fmt.Fprintf(stderr, "Usage: %s diff --local APKINDEX.tar.gz [--against REPOSITORY]\n", os.Args[0])
+		return exitUsage
+	}
+	localIndex, err := fetchAPKINDEX(Repository{ID: "local-build", Name: "local build index", URL: localAPKINDEX}, "")
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load %s: %v\n", localAPKINDEX, err)
+		return exitFetchFailed
+	}
+	local := latestVersions(localIndex.Packages)
+
+	published := make(map[string]publishedVersion)
+	_, err = forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if latest, found := published[pkg.Name]; !found || versionGreaterThan(pkg.Version, latest.Version) {
+			published[pkg.Name] = publishedVersion{Version: pkg.Version, Repository: apkRepository.Name}
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	diff := diffIndex(local, published)
+	exitCode := 0
+	if len(diff.Regressions) > 0 || len(diff.Missing) > 0 {
+		exitCode = exitConstraintViolated
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return exitCode
+	}
+	if exitCode == 0 {
+		fmt.Fprintf(stdout, "%s has every published package at the same or a newer version\n", localAPKINDEX)
+		return 0
+	}
+	if len(diff.Regressions) > 0 {
+		fmt.Fprintf(stdout, "Packages older in %s than published:\n", localAPKINDEX)
+		for _, regression := range diff.Regressions {
+			fmt.Fprintf(stdout, "%s %s is older than %s in %s repository\n", regression.Name, regression.LocalVersion, regression.PublishedVersion, regression.Repository)
+		}
+	}
+	if len(diff.Missing) > 0 {
+		fmt.Fprintf(stdout, "Published packages missing from %s:\n", localAPKINDEX)
+		for _, missingPackage := range diff.Missing {
+			fmt.Fprintf(stdout, "%s (version %s in %s repository)\n", missingPackage.Name, missingPackage.PublishedVersion, missingPackage.Repository)
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,182 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+)
+
+// PackageVersion is a version of a package in a repository
+type PackageVersion struct {
+	Name       string
+	Version    string
+	Repository string
+}
+
+// JSONDiff is the changelog between two saved --json outputs
+type JSONDiff struct {
+	// AddedPackages are the versions of packages which were not in the old output at all
+	AddedPackages []PackageVersion
+	// RemovedPackages are the versions of packages which are not in the new output at all
+	RemovedPackages []PackageVersion
+	NewVersions     []PackageVersion
+	RemovedVersions []PackageVersion
+}
+
+// readJSONOutput reads the package versions of a saved --json output. The latest version, --all-versions and
+// --show-sub-packages tree outputs are all supported.
+func readJSONOutput(path string) (map[string][]PackageVersion, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var output map[string]json.RawMessage
+	if err := json.Unmarshal(content, &output); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	packageVersions := make(map[string][]PackageVersion)
+	addPackageMeta := func(packageName string, packageMeta PackageMeta) {
+		packageVersions[packageName] = append(packageVersions[packageName], PackageVersion{Name: packageName, Version: packageMeta.Version, Repository: packageMeta.Repository})
+	}
+	for packageName, value := range output {
+		// errors reported alongside partial results are not a package
+		if packageName == "errors" {
+			continue
+		}
+		var allVersions []PackageMeta
+		if err := json.Unmarshal(value, &allVersions); err == nil {
+			for _, packageMeta := range allVersions {
+				addPackageMeta(packageName, packageMeta)
+			}
+			continue
+		}
+		var tree struct {
+			Version     string
+			Repository  string
+			Latest      *PackageMeta
+			Versions    []PackageMeta
+			SubPackages map[string]json.RawMessage
+		}
+		if err := json.Unmarshal(value, &tree); err != nil {
+			return nil, fmt.Errorf("failed to parse package %s in %s: %w", packageName, path, err)
+		}
+		switch {
+		case tree.Version != "":
+			addPackageMeta(packageName, PackageMeta{Version: tree.Version, Repository: tree.Repository})
+		case tree.Latest != nil:
+			addPackageMeta(packageName, *tree.Latest)
+		default:
+			for _, packageMeta := range tree.Versions {
+				addPackageMeta(packageName, packageMeta)
+			}
+		}
+		for subPackageName, subPackageValue := range tree.SubPackages {
+			var subPackageVersions []PackageMeta
+			if err := json.Unmarshal(subPackageValue, &subPackageVersions); err != nil {
+				var subPackageLatest PackageMeta
+				if err := json.Unmarshal(subPackageValue, &subPackageLatest); err != nil {
+					return nil, fmt.Errorf("failed to parse sub package %s in %s: %w", subPackageName, path, err)
+				}
+				subPackageVersions = []PackageMeta{subPackageLatest}
+			}
+			for _, packageMeta := range subPackageVersions {
+				addPackageMeta(subPackageName, packageMeta)
+			}
+		}
+	}
+	return packageVersions, nil
+}
+
+// diffJSONOutputs returns the changelog from the old to the new package versions, sorted by name
+func diffJSONOutputs(previous map[string][]PackageVersion, current map[string][]PackageVersion) JSONDiff {
+	var diff JSONDiff
+	for packageName, currentVersions := range current {
+		previousVersions, found := previous[packageName]
+		if !found {
+			diff.AddedPackages = append(diff.AddedPackages, currentVersions...)
+			continue
+		}
+		diff.NewVersions = append(diff.NewVersions, versionsNotIn(currentVersions, previousVersions)...)
+	}
+	for packageName, previousVersions := range previous {
+		currentVersions, found := current[packageName]
+		if !found {
+			diff.RemovedPackages = append(diff.RemovedPackages, previousVersions...)
+			continue
+		}
+		diff.RemovedVersions = append(diff.RemovedVersions, versionsNotIn(previousVersions, currentVersions)...)
+	}
+	for _, packageVersions := range [][]PackageVersion{diff.AddedPackages, diff.RemovedPackages, diff.NewVersions, diff.RemovedVersions} {
+		sort.Slice(packageVersions, func(i, j int) bool {
+			if packageVersions[i].Name != packageVersions[j].Name {
+				return packageVersions[i].Name < packageVersions[j].Name
+			}
+			return versionGreaterThan(packageVersions[j].Version, packageVersions[i].Version)
+		})
+	}
+	return diff
+}
+
+// versionsNotIn returns the package versions which are not in others
+func versionsNotIn(packageVersions []PackageVersion, others []PackageVersion) []PackageVersion {
+	var notIn []PackageVersion
+	for _, packageVersion := range packageVersions {
+		found := false
+		for _, other := range others {
+			if other == packageVersion {
+				found = true
+				break
+			}
+		}
+		if !found {
+			notIn = append(notIn, packageVersion)
+		}
+	}
+	return notIn
+}
+
+// runDiffJSON compares two saved --json outputs and prints the changelog between them
+func runDiffJSON(args []string, asJSON bool) int {
+	if len(args) != 2 {
+		fmt.Fprintf(stderr, "Usage: %s diff-json OLD.json NEW.json\n", os.Args[0])
+		return exitUsage
+	}
+	previous, err := readJSONOutput(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", args[0], err)
+		return exitUsage
+	}
+	current, err := readJSONOutput(args[1])
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", args[1], err)
+		return exitUsage
+	}
+
+	diff := diffJSONOutputs(previous, current)
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(diff, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	for _, added := range diff.AddedPackages {
+		fmt.Fprintf(stdout, "Added package %s %s in %s repository\n", added.Name, added.Version, added.Repository)
+	}
+	for _, newVersion := range diff.NewVersions {
+		fmt.Fprintf(stdout, "New version %s of package %s in %s repository\n", newVersion.Version, newVersion.Name, newVersion.Repository)
+	}
+	for _, removedVersion := range diff.RemovedVersions {
+		fmt.Fprintf(stdout, "Removed version %s of package %s from %s repository\n", removedVersion.Version, removedVersion.Name, removedVersion.Repository)
+	}
+	for _, removedPackage := range diff.RemovedPackages {
+		fmt.Fprintf(stdout, "Removed package %s %s from %s repository\n", removedPackage.Name, removedPackage.Version, removedPackage.Repository)
+	}
+	if len(diff.AddedPackages)+len(diff.RemovedPackages)+len(diff.NewVersions)+len(diff.RemovedVersions) == 0 {
+		fmt.Fprintln(stdout, "No changes")
+	}
+	return 0
+}
@@ -0,0 +1,183 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+// DiscoveredRepository is the apk repository of a cgr.dev organization the current chainctl identity belongs to
+type DiscoveredRepository struct {
+	Org string
+	URL string
+	// Configured is set when the repository is already queried, from the configuration file or --org
+	Configured bool
+	// Accessible is whether the auth token grants access to the repository, only set when there is a token to check
+	Accessible *bool `json:",omitempty"`
+}
+
+// chainctlOrganizations lists the organizations the current chainctl identity can access
+func chainctlOrganizations() ([]string, error) {
+	output, err := exec.Command("chainctl", "iam", "organizations", "list", "-o", "json").Output()
+	if err != nil {
+		var exitError *exec.ExitError
+		if errors.As(err, &exitError) && len(exitError.Stderr) > 0 {
+			return nil, fmt.Errorf("chainctl failed: %s", strings.TrimSpace(string(exitError.Stderr)))
+		}
+		return nil, fmt.Errorf("failed to run chainctl: %w", err)
+	}
+	var organizations struct {
+		Items []struct {
+			Name string `json:"name"`
+		} `json:"items"`
+	}
+	if err := json.Unmarshal(output, &organizations); err != nil {
+		return nil, fmt.Errorf("failed to parse chainctl output: %w", err)
+	}
+	var orgs []string
+	for _, item := range organizations.Items {
+		if item.Name != "" {
+			orgs = append(orgs, item.Name)
+		}
+	}
+	return orgs, nil
+}
+
+// addConfigOrgs adds the organizations to the orgs of the configuration file at configPath, creating the file when it
+// does not exist. The rest of the file, including comments, is kept as is.
+func addConfigOrgs(configPath string, orgs []string) error {
+	var document yaml.Node
+	content, err := os.ReadFile(configPath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+	if err := yaml.Unmarshal(content, &document); err != nil {
+		return fmt.Errorf("failed to parse %s: %w", configPath, err)
+	}
+	if document.Kind == 0 {
+		document = yaml.Node{Kind: yaml.DocumentNode, Content: []*yaml.Node{{Kind: yaml.MappingNode}}}
+	}
+	root := document.Content[0]
+	if root.Kind != yaml.MappingNode {
+		return fmt.Errorf("%s is not a mapping", configPath)
+	}
+	var orgsNode *yaml.Node
+	for i := 0; i+1 < len(root.Content); i += 2 {
+		if root.Content[i].Value == "orgs" {
+			orgsNode = root.Content[i+1]
+		}
+	}
+	if orgsNode == nil {
+		orgsNode = &yaml.Node{Kind: yaml.SequenceNode}
+		root.Content = append(root.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: "orgs"}, orgsNode)
+	}
+	if orgsNode.Kind != yaml.SequenceNode {
+		return fmt.Errorf("orgs in %s is not a list", configPath)
+	}
+	for _, org := range orgs {
+		orgsNode.Content = append(orgsNode.Content, &yaml.Node{Kind: yaml.ScalarNode, Value: org})
+	}
+	var updated bytes.Buffer
+	encoder := yaml.NewEncoder(&updated)
+	encoder.SetIndent(2)
+	if err := encoder.Encode(&document); err != nil {
+		return err
+	}
+	if err := os.MkdirAll(filepath.Dir(configPath), 0o755); err != nil {
+		return err
+	}
+	return os.WriteFile(configPath, updated.Bytes(), 0o644)
+}
+
+// runReposDiscover enumerates, with chainctl, the apk repositories of the organizations the current identity can access
+// and offers to add those not already queried to the configuration file. The offer is only made on a terminal.
+func runReposDiscover(repositories []Repository, authToken string, configPath string, asJSON bool) int {
+	orgs, err := chainctlOrganizations()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to discover repositories: %v\n", err)
+		return exitFetchFailed
+	}
+	arch := "x86_64"
+	configuredURLs := make(map[string]struct{})
+	for _, apkRepository := range repositories {
+		configuredURLs[apkRepository.URL] = struct{}{}
+		if apkRepository.RequiresAuth && apkRepository.Arch != "" {
+			arch = apkRepository.Arch
+		}
+	}
+	discovered := []DiscoveredRepository{}
+	var newOrgs []string
+	organizationRepositories, err := orgRepositories(orgs, arch)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to discover repositories: %v\n", err)
+		return exitUsage
+	}
+	for i, apkRepository := range organizationRepositories {
+		repositoryFound := DiscoveredRepository{Org: orgs[i], URL: apkRepository.URL}
+		_, repositoryFound.Configured = configuredURLs[apkRepository.URL]
+		if authToken != "" {
+			access := checkAccess(apkRepository, authToken)
+			repositoryFound.Accessible = &access.Accessible
+		}
+		if !repositoryFound.Configured {
+			newOrgs = append(newOrgs, orgs[i])
+		}
+		discovered = append(discovered, repositoryFound)
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(discovered, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	if len(discovered) == 0 {
+		fmt.Fprintln(stdout, "No organizations found for the current chainctl identity")
+		return 0
+	}
+	for _, repositoryFound := range discovered {
+		var details []string
+		if repositoryFound.Configured {
+			details = append(details, "configured")
+		}
+		if repositoryFound.Accessible != nil && *repositoryFound.Accessible {
+			details = append(details, "accessible")
+		} else if repositoryFound.Accessible != nil {
+			details = append(details, "not accessible with the auth token")
+		}
+		if len(details) > 0 {
+			fmt.Fprintf(stdout, "%s %s - %s\n", repositoryFound.Org, repositoryFound.URL, strings.Join(details, ", "))
+		} else {
+			fmt.Fprintf(stdout, "%s %s\n", repositoryFound.Org, repositoryFound.URL)
+		}
+	}
+	if len(newOrgs) == 0 {
+		return 0
+	}
+	if !isTerminal(os.Stdin.Fd()) {
+		fmt.Fprintf(stdout, "Add them to orgs in %s, or query them with --org\n", configPath)
+		return 0
+	}
+	fmt.Fprintf(stdout, "Add %s to orgs in %s? [y/N] ", strings.Join(newOrgs, ", "), configPath)
+	answer, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	if answer = strings.ToLower(strings.TrimSpace(answer)); answer != "y" && answer != "yes" {
+		return 0
+	}
+	if err := addConfigOrgs(configPath, newOrgs); err != nil {
+		fmt.Fprintf(stderr, "Failed to update %s: %v\n", configPath, err)
+		return exitUsage
+	}
+	fmt.Fprintf(stdout, "Added %s to %s\n", strings.Join(newOrgs, ", "), configPath)
+	return 0
+}
@@ -0,0 +1,155 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// DryRunRepository is a repository whose packages would be read and how
+type DryRunRepository struct {
+	ID   string
+	Name string
+	Arch string `json:",omitempty"`
+	// URLs are the locations the APKINDEX would be fetched from, the repository URL then its mirrors in order
+	URLs []string
+	// Local is set when the packages would be read from a local APKINDEX or directory of .apk files
+	Local bool `json:",omitempty"`
+	// Auth is where the auth token would be taken from, empty for public and local repositories
+	Auth string `json:",omitempty"`
+}
+
+// DryRunMatcher is how a query would be matched against the packages
+type DryRunMatcher struct {
+	Query string
+	// Kind is the matcher kind, e.g. exact, regex or glob
+	Kind    string
+	Pattern string
+	// Field is the package field matched, name or origin
+	Field string
+}
+
+// DryRun is what a query would do, without fetching anything
+type DryRun struct {
+	Repositories []DryRunRepository
+	// Command is the command which would be run, empty when packages are queried
+	Command string `json:",omitempty"`
+	// Matchers are the matchers applied to the packages when packages are queried, none when every package is listed
+	Matchers []DryRunMatcher `json:",omitempty"`
+}
+
+// newDryRun describes the repositories which would be fetched, with the auth token from credentialsSource, and the
+// matchers of the package names, or the command which would be run. An unknown auth token source means the token would
+// be prompted for.
+func newDryRun(repositories []Repository, command string, packageNames []string, matchAsRegex bool, matchOrigin bool, originFilter string, credentialsSource string) DryRun {
+	dryRun := DryRun{Command: command}
+	for _, apkRepository := range repositories {
+		dryRunRepository := DryRunRepository{ID: apkRepository.ID, Name: apkRepository.Name, Arch: apkRepository.Arch}
+		if apkRepository.PackagesDir != "" {
+			dryRunRepository.URLs = []string{apkRepository.PackagesDir}
+			dryRunRepository.Local = true
+		} else if _, err := os.Stat(apkRepository.URL); err == nil {
+			dryRunRepository.URLs = []string{apkRepository.URL}
+			dryRunRepository.Local = true
+		} else {
+			dryRunRepository.URLs = apkRepository.URLs()
+		}
+		if apkRepository.RequiresAuth && !dryRunRepository.Local {
+			dryRunRepository.Auth = credentialsSource
+			if dryRunRepository.Auth == "" {
+				dryRunRepository.Auth = "prompt"
+			}
+		}
+		dryRun.Repositories = append(dryRun.Repositories, dryRunRepository)
+	}
+	if command != "" {
+		return dryRun
+	}
+	describe := func(query string, field string) DryRunMatcher {
+		kind, pattern := queryMatcherKind(query, queryDefaultKind(matchAsRegex))
+		// queries which are not valid regular expressions are matched on exact package name
+		if kind.Name == "regex" && !isValidRegex(pattern) {
+			return DryRunMatcher{Query: query, Kind: "exact", Pattern: query, Field: field}
+		}
+		return DryRunMatcher{Query: query, Kind: kind.Name, Pattern: pattern, Field: field}
+	}
+	field := "name"
+	if matchOrigin {
+		field = "origin"
+	}
+	for _, packageName := range removeDuplicates(packageNames) {
+		dryRun.Matchers = append(dryRun.Matchers, describe(packageName, field))
+	}
+	if originFilter != "" {
+		dryRun.Matchers = append(dryRun.Matchers, describe(originFilter, "origin"))
+	}
+	return dryRun
+}
+
+// printDryRun prints what a query would do, as JSON when asJSON is set
+func printDryRun(dryRun DryRun, asJSON bool) int {
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(dryRun, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	fmt.Fprintln(stdout, "Repositories which would be queried:")
+	if len(dryRun.Repositories) == 0 {
+		fmt.Fprintln(stdout, "\tnone")
+	}
+	for _, dryRunRepository := range dryRun.Repositories {
+		var details []string
+		if dryRunRepository.Arch != "" {
+			details = append(details, dryRunRepository.Arch)
+		}
+		switch {
+		case dryRunRepository.Local:
+			details = append(details, "local")
+		case dryRunRepository.Auth == "prompt":
+			details = append(details, "requires auth, the token would be prompted for")
+		case dryRunRepository.Auth != "":
+			details = append(details, "requires auth, token from "+dryRunRepository.Auth)
+		default:
+			details = append(details, "public")
+		}
+		fmt.Fprintf(stdout, "\t%s (%s repository) - %s\n", dryRunRepository.ID, dryRunRepository.Name, strings.Join(details, ", "))
+		for i, URL := range dryRunRepository.URLs {
+			if i == 0 {
+				fmt.Fprintf(stdout, "\t\t%s\n", URL)
+			} else {
+				fmt.Fprintf(stdout, "\t\tmirror %s\n", URL)
+			}
+		}
+	}
+	if dryRun.Command != "" {
+		fmt.Fprintf(stdout, "Command which would be run: %s\n", dryRun.Command)
+		return 0
+	}
+	if len(dryRun.Matchers) == 0 {
+		fmt.Fprintln(stdout, "No package names specified - every package would be listed")
+		return 0
+	}
+	fmt.Fprintln(stdout, "Matchers which would be applied:")
+	for _, matcher := range dryRun.Matchers {
+		fmt.Fprintf(stdout, "\t%s - %s match of %s %s\n", matcher.Query, matcher.Kind, matcher.Field, matcher.Pattern)
+	}
+	return 0
+}
+
+// commands are the commands which query the repositories, any other first argument is a package name
+var commands = map[string]struct{}{
+	"advisories": {}, "arch-skew": {}, "arches": {}, "auth": {}, "check": {}, "consumers": {}, "diff": {}, "dump": {},
+	"files": {}, "info": {}, "latest": {}, "lock": {}, "origins": {}, "outdated": {}, "pkginfo": {}, "proxy": {},
+	"removed": {}, "repos": {}, "serve": {}, "skew": {}, "streams": {}, "track": {}, "verify": {}, "watch": {},
+}
+
+// isCommand reports whether the argument is the name of a command which queries the repositories
+func isCommand(argument string) bool {
+	_, found := commands[argument]
+	return found
+}
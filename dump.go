@@ -0,0 +1,144 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/csv"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// DumpOptions are the options of the dump command, exactly one output format must be selected
+type DumpOptions struct {
+	Parquet bool
+	CSV     bool
+}
+
+// PackageRecord is every field of a package in an APKINDEX, together with the repository it is in
+type PackageRecord struct {
+	Repository       string   `parquet:"name=repository, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Name             string   `parquet:"name=name, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Version          string   `parquet:"name=version, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Arch             string   `parquet:"name=arch, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Origin           string   `parquet:"name=origin, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Description      string   `parquet:"name=description, type=BYTE_ARRAY, convertedtype=UTF8"`
+	URL              string   `parquet:"name=url, type=BYTE_ARRAY, convertedtype=UTF8"`
+	License          string   `parquet:"name=license, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Maintainer       string   `parquet:"name=maintainer, type=BYTE_ARRAY, convertedtype=UTF8"`
+	RepoCommit       string   `parquet:"name=repo_commit, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Checksum         string   `parquet:"name=checksum, type=BYTE_ARRAY, convertedtype=UTF8"`
+	Size             int64    `parquet:"name=size, type=INT64"`
+	InstalledSize    int64    `parquet:"name=installed_size, type=INT64"`
+	ProviderPriority int64    `parquet:"name=provider_priority, type=INT64"`
+	BuildTime        int64    `parquet:"name=build_time, type=INT64, convertedtype=TIMESTAMP_MILLIS"`
+	Dependencies     []string `parquet:"name=dependencies, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	Provides         []string `parquet:"name=provides, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+	InstallIf        []string `parquet:"name=install_if, type=LIST, valuetype=BYTE_ARRAY, valueconvertedtype=UTF8"`
+}
+
+// newPackageRecord returns the record of a package in a repository
+func newPackageRecord(apkRepository Repository, pkg *repository.Package) PackageRecord {
+	return PackageRecord{
+		Repository:       apkRepository.Name,
+		Name:             pkg.Name,
+		Version:          pkg.Version,
+		Arch:             pkg.Arch,
+		Origin:           pkg.Origin,
+		Description:      pkg.Description,
+		URL:              pkg.URL,
+		License:          pkg.License,
+		Maintainer:       pkg.Maintainer,
+		RepoCommit:       pkg.RepoCommit,
+		Checksum:         "Q1" + base64.StdEncoding.EncodeToString(pkg.Checksum),
+		Size:             int64(pkg.Size),
+		InstalledSize:    int64(pkg.InstalledSize),
+		ProviderPriority: int64(pkg.ProviderPriority),
+		BuildTime:        pkg.BuildTime.UnixMilli(),
+		Dependencies:     pkg.Dependencies,
+		Provides:         pkg.Provides,
+		InstallIf:        pkg.InstallIf,
+	}
+}
+
+// csvHeader are the columns of the CSV dump, named like the parquet columns
+var csvHeader = []string{"repository", "name", "version", "arch", "origin", "description", "url", "license", "maintainer", "repo_commit", "checksum", "size", "installed_size", "provider_priority", "build_time", "dependencies", "provides", "install_if"}
+
+// writeCSV writes the records as CSV with a header row. List fields are space separated, as in the APKINDEX.
+func writeCSV(w io.Writer, records []PackageRecord) error {
+	csvWriter := csv.NewWriter(w)
+	if err := csvWriter.Write(csvHeader); err != nil {
+		return err
+	}
+	for _, record := range records {
+		row := []string{
+			record.Repository,
+			record.Name,
+			record.Version,
+			record.Arch,
+			record.Origin,
+			record.Description,
+			record.URL,
+			record.License,
+			record.Maintainer,
+			record.RepoCommit,
+			record.Checksum,
+			strconv.FormatInt(record.Size, 10),
+			strconv.FormatInt(record.InstalledSize, 10),
+			strconv.FormatInt(record.ProviderPriority, 10),
+			time.UnixMilli(record.BuildTime).UTC().Format(time.RFC3339),
+			strings.Join(record.Dependencies, " "),
+			strings.Join(record.Provides, " "),
+			strings.Join(record.InstallIf, " "),
+		}
+		if err := csvWriter.Write(row); err != nil {
+			return err
+		}
+	}
+	csvWriter.Flush()
+	return csvWriter.Error()
+}
+
+// runDump writes every package of the repositories, with all of its metadata, to a file or stdout. No query is needed,
+// it is meant for offline analysis and archival snapshots of the indices.
+func runDump(args []string, repositories []Repository, authToken string, options DumpOptions) int {
+	if len(args) > 1 || options.Parquet == options.CSV {
+		fmt.Fprintf(stderr, "Usage: %s dump --csv|--parquet [FILE]\n", os.Args[0])
+		return exitUsage
+	}
+	var records []PackageRecord
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		records = append(records, newPackageRecord(apkRepository, pkg))
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	output := stdout
+	if len(args) == 1 {
+		outputFile, err := os.Create(args[0])
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to create %s: %v\n", args[0], err)
+			return exitUsage
+		}
+		defer outputFile.Close()
+		output = outputFile
+	}
+	if options.CSV {
+		if err := writeCSV(output, records); err != nil {
+			fmt.Fprintf(stderr, "Failed to write CSV: %v\n", err)
+			return exitUsage
+		}
+		return 0
+	}
+	if err := writeParquet(output, records); err != nil {
+		fmt.Fprintf(stderr, "Failed to write parquet: %v\n", err)
+		return exitUsage
+	}
+	return 0
+}
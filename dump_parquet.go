@@ -0,0 +1,25 @@
+//go:build !js
+
+package main
+
+import (
+	"io"
+
+	"github.com/xitongsys/parquet-go/parquet"
+	"github.com/xitongsys/parquet-go/writer"
+)
+
+// writeParquet writes the records as a snappy compressed parquet file
+func writeParquet(w io.Writer, records []PackageRecord) error {
+	parquetWriter, err := writer.NewParquetWriterFromWriter(w, new(PackageRecord), 4)
+	if err != nil {
+		return err
+	}
+	parquetWriter.CompressionType = parquet.CompressionCodec_SNAPPY
+	for _, record := range records {
+		if err := parquetWriter.Write(record); err != nil {
+			return err
+		}
+	}
+	return parquetWriter.WriteStop()
+}
@@ -0,0 +1,13 @@
+//go:build js
+
+package main
+
+import (
+	"errors"
+	"io"
+)
+
+// writeParquet is not available in the browser, the parquet writer depends on network sockets
+func writeParquet(w io.Writer, records []PackageRecord) error {
+	return errors.New("parquet output is not supported in the browser")
+}
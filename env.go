@@ -0,0 +1,76 @@
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// envPrefix is the prefix of the environment variables mirroring the flags
+const envPrefix = "WOLFI_PACKAGE_STATUS_"
+
+// flagEnvName returns the environment variable mirroring a flag, e.g. WOLFI_PACKAGE_STATUS_ALL_VERSIONS for
+// --all-versions
+func flagEnvName(flagName string) string {
+	return envPrefix + strings.ToUpper(strings.ReplaceAll(flagName, "-", "_"))
+}
+
+// envSliceFlag is a flag which can be specified multiple times whose values were set from its environment variable. The
+// values are replaced, rather than appended to, when the flag is first specified on the command line.
+type envSliceFlag struct {
+	values  *stringSliceFlag
+	fromEnv bool
+}
+
+func (s *envSliceFlag) String() string {
+	if s.values == nil {
+		return ""
+	}
+	return s.values.String()
+}
+
+func (s *envSliceFlag) Set(value string) error {
+	if s.fromEnv {
+		*s.values = nil
+		s.fromEnv = false
+	}
+	return s.values.Set(value)
+}
+
+// setFlagsFromEnv sets each flag whose environment variable is set, so containerized CI jobs can configure the tool
+// without wrapper scripts. Flags which can be specified multiple times take a comma separated list. It must be called
+// before the command line is parsed so flags specified on the command line override the environment, replacing all
+// the values of a flag which can be specified multiple times.
+func setFlagsFromEnv(flagSet *flag.FlagSet) error {
+	var err error
+	// flags which share their values, such as --repo and --against, share one wrapper so the values set from the
+	// environment are replaced once rather than by each of the flags
+	envSliceFlags := make(map[*stringSliceFlag]*envSliceFlag)
+	flagSet.VisitAll(func(f *flag.Flag) {
+		value, found := os.LookupEnv(flagEnvName(f.Name))
+		if !found || err != nil {
+			return
+		}
+		values := []string{value}
+		sliceFlag, isSlice := f.Value.(*stringSliceFlag)
+		if isSlice {
+			values = strings.Split(value, ",")
+		}
+		for _, value := range values {
+			if setErr := f.Value.Set(strings.TrimSpace(value)); setErr != nil {
+				err = fmt.Errorf("%s: %w", flagEnvName(f.Name), setErr)
+				return
+			}
+		}
+		if isSlice {
+			envSliceFlags[sliceFlag] = &envSliceFlag{values: sliceFlag, fromEnv: true}
+		}
+	})
+	flagSet.VisitAll(func(f *flag.Flag) {
+		if sliceFlag, isSlice := f.Value.(*stringSliceFlag); isSlice && envSliceFlags[sliceFlag] != nil {
+			f.Value = envSliceFlags[sliceFlag]
+		}
+	})
+	return err
+}
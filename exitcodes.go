@@ -0,0 +1,16 @@
+package main
+
+// The exit codes are a stable contract for scripts and CI, each kind of failure has its own exit code
+const (
+	// exitUsage is returned for invalid usage, such as unknown options or malformed input files, and other errors
+	exitUsage = 1
+	// exitFetchFailed is returned when a repository, package or security database cannot be downloaded, e.g. because
+	// of a network failure or an invalid auth token
+	exitFetchFailed = 2
+	// exitNoMatches is returned when no package matches the queries or a named package does not exist
+	exitNoMatches = 3
+	// exitConstraintViolated is returned when packages are checked against the repositories and fail the check - a
+	// requirement or constraint is not met, a package is outdated, stale, affected by a vulnerability, has drifted from
+	// a lock file or its checksum does not match, or with --strict a query matched more packages than --max-matches
+	exitConstraintViolated = 4
+)
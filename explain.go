@@ -0,0 +1,88 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+)
+
+// QueryMatch is a query which matched a package and how
+type QueryMatch struct {
+	Query string
+	// Kind is the matcher kind of the query, e.g. exact, regex or provides
+	Kind string
+	// MatchedOn is what the query matched - the package name, its origin package, one of its virtual provides or, when
+	// the package was included as the parent of a matching package, a sub package
+	MatchedOn string
+}
+
+// Explanation is why a package was included in the results
+type Explanation struct {
+	Matches []QueryMatch
+	// FirstSeenIn is the repository the package was first found in, repositories are read in the order they are queried
+	FirstSeenIn string
+}
+
+// explanations records, by package name, why each package was included in the results
+type explanations map[string]*Explanation
+
+// record notes that the queries matched the package in the named repository on matchedOn - name, origin or sub package.
+// Queries of virtual provides are recorded as matching on provides.
+func (e explanations) record(packageName string, repositoryName string, matchedOn string, queries []string, matchAsRegex bool) {
+	explanation, found := e[packageName]
+	if !found {
+		explanation = &Explanation{FirstSeenIn: repositoryName}
+		e[packageName] = explanation
+	}
+	for _, query := range queries {
+		kind, pattern := queryMatcherKind(query, queryDefaultKind(matchAsRegex))
+		kindName := kind.Name
+		// queries which are not valid regular expressions are matched on exact package name
+		if kindName == "regex" && !isValidRegex(pattern) {
+			kindName = "exact"
+		}
+		queryMatchedOn := matchedOn
+		if kindName == "provides" && matchedOn == "name" {
+			queryMatchedOn = "provides"
+		}
+		queryMatch := QueryMatch{Query: query, Kind: kindName, MatchedOn: queryMatchedOn}
+		known := false
+		for _, match := range explanation.Matches {
+			known = known || match == queryMatch
+		}
+		if !known {
+			explanation.Matches = append(explanation.Matches, queryMatch)
+		}
+	}
+}
+
+// SetExplanations records on each version of the packages in the results, and their sub packages, why the package was
+// included
+func (r *Results) SetExplanations(explanations explanations) {
+	for _, results := range []*Results{r, r.SubPackages} {
+		for packageName, versions := range results.AllVersions {
+			explanation, found := explanations[packageName]
+			if !found {
+				continue
+			}
+			for i := range versions {
+				versions[i].Explanation = explanation
+			}
+			latestVersion := results.LatestVersion[packageName]
+			latestVersion.Explanation = explanation
+			results.LatestVersion[packageName] = latestVersion
+		}
+	}
+}
+
+// explanationAnnotation renders why a package was included, e.g. ` - Included because "re:^py" (regex) matched name,
+// first seen in wolfi os repository`
+func explanationAnnotation(explanation *Explanation) string {
+	var reasons []string
+	for _, match := range explanation.Matches {
+		reasons = append(reasons, fmt.Sprintf("%q (%s) matched %s", match.Query, match.Kind, match.MatchedOn))
+	}
+	if len(reasons) == 0 {
+		return " - Included, first seen in " + explanation.FirstSeenIn + " repository"
+	}
+	return " - Included because " + strings.Join(reasons, ", ") + ", first seen in " + explanation.FirstSeenIn + " repository"
+}
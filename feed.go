@@ -0,0 +1,76 @@
+package main
+
+import (
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+)
+
+// atomFeed is an Atom (RFC 4287) feed of package changes, which Slack and most feed readers can subscribe to
+type atomFeed struct {
+	XMLName xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	Title   string      `xml:"title"`
+	ID      string      `xml:"id"`
+	Updated string      `xml:"updated"`
+	Link    atomLink    `xml:"link"`
+	Entries []atomEntry `xml:"entry"`
+}
+
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+type atomAuthor struct {
+	Name string `xml:"name"`
+}
+
+type atomEntry struct {
+	Title   string     `xml:"title"`
+	ID      string     `xml:"id"`
+	Updated string     `xml:"updated"`
+	Author  atomAuthor `xml:"author"`
+	Summary string     `xml:"summary"`
+}
+
+// newAtomFeed builds the feed of the changes, newest first. The feed is updated when the newest change was detected.
+func newAtomFeed(feedURL string, changes []PackageChange) atomFeed {
+	feed := atomFeed{
+		Title:   "wolfi-package-status package changes",
+		ID:      feedURL,
+		Updated: time.Unix(0, 0).UTC().Format(time.RFC3339),
+		Link:    atomLink{Href: feedURL, Rel: "self"},
+	}
+	if len(changes) > 0 {
+		feed.Updated = changes[0].DetectedAt.UTC().Format(time.RFC3339)
+	}
+	for _, change := range changes {
+		feed.Entries = append(feed.Entries, atomEntry{
+			Title: fmt.Sprintf("%s %s", change.Name, change.Version),
+			// the entry ID must be stable across requests so readers do not show the same change twice
+			ID:      fmt.Sprintf("tag:wolfi-package-status,%s:%s/%s=%s", change.DetectedAt.UTC().Format("2006-01-02"), url.PathEscape(change.Repository), change.Name, change.Version),
+			Updated: change.DetectedAt.UTC().Format(time.RFC3339),
+			Author:  atomAuthor{Name: change.Repository},
+			Summary: fmt.Sprintf("New version %s of package %s (origin %s) in %s repository, built %s", change.Version, change.Name, change.Origin, change.Repository, change.BuildTime.UTC().Format(time.RFC3339)),
+		})
+	}
+	return feed
+}
+
+// feedHandler serves the Atom feed of the changes. The feed can be filtered with one or more q query parameters, which
+// are matched like the package names of the command line, e.g. /feed.atom?q=python-3.12&q=cmd:python3
+func feedHandler(changes *changeLog, matchAsRegex bool) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		feedURL := "http://" + r.Host + r.URL.RequestURI()
+		feed := newAtomFeed(feedURL, changes.recent(newRemoteMatchers(r.URL.Query()["q"], matchAsRegex)))
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		_, _ = w.Write([]byte(xml.Header))
+		encoder := xml.NewEncoder(w)
+		encoder.Indent("", "  ")
+		if err := encoder.Encode(feed); err != nil {
+			logVerbose("Failed to write the Atom feed: %v", err)
+		}
+	})
+}
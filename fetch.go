@@ -0,0 +1,357 @@
+package main
+
+import (
+	"context"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"runtime"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+	"golang.org/x/sync/errgroup"
+)
+
+// fetchAPKINDEX returns the parsed APKINDEX of the repository, downloading it first when the repository URL is not a
+// local file. When the download fails each of the repository mirrors is tried in turn.
+func fetchAPKINDEX(apkRepository Repository, authToken string) (*repository.ApkIndex, error) {
+	if apkRepository.PackagesDir != "" {
+		apkIndex := &repository.ApkIndex{}
+		err := streamLocalPackages(apkRepository.PackagesDir, func(pkg *repository.Package) {
+			apkIndex.Packages = append(apkIndex.Packages, pkg)
+		})
+		return apkIndex, err
+	}
+	localAPKINDEXPath, cleanup, err := downloadAPKINDEX(apkRepository, authToken)
+	if err != nil {
+		return nil, err
+	}
+	defer cleanup()
+	return parseAPKINDEX(apkRepository, localAPKINDEXPath)
+}
+
+// downloadAPKINDEX downloads the APKINDEX of the repository to a temporary file, unless the repository URL is a local
+// file, and returns its path. cleanup removes the temporary file.
+func downloadAPKINDEX(apkRepository Repository, authToken string) (string, func(), error) {
+	localAPKINDEXPath, _, cleanup, err := downloadModifiedAPKINDEX(apkRepository, authToken, nil)
+	return localAPKINDEXPath, cleanup, err
+}
+
+// downloadModifiedAPKINDEX is downloadAPKINDEX sending a conditional request, when the validators of a previous download
+// are known, to the URL it was downloaded from. errNotModified is returned when the APKINDEX has not changed since,
+// otherwise the validators of the new download are returned along with its path.
+func downloadModifiedAPKINDEX(apkRepository Repository, authToken string, previous *indexValidators) (string, indexValidators, func(), error) {
+	// check to see of the repository URL is a local file
+	if _, err := os.Stat(apkRepository.URL); err == nil {
+		return apkRepository.URL, indexValidators{}, func() {}, nil
+	}
+	// Download the APKINDEX file to a temporary directory
+	temporaryAPKINDEXdir, err := os.MkdirTemp("", "wolfi-package-status")
+	if err != nil {
+		return "", indexValidators{}, nil, fmt.Errorf("failed to create temporary directory: %w", err)
+	}
+	cleanup := func() { os.RemoveAll(temporaryAPKINDEXdir) }
+
+	localAPKINDEXPath := filepath.Join(temporaryAPKINDEXdir, "APKINDEX.tar.gz")
+	var validators indexValidators
+	var downloadErrors []error
+	for _, APKINDEXurl := range apkRepository.URLs() {
+		validators, err = downloadFile(APKINDEXurl, apkRepository, authToken, localAPKINDEXPath, previous)
+		if errors.Is(err, errNotModified) {
+			logVerbose("APKINDEX of %s repository not modified at %s", apkRepository.Name, APKINDEXurl)
+			cleanup()
+			return "", validators, nil, err
+		}
+		if err == nil {
+			logVerbose("Fetched APKINDEX of %s repository from %s", apkRepository.Name, APKINDEXurl)
+			break
+		}
+		logVerbose("Failed to fetch APKINDEX of %s repository from %s: %v", apkRepository.Name, APKINDEXurl, err)
+		downloadErrors = append(downloadErrors, err)
+	}
+	if len(downloadErrors) == len(apkRepository.URLs()) {
+		cleanup()
+		return "", indexValidators{}, nil, errors.Join(downloadErrors...)
+	}
+	return localAPKINDEXPath, validators, cleanup, nil
+}
+
+// parseAPKINDEX decompresses and parses the APKINDEX.tar.gz of the repository at localAPKINDEXPath. With --low-memory
+// the index is decompressed to disk and parsed as it is read rather than read into memory first.
+func parseAPKINDEX(apkRepository Repository, localAPKINDEXPath string) (*repository.ApkIndex, error) {
+	if lowMemory {
+		apkIndex := &repository.ApkIndex{}
+		if err := streamAPKINDEX(localAPKINDEXPath, func(pkg *repository.Package) {
+			apkIndex.Packages = append(apkIndex.Packages, pkg)
+		}); err != nil {
+			return nil, fmt.Errorf("failed to parse APKINDEX file %s: %w", apkRepository.URL, err)
+		}
+		return apkIndex, nil
+	}
+	indexFile, err := os.Open(localAPKINDEXPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open APKINDEX file %s: %w", localAPKINDEXPath, err)
+	}
+	defer indexFile.Close()
+	apkIndex, err := repository.IndexFromArchive(indexFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse APKINDEX file %s: %w", apkRepository.URL, err)
+	}
+	return apkIndex, nil
+}
+
+// newRepositoryRequest returns a GET request of url from the repository, authenticated only for non public repositories
+func newRepositoryRequest(url string, apkRepository Repository, authToken string) (*http.Request, error) {
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("error creating request: %w", err)
+	}
+
+	// Add the auth token to the request header but only for non public repositories
+	if authToken != "" && apkRepository.RequiresAuth {
+		encodedAuth := base64.StdEncoding.EncodeToString([]byte("user:" + authToken))
+		req.Header.Set("Authorization", "Basic "+encodedAuth)
+	}
+
+	req.Header.Set("Accept", "application/gzip")
+	req.Header.Add("User-Agent", "curl/7.68.0")
+	return req, nil
+}
+
+// httpGet requests url from the repository, authenticating only for non public repositories
+func httpGet(url string, apkRepository Repository, authToken string) (*http.Response, error) {
+	req, err := newRepositoryRequest(url, apkRepository, authToken)
+	if err != nil {
+		return nil, err
+	}
+
+	// Send the request via a client
+	client := &http.Client{}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+	return resp, nil
+}
+
+// downloadFile downloads url from the repository to destinationPath and returns the validators of the response. When
+// previous holds the validators of an earlier download of url the request is conditional, and errNotModified is
+// returned when url has not changed since.
+func downloadFile(url string, apkRepository Repository, authToken string, destinationPath string, previous *indexValidators) (indexValidators, error) {
+	req, err := newRepositoryRequest(url, apkRepository, authToken)
+	if err != nil {
+		return indexValidators{}, err
+	}
+	if previous != nil && previous.URL == url {
+		if previous.ETag != "" {
+			req.Header.Set("If-None-Match", previous.ETag)
+		}
+		if previous.LastModified != "" {
+			req.Header.Set("If-Modified-Since", previous.LastModified)
+		}
+	}
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return indexValidators{}, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotModified && previous != nil {
+		return *previous, errNotModified
+	}
+	if resp.StatusCode != http.StatusOK {
+		return indexValidators{}, fmt.Errorf("failed to download %s: %s", url, resp.Status)
+	}
+
+	localFile, err := os.Create(destinationPath)
+	if err != nil {
+		return indexValidators{}, fmt.Errorf("failed to write %s: %w", destinationPath, err)
+	}
+	defer localFile.Close()
+
+	// Write the response to file
+	if _, err = io.Copy(localFile, resp.Body); err != nil {
+		return indexValidators{}, fmt.Errorf("failed to write %s: %w", destinationPath, err)
+	}
+	return indexValidators{URL: url, ETag: resp.Header.Get("ETag"), LastModified: resp.Header.Get("Last-Modified")}, nil
+}
+
+// streamPackages calls handle for each package of the repository, from the daemon when one is running, which has them
+// in memory, otherwise from the fetched APKINDEX as each package is parsed. The time taken to download, to parse and to
+// handle the packages, and the heap in use once they have been handled, are recorded in timing.
+func streamPackages(apkRepository Repository, authToken string, timing *RepositoryTiming, handle func(pkg *repository.Package)) error {
+	// the heap is sampled once every package has been handled, when the packages the handler kept are in use
+	defer func() {
+		var memStats runtime.MemStats
+		runtime.ReadMemStats(&memStats)
+		timing.HeapInUse = memStats.HeapInuse
+	}()
+	timedHandle := func(pkg *repository.Package) {
+		matchStartTime := time.Now()
+		handle(pkg)
+		timing.Match += time.Since(matchStartTime)
+	}
+
+	// a directory of apks has no APKINDEX, each package is read from its apk
+	if apkRepository.PackagesDir != "" {
+		startTime := time.Now()
+		if err := streamLocalPackages(apkRepository.PackagesDir, timedHandle); err != nil {
+			return err
+		}
+		timing.Parse = time.Since(startTime) - timing.Match
+		return nil
+	}
+	if daemonSocket != "" {
+		startTime := time.Now()
+		packages, err := daemonPackages(apkRepository)
+		if err == nil {
+			timing.Download = time.Since(startTime)
+			logVerbose("Loaded %s repository from the daemon on %s", apkRepository.Name, daemonSocket)
+			for _, pkg := range packages {
+				timedHandle(pkg)
+			}
+			return nil
+		}
+		logVerbose("Failed to load %s repository from the daemon on %s: %v", apkRepository.Name, daemonSocket, err)
+	}
+	// the packages parsed on a previous run are used while the repository confirms the APKINDEX has not changed
+	cached := loadParsedIndex(apkRepository)
+	var previous *indexValidators
+	if cached != nil {
+		previous = &cached.Validators
+	}
+	startTime := time.Now()
+	localAPKINDEXPath, validators, cleanup, err := downloadModifiedAPKINDEX(apkRepository, authToken, previous)
+	if errors.Is(err, errNotModified) {
+		timing.Download = time.Since(startTime)
+		startTime = time.Now()
+		packages, cacheErr := cached.packages(apkRepository)
+		if cacheErr == nil {
+			for _, pkg := range packages {
+				timedHandle(pkg)
+			}
+			timing.Parse = time.Since(startTime) - timing.Match
+			return nil
+		}
+		// the corrupt cache has been discarded, the APKINDEX is downloaded again unconditionally
+		logVerbose("%v, downloading the APKINDEX again", cacheErr)
+		localAPKINDEXPath, validators, cleanup, err = downloadModifiedAPKINDEX(apkRepository, authToken, nil)
+	}
+	if err != nil {
+		return err
+	}
+	defer cleanup()
+	timing.Download = time.Since(startTime)
+	startTime = time.Now()
+	// only APKINDEX downloads which can be revalidated are worth caching the packages of, and the packages are not kept
+	// in memory to cache them with --low-memory
+	var writer *parsedIndexWriter
+	if (validators.ETag != "" || validators.LastModified != "") && !lowMemory {
+		writer = newParsedIndexWriter(validators)
+	}
+	if err := streamAPKINDEX(localAPKINDEXPath, func(pkg *repository.Package) {
+		if writer != nil {
+			writer.Add(pkg)
+		}
+		timedHandle(pkg)
+	}); err != nil {
+		return err
+	}
+	// parsing and handling are interleaved, the time not spent handling packages was spent parsing
+	timing.Parse = time.Since(startTime) - timing.Match
+	if writer != nil {
+		writer.Save(apkRepository)
+	}
+	return nil
+}
+
+// forEachPackage fetches the APKINDEX of each repository in turn and calls handle for every package it contains which
+// is not ignored by the configuration. It returns how long each repository took to fetch and process, or an error
+// wrapping errLoadFailed when a repository could not be loaded, which the caller reports.
+func forEachPackage(repositories []Repository, authToken string, handle func(apkRepository Repository, pkg *repository.Package)) ([]RepositoryTiming, error) {
+	return forEachIndexedPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if !isIgnored(pkg.Name) {
+			handle(apkRepository, pkg)
+		}
+	})
+}
+
+// errLoadFailed is returned, wrapped, when the packages of a repository could not be loaded, so commands can tell a
+// fetch failure, which they exit with exitFetchFailed for, from a package which does not exist
+var errLoadFailed = errors.New("failed to load APKINDEX")
+
+// partialResults is set when repositories which cannot be loaded should be skipped, with their errors collected in
+// loadErrors, rather than fail the command
+var partialResults bool
+
+// loadErrors are the errors of the repositories skipped when partialResults is set
+var loadErrors []ReportedError
+
+// packageChannelSize bounds how many parsed packages of a repository wait to be handled, so the repositories are
+// downloaded and parsed concurrently without their whole indices being held in memory
+const packageChannelSize = 1024
+
+// forEachIndexedPackage is forEachPackage including ignored packages, for commands which act on exact packages, such as
+// resolving a lock file, rather than report on them. The repositories are downloaded and parsed concurrently, overlapping
+// network and CPU work, while handle is called from a single goroutine for the packages of each repository in turn, so
+// results do not depend on which repository is fetched first.
+func forEachIndexedPackage(repositories []Repository, authToken string, handle func(apkRepository Repository, pkg *repository.Package)) ([]RepositoryTiming, error) {
+	group, ctx := errgroup.WithContext(context.Background())
+	timings := make([]RepositoryTiming, len(repositories))
+	packageChannels := make([]chan *repository.Package, len(repositories))
+	repositoryErrors := make([]error, len(repositories))
+	for i, apkRepository := range repositories {
+		timings[i] = RepositoryTiming{Name: apkRepository.Name}
+		packageChannels[i] = make(chan *repository.Package, packageChannelSize)
+		group.Go(func() error {
+			defer close(packageChannels[i])
+			err := streamPackages(apkRepository, authToken, &timings[i], func(pkg *repository.Package) {
+				select {
+				case packageChannels[i] <- pkg:
+				case <-ctx.Done():
+				}
+			})
+			if err != nil && partialResults {
+				repositoryErrors[i] = err
+				return nil
+			}
+			if err != nil {
+				return fmt.Errorf("%s repository: %w", apkRepository.Name, err)
+			}
+			return nil
+		})
+	}
+
+	// the timings of a repository are only complete once every package has been handled, the time streamPackages
+	// recorded as matching was spent waiting for packages to be handled
+	matchTimes := make([]time.Duration, len(repositories))
+	for i, apkRepository := range repositories {
+		for pkg := range packageChannels[i] {
+			matchStartTime := time.Now()
+			handle(apkRepository, pkg)
+			matchTimes[i] += time.Since(matchStartTime)
+		}
+	}
+	if err := group.Wait(); err != nil {
+		return nil, fmt.Errorf("%w of %w", errLoadFailed, err)
+	}
+	for i, err := range repositoryErrors {
+		if err != nil {
+			loadErrors = append(loadErrors, ReportedError{Kind: "repository", Repository: repositories[i].Name, Message: err.Error()})
+		}
+	}
+	for i := range timings {
+		timings[i].Match = matchTimes[i]
+		// the repositories are fetched concurrently, so a repository's own time excludes waiting for the packages of
+		// the repositories before it to be handled
+		timings[i].Elapsed = timings[i].Download + timings[i].Parse + timings[i].Match
+	}
+	return timings, nil
+}
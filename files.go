@@ -0,0 +1,84 @@
+package main
+
+import (
+	"archive/tar"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// PackageFile is a single entry installed by a package
+type PackageFile struct {
+	Path       string
+	Type       string
+	Mode       string
+	Size       int64
+	LinkTarget string `json:",omitempty"`
+}
+
+// runFiles lists the files installed by the latest, or the specified, version of a package
+func runFiles(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s files PACKAGE[=VERSION]\n", os.Args[0])
+		return exitUsage
+	}
+	packageName, packageVersion := splitPackageReference(args[0])
+	packageName = resolveAlias(packageName)
+	apkRepository, pkg, err := resolvePackage(repositories, authToken, packageName, packageVersion)
+	if errors.Is(err, errLoadFailed) {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitNoMatches
+	}
+	apk, err := fetchAPK(apkRepository, pkg, authToken)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to fetch %s: %v\n", pkg.Filename(), err)
+		return exitFetchFailed
+	}
+	headers, err := apk.Files()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to list files of %s: %v\n", pkg.Filename(), err)
+		return exitUsage
+	}
+
+	var packageFiles []PackageFile
+	for _, header := range headers {
+		packageFile := PackageFile{Path: "/" + header.Name, Mode: header.FileInfo().Mode().String(), Size: header.Size}
+		switch header.Typeflag {
+		case tar.TypeDir:
+			packageFile.Type = "directory"
+		case tar.TypeSymlink, tar.TypeLink:
+			packageFile.Type = "link"
+			packageFile.LinkTarget = header.Linkname
+		default:
+			packageFile.Type = "file"
+		}
+		packageFiles = append(packageFiles, packageFile)
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(packageFiles, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	fmt.Fprintf(stdout, "Files of package %s version %s in %s repository:\n", pkg.Name, pkg.Version, apkRepository.Name)
+	for _, packageFile := range packageFiles {
+		switch packageFile.Type {
+		case "directory":
+			continue
+		case "link":
+			fmt.Fprintf(stdout, "%s -> %s\n", packageFile.Path, packageFile.LinkTarget)
+		default:
+			fmt.Fprintln(stdout, packageFile.Path)
+		}
+	}
+	return 0
+}
@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// ghaAnnotation is a GitHub Actions workflow command annotating a line of a file, or the workflow run when File is
+// empty, so problems show inline on pull requests
+type ghaAnnotation struct {
+	// Level is notice, warning or error
+	Level   string
+	File    string
+	Line    int
+	Title   string
+	Message string
+}
+
+// ghaEscapeData escapes the message of a workflow command
+func ghaEscapeData(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A").Replace(value)
+}
+
+// ghaEscapeProperty escapes a property value of a workflow command
+func ghaEscapeProperty(value string) string {
+	return strings.NewReplacer("%", "%25", "\r", "%0D", "\n", "%0A", ":", "%3A", ",", "%2C").Replace(value)
+}
+
+func (a ghaAnnotation) String() string {
+	var properties []string
+	if a.File != "" {
+		properties = append(properties, "file="+ghaEscapeProperty(a.File))
+		if a.Line > 0 {
+			properties = append(properties, fmt.Sprintf("line=%d", a.Line))
+		}
+	}
+	if a.Title != "" {
+		properties = append(properties, "title="+ghaEscapeProperty(a.Title))
+	}
+	command := "::" + a.Level
+	if len(properties) > 0 {
+		command += " " + strings.Join(properties, ",")
+	}
+	return command + "::" + ghaEscapeData(a.Message)
+}
+
+// writeGHA renders the annotations one workflow command per line
+func writeGHA(w io.Writer, annotations []ghaAnnotation) error {
+	for _, annotation := range annotations {
+		if _, err := fmt.Fprintln(w, annotation); err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,225 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/graphql-go/graphql"
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// catalogVersion is a version of a package in a repository
+type catalogVersion struct {
+	Repository string
+	Package    *repository.Package
+}
+
+// catalogPackage is a package with its versions across all repositories, oldest first
+type catalogPackage struct {
+	Name     string
+	Versions []catalogVersion
+	catalog  *packageCatalog
+}
+
+// latest returns the newest version of the package, preferring the repository queried first for the same version as
+// apk would
+func (p *catalogPackage) latest() catalogVersion {
+	newest := p.Versions[len(p.Versions)-1]
+	for _, version := range p.Versions {
+		if version.Package.Version == newest.Package.Version {
+			return version
+		}
+	}
+	return newest
+}
+
+// packageCatalog indexes the packages held by the daemon by name and origin for the GraphQL API
+type packageCatalog struct {
+	packages map[string]*catalogPackage
+	// subPackages are the names of the packages built from each origin package, excluding the origin package itself
+	subPackages map[string][]string
+}
+
+// newPackageCatalog builds the catalog of the indices, in the order of the repositories
+func newPackageCatalog(repositories []Repository, indices map[string]*daemonIndex) *packageCatalog {
+	catalog := &packageCatalog{packages: make(map[string]*catalogPackage), subPackages: make(map[string][]string)}
+	for _, apkRepository := range repositories {
+		index := indices[apkRepository.ID]
+		if index == nil {
+			continue
+		}
+		for _, pkg := range index.Packages {
+			if isIgnored(pkg.Name) {
+				continue
+			}
+			catalogEntry, found := catalog.packages[pkg.Name]
+			if !found {
+				catalogEntry = &catalogPackage{Name: pkg.Name, catalog: catalog}
+				catalog.packages[pkg.Name] = catalogEntry
+				if pkg.Origin != "" && pkg.Origin != pkg.Name {
+					catalog.subPackages[pkg.Origin] = append(catalog.subPackages[pkg.Origin], pkg.Name)
+				}
+			}
+			catalogEntry.Versions = append(catalogEntry.Versions, catalogVersion{Repository: apkRepository.Name, Package: pkg})
+		}
+	}
+	for _, catalogEntry := range catalog.packages {
+		versions := catalogEntry.Versions
+		// stable so the same version stays in the order of the repositories
+		sort.SliceStable(versions, func(i, j int) bool {
+			return versionGreaterThan(versions[j].Package.Version, versions[i].Package.Version)
+		})
+	}
+	for _, names := range catalog.subPackages {
+		sort.Strings(names)
+	}
+	return catalog
+}
+
+// sortedPackages returns the packages of the names, sorted by name
+func (c *packageCatalog) sortedPackages(names []string) []*catalogPackage {
+	sort.Strings(names)
+	packages := make([]*catalogPackage, 0, len(names))
+	for _, name := range names {
+		if catalogEntry, found := c.packages[name]; found {
+			packages = append(packages, catalogEntry)
+		}
+	}
+	return packages
+}
+
+// newGraphQLSchema returns the schema of the GraphQL API of the daemon:
+//
+//	package(name: String!): Package
+//	search(regex: String!): [Package]
+//
+// where a Package has its name, origin, description, latest version, versions in every repository, sub packages and
+// the dependencies of its latest version. catalog returns the catalog of the packages as of the last refresh.
+func newGraphQLSchema(catalog func() *packageCatalog) (graphql.Schema, error) {
+	versionType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Version",
+		Fields: graphql.Fields{
+			"version": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(catalogVersion).Package.Version, nil
+			}},
+			"repository": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(catalogVersion).Repository, nil
+			}},
+			"buildTime": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(catalogVersion).Package.BuildTime.UTC().Format(time.RFC3339), nil
+			}},
+			"checksum": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return "Q1" + base64.StdEncoding.EncodeToString(p.Source.(catalogVersion).Package.Checksum), nil
+			}},
+			"dependencies": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(catalogVersion).Package.Dependencies, nil
+			}},
+			"provides": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+				return p.Source.(catalogVersion).Package.Provides, nil
+			}},
+		},
+	})
+	var packageType *graphql.Object
+	packageType = graphql.NewObject(graphql.ObjectConfig{
+		Name: "Package",
+		// a thunk as sub packages are packages themselves
+		Fields: graphql.FieldsThunk(func() graphql.Fields {
+			return graphql.Fields{
+				"name": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).Name, nil
+				}},
+				"origin": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).latest().Package.Origin, nil
+				}},
+				"description": &graphql.Field{Type: graphql.String, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).latest().Package.Description, nil
+				}},
+				"latest": &graphql.Field{Type: versionType, Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).latest(), nil
+				}},
+				"versions": &graphql.Field{Type: graphql.NewList(versionType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).Versions, nil
+				}},
+				"subpackages": &graphql.Field{Type: graphql.NewList(packageType), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					catalogEntry := p.Source.(*catalogPackage)
+					return catalogEntry.catalog.sortedPackages(append([]string(nil), catalogEntry.catalog.subPackages[catalogEntry.Name]...)), nil
+				}},
+				"dependencies": &graphql.Field{Type: graphql.NewList(graphql.String), Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					return p.Source.(*catalogPackage).latest().Package.Dependencies, nil
+				}},
+			}
+		}),
+	})
+	queryType := graphql.NewObject(graphql.ObjectConfig{
+		Name: "Query",
+		Fields: graphql.Fields{
+			"package": &graphql.Field{
+				Type: packageType,
+				Args: graphql.FieldConfigArgument{"name": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					catalogEntry, found := catalog().packages[p.Args["name"].(string)]
+					if !found {
+						return nil, nil
+					}
+					return catalogEntry, nil
+				},
+			},
+			"search": &graphql.Field{
+				Type: graphql.NewList(packageType),
+				Args: graphql.FieldConfigArgument{"regex": &graphql.ArgumentConfig{Type: graphql.NewNonNull(graphql.String)}},
+				Resolve: func(p graphql.ResolveParams) (interface{}, error) {
+					regex, err := regexp.Compile(p.Args["regex"].(string))
+					if err != nil {
+						return nil, err
+					}
+					packages := catalog()
+					var names []string
+					for name := range packages.packages {
+						if regex.MatchString(name) {
+							names = append(names, name)
+						}
+					}
+					return packages.sortedPackages(names), nil
+				},
+			},
+		},
+	})
+	return graphql.NewSchema(graphql.SchemaConfig{Query: queryType})
+}
+
+// graphQLRequest is the body of a GraphQL request sent with POST
+type graphQLRequest struct {
+	Query         string                 `json:"query"`
+	OperationName string                 `json:"operationName"`
+	Variables     map[string]interface{} `json:"variables"`
+}
+
+// graphQLHandler serves GraphQL queries sent with POST as JSON or with GET as the query parameter
+func graphQLHandler(schema graphql.Schema) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var request graphQLRequest
+		switch r.Method {
+		case http.MethodGet:
+			request.Query = r.URL.Query().Get("query")
+		case http.MethodPost:
+			if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+				http.Error(w, "invalid GraphQL request: "+err.Error(), http.StatusBadRequest)
+				return
+			}
+		default:
+			http.Error(w, "GraphQL queries must use GET or POST", http.StatusMethodNotAllowed)
+			return
+		}
+		writeJSON(w, graphql.Do(graphql.Params{
+			Schema:         schema,
+			RequestString:  request.Query,
+			OperationName:  request.OperationName,
+			VariableValues: request.Variables,
+			Context:        r.Context(),
+		}))
+	})
+}
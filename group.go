@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// matchedQuery reports whether any version of the package matched the query
+func matchedQuery(versions []PackageMeta, query string) bool {
+	for _, packageMeta := range versions {
+		for _, matchedQuery := range packageMeta.MatchedQueries {
+			if matchedQuery == query {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// forQuery returns the results, and sub packages, matched by the query. The sub packages of a matched package are
+// included with it.
+func (r *Results) forQuery(query string) *Results {
+	queryResults := NewResults()
+	queryResults.DefinitionsURLs = r.DefinitionsURLs
+	for packageName, versions := range r.AllVersions {
+		if matchedQuery(versions, query) {
+			queryResults.AllVersions[packageName] = versions
+			queryResults.LatestVersion[packageName] = r.LatestVersion[packageName]
+		}
+	}
+	for subPackageName, versions := range r.SubPackages.AllVersions {
+		latestVersion := r.SubPackages.LatestVersion[subPackageName]
+		if _, originMatched := queryResults.AllVersions[latestVersion.Origin]; originMatched || matchedQuery(versions, query) {
+			queryResults.SubPackages.AllVersions[subPackageName] = versions
+			queryResults.SubPackages.LatestVersion[subPackageName] = latestVersion
+		}
+	}
+	return queryResults
+}
+
+// printGroupedByQuery renders the results nested under each of options.Queries, in the order they were specified. In
+// JSON output each query maps to the document the query alone would have produced, in text output the results of each
+// query follow a heading.
+func (r *Results) printGroupedByQuery(w io.Writer, options PrintOptions) error {
+	queryOptions := options
+	queryOptions.GroupBy = ""
+	if options.JSON {
+		groups := make(map[string]json.RawMessage)
+		for _, query := range removeDuplicates(options.Queries) {
+			var group bytes.Buffer
+			if err := r.forQuery(query).print(&group, queryOptions); err != nil {
+				return err
+			}
+			groups[query] = group.Bytes()
+		}
+		jsonOutput, err := json.MarshalIndent(groups, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(jsonOutput))
+		return err
+	}
+	for _, query := range removeDuplicates(options.Queries) {
+		fmt.Fprintf(w, "Query %s:\n", query)
+		queryResults := r.forQuery(query)
+		if len(queryResults.AllVersions) == 0 && len(queryResults.SubPackages.AllVersions) == 0 {
+			fmt.Fprintln(w, "\tno packages matched")
+			continue
+		}
+		var group bytes.Buffer
+		if err := queryResults.print(&group, queryOptions); err != nil {
+			return err
+		}
+		for _, line := range strings.Split(strings.TrimSuffix(group.String(), "\n"), "\n") {
+			fmt.Fprintf(w, "\t%s\n", line)
+		}
+	}
+	return nil
+}
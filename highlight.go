@@ -0,0 +1,115 @@
+package main
+
+import (
+	"os"
+	"sort"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// highlightStart and highlightEnd surround the matched portions of package names, bold red as grep --color does
+const (
+	highlightStart = "\x1b[1;31m"
+	highlightEnd   = "\x1b[0m"
+)
+
+// spanMatcher is implemented by matchers which can tell which portions of a package name they matched, as start and
+// end byte offsets
+type spanMatcher interface {
+	matchedSpans(name string) [][2]int
+}
+
+func (m exactMatcher) matchedSpans(name string) [][2]int {
+	if name != m.query {
+		return nil
+	}
+	return [][2]int{{0, len(name)}}
+}
+
+func (m exactMatcherSet) matchedSpans(name string) [][2]int {
+	if _, found := m.names[name]; !found {
+		return nil
+	}
+	return [][2]int{{0, len(name)}}
+}
+
+// matchedSpans of a regular expression are its capture groups, when it has any, so `python-(3\.1[23])` highlights only
+// the version, and otherwise each match
+func (m regexMatcher) matchedSpans(name string) [][2]int {
+	if name == m.pattern {
+		return [][2]int{{0, len(name)}}
+	}
+	var spans [][2]int
+	for _, match := range m.regex.FindAllStringSubmatchIndex(name, -1) {
+		if len(match) == 2 {
+			spans = append(spans, [2]int{match[0], match[1]})
+			continue
+		}
+		for i := 2; i+1 < len(match); i += 2 {
+			if match[i] >= 0 {
+				spans = append(spans, [2]int{match[i], match[i+1]})
+			}
+		}
+	}
+	return spans
+}
+
+func (m globMatcher) matchedSpans(name string) [][2]int {
+	if !m.Matches(&repository.Package{Name: name}) {
+		return nil
+	}
+	return [][2]int{{0, len(name)}}
+}
+
+func (m fuzzyMatcher) matchedSpans(name string) [][2]int {
+	if !m.Matches(&repository.Package{Name: name}) {
+		return nil
+	}
+	return [][2]int{{0, len(name)}}
+}
+
+// highlightName returns the package name with the portions matched by any of the matchers highlighted. Virtual
+// provides are matched against the provides of a package rather than its name so highlight nothing.
+func highlightName(name string, matchers []Matcher) string {
+	var spans [][2]int
+	for _, matcher := range matchers {
+		if spanMatcher, found := matcher.(spanMatcher); found {
+			for _, span := range spanMatcher.matchedSpans(name) {
+				// empty matches, e.g. of an optional group, have nothing to highlight
+				if span[1] > span[0] {
+					spans = append(spans, span)
+				}
+			}
+		}
+	}
+	if len(spans) == 0 {
+		return name
+	}
+	sort.Slice(spans, func(i, j int) bool {
+		return spans[i][0] < spans[j][0]
+	})
+	highlighted := ""
+	end := 0
+	for _, span := range spans {
+		// overlapping spans of several matchers are highlighted as one
+		if span[1] <= end {
+			continue
+		}
+		start := max(span[0], end)
+		highlighted += name[end:start] + highlightStart + name[start:span[1]] + highlightEnd
+		end = span[1]
+	}
+	return highlighted + name[end:]
+}
+
+// colorOutput reports whether text output written to the file should be colored - only on a terminal, which is not a
+// dumb terminal, and never when NO_COLOR is set
+func colorOutput(f *os.File) bool {
+	if _, noColor := os.LookupEnv("NO_COLOR"); noColor {
+		return false
+	}
+	if os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	return isTerminal(f.Fd())
+}
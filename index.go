@@ -0,0 +1,120 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// loadSigningKey reads an RSA private key in PEM format, either PKCS#1 as written by abuild-keygen and melange keygen or
+// PKCS#8
+func loadSigningKey(keyPath string) (*rsa.PrivateKey, error) {
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(keyPEM)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data in %s", keyPath)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key %s: %w", keyPath, err)
+	}
+	rsaKey, isRSA := key.(*rsa.PrivateKey)
+	if !isRSA {
+		return nil, errors.New("only RSA signing keys are supported")
+	}
+	return rsaKey, nil
+}
+
+// signIndex prepends the signature section to the gzip compressed APKINDEX archive, as apk index does. The signature is
+// a gzip compressed tar holding a single .SIGN.RSA.KEYNAME.pub entry, without the end of archive marker so the sections
+// read as one tar, with the PKCS#1 v1.5 SHA-1 signature of the archive. apk finds the public key to verify it with by
+// KEYNAME in /etc/apk/keys.
+func signIndex(archive []byte, keyPath string) ([]byte, error) {
+	key, err := loadSigningKey(keyPath)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha1.Sum(archive)
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA1, digest[:])
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign APKINDEX: %w", err)
+	}
+
+	var signatureSection bytes.Buffer
+	gzipWriter := gzip.NewWriter(&signatureSection)
+	tarWriter := tar.NewWriter(gzipWriter)
+	header := &tar.Header{Name: ".SIGN.RSA." + filepath.Base(keyPath) + ".pub", Mode: 0o644, Size: int64(len(signature))}
+	if err := tarWriter.WriteHeader(header); err != nil {
+		return nil, err
+	}
+	if _, err := tarWriter.Write(signature); err != nil {
+		return nil, err
+	}
+	// Flush rather than Close, which would write the end of archive marker
+	if err := tarWriter.Flush(); err != nil {
+		return nil, err
+	}
+	if err := gzipWriter.Close(); err != nil {
+		return nil, err
+	}
+	return append(signatureSection.Bytes(), archive...), nil
+}
+
+// runIndex writes the APKINDEX.tar.gz of the .apk files in a directory, optionally signed with signingKey, so the
+// directory can be used as an apk repository
+func runIndex(args []string, signingKey string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s index [--signing-key KEY.rsa] DIR\n", os.Args[0])
+		return exitUsage
+	}
+	dir := args[0]
+	apkIndex := &repository.ApkIndex{}
+	if err := streamLocalPackages(dir, func(pkg *repository.Package) {
+		apkIndex.Packages = append(apkIndex.Packages, pkg)
+	}); err != nil {
+		fmt.Fprintf(stderr, "Failed to read the packages in %s: %v\n", dir, err)
+		return exitUsage
+	}
+	archiveReader, err := repository.ArchiveFromIndex(apkIndex)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create APKINDEX: %v\n", err)
+		return exitUsage
+	}
+	archive, err := io.ReadAll(archiveReader)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create APKINDEX: %v\n", err)
+		return exitUsage
+	}
+	if signingKey != "" {
+		if archive, err = signIndex(archive, signingKey); err != nil {
+			fmt.Fprintf(stderr, "Failed to sign APKINDEX with %s: %v\n", signingKey, err)
+			return exitUsage
+		}
+	}
+	indexPath := filepath.Join(dir, "APKINDEX.tar.gz")
+	if err := os.WriteFile(indexPath, archive, 0o644); err != nil {
+		fmt.Fprintf(stderr, "Failed to write %s: %v\n", indexPath, err)
+		return exitUsage
+	}
+	fmt.Fprintf(stdout, "Wrote %s with %d packages\n", indexPath, len(apkIndex.Packages))
+	return 0
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"bytes"
+	"encoding/gob"
+	"errors"
+	"fmt"
+	"io"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// errNotModified is returned when a conditional request finds the APKINDEX has not changed since it was last downloaded
+var errNotModified = errors.New("APKINDEX not modified")
+
+// indexValidators identify the response an APKINDEX was downloaded from. They are sent back with a conditional request
+// to the same URL to find out whether the APKINDEX has changed since.
+type indexValidators struct {
+	URL          string
+	ETag         string
+	LastModified string
+}
+
+// parsedIndexWriter gob encodes the packages of an APKINDEX as they are parsed, after the validators of the download
+// they were parsed from, so warm runs skip decompressing and parsing the APKINDEX while it has not changed
+type parsedIndexWriter struct {
+	buffer  bytes.Buffer
+	encoder *gob.Encoder
+	err     error
+}
+
+func newParsedIndexWriter(validators indexValidators) *parsedIndexWriter {
+	w := &parsedIndexWriter{}
+	w.encoder = gob.NewEncoder(&w.buffer)
+	w.err = w.encoder.Encode(validators)
+	return w
+}
+
+// Add encodes the next package of the APKINDEX
+func (w *parsedIndexWriter) Add(pkg *repository.Package) {
+	if w.err == nil {
+		w.err = w.encoder.Encode(pkg)
+	}
+}
+
+// Save caches the encoded packages of the repository. This is best effort, failing to cache the packages should not
+// fail the command.
+func (w *parsedIndexWriter) Save(apkRepository Repository) {
+	if w.err == nil {
+		_ = packageCache.Put(parsedIndexKey(apkRepository), w.buffer.Bytes())
+	}
+}
+
+// parsedIndex is the cached packages of the APKINDEX of a repository
+type parsedIndex struct {
+	Validators indexValidators
+	decoder    *gob.Decoder
+}
+
+// loadParsedIndex returns the cached packages of the repository, or nil when none are cached or they cannot be read
+func loadParsedIndex(apkRepository Repository) *parsedIndex {
+	cached, err := packageCache.Get(parsedIndexKey(apkRepository))
+	if err != nil || cached == nil {
+		return nil
+	}
+	index := &parsedIndex{decoder: gob.NewDecoder(bytes.NewReader(cached.Value))}
+	if err := index.decoder.Decode(&index.Validators); err != nil {
+		return nil
+	}
+	return index
+}
+
+// packages decodes every cached package. The packages are all decoded before any is handled so a cache which turns
+// out to be corrupt is discarded, for the APKINDEX to be parsed again, without having handled part of it.
+func (p *parsedIndex) packages(apkRepository Repository) ([]*repository.Package, error) {
+	var packages []*repository.Package
+	for {
+		pkg := &repository.Package{}
+		err := p.decoder.Decode(pkg)
+		if err == io.EOF {
+			return packages, nil
+		}
+		if err != nil {
+			_ = packageCache.Delete(parsedIndexKey(apkRepository))
+			return nil, fmt.Errorf("failed to read the cached packages of %s repository: %w", apkRepository.Name, err)
+		}
+		packages = append(packages, pkg)
+	}
+}
@@ -0,0 +1,203 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// PackageInfo is the APKINDEX entry of a package version, with links to the build source when the repository publishes
+// its definitions
+type PackageInfo struct {
+	Name          string
+	Version       string
+	Repository    string
+	Origin        string
+	Description   string
+	URL           string
+	License       string
+	Maintainer    string
+	BuildTime     time.Time
+	Commit        string
+	CommitURL     string `json:",omitempty"`
+	DefinitionURL string `json:",omitempty"`
+	Size          uint64
+	InstalledSize uint64
+	Checksum      string
+	Dependencies  []string
+	Provides      []string
+	// Replaces are the packages whose files this package may overwrite, and ReplacesPriority decides which package
+	// owns a file when both replace each other
+	Replaces         []string
+	ReplacesPriority uint64 `json:",omitempty"`
+	// InstallIf are the packages which, once all installed, make apk install this package automatically
+	InstallIf []string
+	Footprint *Footprint `json:",omitempty"`
+	// FootprintError is why the dependency closure could not be resolved, e.g. a dependency in no repository queried
+	FootprintError string `json:",omitempty"`
+}
+
+// Footprint summarises the dependency closure of a package, to compare candidate packages at a glance
+type Footprint struct {
+	DirectDependencies     int
+	TransitiveDependencies int
+	// ClosureInstalledSize is the installed size in bytes of the package and all of its transitive dependencies
+	ClosureInstalledSize uint64
+}
+
+// footprint resolves the dependency closure of the package from the loaded indices
+func (index *packageIndex) footprint(pkg *repository.Package) (*Footprint, error) {
+	closure, err := index.resolve([]Constraint{{Name: pkg.Name, Operator: "=", Version: pkg.Version}})
+	if err != nil {
+		return nil, err
+	}
+	footprint := &Footprint{TransitiveDependencies: len(closure) - 1}
+	for _, dependency := range pkg.Dependencies {
+		// conflicts are not dependencies
+		if !strings.HasPrefix(dependency, "!") {
+			footprint.DirectDependencies++
+		}
+	}
+	for _, resolved := range closure {
+		footprint.ClosureInstalledSize += resolved.Package.InstalledSize
+	}
+	return footprint, nil
+}
+
+// fetchReplacesPriority reads the replaces_priority of the package from the .PKGINFO of its apk, zero when unset
+func fetchReplacesPriority(apkRepository Repository, pkg *repository.Package, authToken string) (uint64, error) {
+	apk, err := fetchAPK(apkRepository, pkg, authToken)
+	if err != nil {
+		return 0, err
+	}
+	fields, _, err := apk.Control()
+	if err != nil {
+		return 0, err
+	}
+	for _, field := range fields {
+		if field.Key == "replaces_priority" {
+			return strconv.ParseUint(field.Value, 10, 64)
+		}
+	}
+	return 0, nil
+}
+
+// runInfo prints the APKINDEX entry of the latest, or the specified, version of a package, including the commit of the
+// build source which produced it for traceability from a version back to the exact build, and the footprint of its
+// dependency closure. With readReplacesPriority the apk of a package which replaces others is downloaded to read its
+// replaces_priority.
+func runInfo(args []string, repositories []Repository, authToken string, asJSON bool, readReplacesPriority bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s info PACKAGE[=VERSION]\n", os.Args[0])
+		return exitUsage
+	}
+	packageName, packageVersion := splitPackageReference(args[0])
+	packageName = resolveAlias(packageName)
+	constraint := Constraint{Name: packageName}
+	if packageVersion != "" {
+		constraint.Operator, constraint.Version = "=", packageVersion
+	}
+	index, err := buildPackageIndex(repositories, authToken)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	resolved, found := index.best(constraint)
+	if !found || resolved.Package.Name != packageName {
+		if packageVersion != "" {
+			fmt.Fprintf(stderr, "package %s version %s not found\n", packageName, packageVersion)
+		} else {
+			fmt.Fprintf(stderr, "package %s not found\n", packageName)
+		}
+		return exitNoMatches
+	}
+	apkRepository, pkg := resolved.Repository, resolved.Package
+	info := PackageInfo{
+		Name:          pkg.Name,
+		Version:       pkg.Version,
+		Repository:    apkRepository.Name,
+		Origin:        pkg.Origin,
+		Description:   pkg.Description,
+		URL:           pkg.URL,
+		License:       pkg.License,
+		Maintainer:    pkg.Maintainer,
+		BuildTime:     pkg.BuildTime,
+		Commit:        pkg.RepoCommit,
+		Size:          pkg.Size,
+		InstalledSize: pkg.InstalledSize,
+		Checksum:      pkg.ChecksumString(),
+		Dependencies:  pkg.Dependencies,
+		Provides:      pkg.Provides,
+		Replaces:      pkg.Replaces,
+		InstallIf:     pkg.InstallIf,
+	}
+	if apkRepository.CommitsURL != "" && pkg.RepoCommit != "" {
+		info.CommitURL = apkRepository.CommitsURL + pkg.RepoCommit
+	}
+	if apkRepository.DefinitionsURL != "" {
+		info.DefinitionURL = definitionURL(apkRepository.DefinitionsURL, pkg)
+	}
+
+	if len(pkg.Replaces) > 0 && readReplacesPriority {
+		// replaces_priority is only recorded in the .PKGINFO, not the APKINDEX, so the apk is only fetched when asked
+		// for with --replaces-priority
+		if replacesPriority, err := fetchReplacesPriority(apkRepository, pkg, authToken); err != nil {
+			fmt.Fprintf(stderr, "Warning: failed to read replaces_priority of %s: %v\n", pkg.Filename(), err)
+		} else {
+			info.ReplacesPriority = replacesPriority
+		}
+	}
+
+	if footprint, err := index.footprint(pkg); err != nil {
+		info.FootprintError = err.Error()
+	} else {
+		info.Footprint = footprint
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(info, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	fmt.Fprintf(stdout, "Package %s version %s in %s repository:\n", info.Name, info.Version, info.Repository)
+	fmt.Fprintf(stdout, "Origin: %s\n", info.Origin)
+	fmt.Fprintf(stdout, "Description: %s\n", info.Description)
+	fmt.Fprintf(stdout, "URL: %s\n", info.URL)
+	fmt.Fprintf(stdout, "License: %s\n", info.License)
+	fmt.Fprintf(stdout, "Maintainer: %s\n", info.Maintainer)
+	fmt.Fprintf(stdout, "Build time: %s\n", formatTime(info.BuildTime))
+	if info.CommitURL != "" {
+		fmt.Fprintf(stdout, "Commit: %s (%s)\n", info.Commit, info.CommitURL)
+	} else {
+		fmt.Fprintf(stdout, "Commit: %s\n", info.Commit)
+	}
+	if info.DefinitionURL != "" {
+		fmt.Fprintf(stdout, "Definition: %s\n", info.DefinitionURL)
+	}
+	fmt.Fprintf(stdout, "Size: %s, installed size %s\n", humanize.IBytes(info.Size), humanize.IBytes(info.InstalledSize))
+	fmt.Fprintf(stdout, "Checksum: %s\n", info.Checksum)
+	fmt.Fprintf(stdout, "Dependencies: %s\n", strings.Join(info.Dependencies, " "))
+	fmt.Fprintf(stdout, "Provides: %s\n", strings.Join(info.Provides, " "))
+	if info.ReplacesPriority != 0 {
+		fmt.Fprintf(stdout, "Replaces: %s (priority %d)\n", strings.Join(info.Replaces, " "), info.ReplacesPriority)
+	} else {
+		fmt.Fprintf(stdout, "Replaces: %s\n", strings.Join(info.Replaces, " "))
+	}
+	fmt.Fprintf(stdout, "Install if: %s\n", strings.Join(info.InstallIf, " "))
+	if info.Footprint != nil {
+		fmt.Fprintf(stdout, "Footprint: %d direct and %d transitive dependencies, closure installed size %s\n", info.Footprint.DirectDependencies, info.Footprint.TransitiveDependencies, humanize.IBytes(info.Footprint.ClosureInstalledSize))
+	} else {
+		fmt.Fprintf(stdout, "Footprint: unknown - %s\n", info.FootprintError)
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// runLatest prints only the newest version string of the named package across the repositories. It returns a non zero
+// exit code when the package does not exist in any of them.
+func runLatest(args []string, repositories []Repository, authToken string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s latest [--repo REPOSITORY] PACKAGE\n", os.Args[0])
+		return exitUsage
+	}
+	packageName := resolveAlias(args[0])
+	results := NewResults()
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if pkg.Name == packageName {
+			results.AddPackageMeta(pkg, apkRepository.Name, nil)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	latestVersion, found := results.LatestVersion[packageName]
+	if !found {
+		fmt.Fprintf(stderr, "Package %s not found\n", packageName)
+		return exitNoMatches
+	}
+	fmt.Fprintln(stdout, latestVersion.Version)
+	return 0
+}
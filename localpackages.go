@@ -0,0 +1,102 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// localPackagesRepository returns the ad-hoc repository of the .apk files in dir, e.g. freshly built by melange
+func localPackagesRepository(dir string) Repository {
+	return Repository{ID: "local-packages", Name: "local packages", URL: filepath.Join(dir, "APKINDEX.tar.gz"), PackagesDir: dir}
+}
+
+// packageFromAPK builds the APKINDEX entry of the apk at apkPath from its .PKGINFO, as apk index would
+func packageFromAPK(apkPath string) (*repository.Package, error) {
+	content, err := os.ReadFile(apkPath)
+	if err != nil {
+		return nil, err
+	}
+	apk, err := readAPK(bytes.NewReader(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", apkPath, err)
+	}
+	fields, _, err := apk.Control()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read .PKGINFO of %s: %w", apkPath, err)
+	}
+	checksum := sha1.Sum(apk.ControlSection)
+	pkg := &repository.Package{Checksum: checksum[:], Size: uint64(len(content))}
+	for _, field := range fields {
+		switch field.Key {
+		case "pkgname":
+			pkg.Name = field.Value
+		case "pkgver":
+			pkg.Version = field.Value
+		case "pkgdesc":
+			pkg.Description = field.Value
+		case "url":
+			pkg.URL = field.Value
+		case "arch":
+			pkg.Arch = field.Value
+		case "license":
+			pkg.License = field.Value
+		case "origin":
+			pkg.Origin = field.Value
+		case "maintainer":
+			pkg.Maintainer = field.Value
+		case "commit":
+			pkg.RepoCommit = field.Value
+		case "datahash":
+			pkg.DataHash = field.Value
+		case "depend":
+			pkg.Dependencies = append(pkg.Dependencies, field.Value)
+		case "provides":
+			pkg.Provides = append(pkg.Provides, field.Value)
+		case "install_if":
+			pkg.InstallIf = append(pkg.InstallIf, field.Value)
+		case "replaces":
+			pkg.Replaces = append(pkg.Replaces, field.Value)
+		case "size":
+			pkg.InstalledSize, _ = strconv.ParseUint(field.Value, 10, 64)
+		case "provider_priority":
+			pkg.ProviderPriority, _ = strconv.ParseUint(field.Value, 10, 64)
+		case "builddate":
+			pkg.BuildDate, _ = strconv.ParseInt(field.Value, 10, 64)
+			pkg.BuildTime = time.Unix(pkg.BuildDate, 0).UTC()
+		}
+	}
+	if pkg.Name == "" || pkg.Version == "" {
+		return nil, fmt.Errorf("no pkgname or pkgver in .PKGINFO of %s", apkPath)
+	}
+	return pkg, nil
+}
+
+// streamLocalPackages calls handle for the package of each .apk file in dir, in file name order
+func streamLocalPackages(dir string, handle func(pkg *repository.Package)) error {
+	apkPaths, err := filepath.Glob(filepath.Join(dir, "*.apk"))
+	if err != nil {
+		return err
+	}
+	if len(apkPaths) == 0 {
+		if _, err := os.Stat(dir); err != nil {
+			return err
+		}
+	}
+	sort.Strings(apkPaths)
+	for _, apkPath := range apkPaths {
+		pkg, err := packageFromAPK(apkPath)
+		if err != nil {
+			return err
+		}
+		handle(pkg)
+	}
+	return nil
+}
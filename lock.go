@@ -0,0 +1,213 @@
+package main
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// The lock file types mirror the resolved lock file format of apko, apko.lock.json, so builds can be pinned from the
+// output of this tool
+
+// LockFile is an apko style resolved lock file
+type LockFile struct {
+	Version  string       `json:"version"`
+	Contents LockContents `json:"contents"`
+}
+
+// LockContents are the repositories and resolved packages of a lock file
+type LockContents struct {
+	Keyrings     []LockKeyring    `json:"keyring"`
+	Repositories []LockRepository `json:"repositories"`
+	Packages     []LockPackage    `json:"packages"`
+}
+
+// LockKeyring is a signing key of a lock file
+type LockKeyring struct {
+	Name string `json:"name"`
+	URL  string `json:"url"`
+}
+
+// LockRepository is a repository of a lock file
+type LockRepository struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Architecture string `json:"architecture"`
+}
+
+// LockPackage is a package pinned to an exact version in a lock file
+type LockPackage struct {
+	Name         string `json:"name"`
+	URL          string `json:"url"`
+	Version      string `json:"version"`
+	Architecture string `json:"architecture"`
+	Checksum     string `json:"checksum"`
+}
+
+// repositoryBaseURL returns the repository URL as written in /etc/apk/repositories, i.e. without the architecture and
+// APKINDEX.tar.gz, e.g. https://packages.wolfi.dev/os
+func repositoryBaseURL(APKINDEXurl string) string {
+	baseURL := strings.TrimSuffix(APKINDEXurl, "/APKINDEX.tar.gz")
+	if i := strings.LastIndex(baseURL, "/"); i >= 0 {
+		return baseURL[:i]
+	}
+	return baseURL
+}
+
+// packageURL returns the location of the apk of the package alongside the APKINDEX of the repository
+func packageURL(resolvedPackage indexedPackage) string {
+	return resolvedPackage.Repository.URL[:strings.LastIndex(resolvedPackage.Repository.URL, "/")+1] + resolvedPackage.Package.Filename()
+}
+
+// newLockFile builds the lock file of the resolved packages
+func newLockFile(resolvedPackages []indexedPackage) LockFile {
+	lockFile := LockFile{Version: "v1", Contents: LockContents{Keyrings: []LockKeyring{}, Repositories: []LockRepository{}, Packages: []LockPackage{}}}
+	lockedRepositories := make(map[string]struct{})
+	for _, resolvedPackage := range resolvedPackages {
+		pkg := resolvedPackage.Package
+		if _, found := lockedRepositories[resolvedPackage.Repository.URL]; !found {
+			lockedRepositories[resolvedPackage.Repository.URL] = struct{}{}
+			lockFile.Contents.Repositories = append(lockFile.Contents.Repositories, LockRepository{
+				Name:         repositoryBaseURL(resolvedPackage.Repository.URL),
+				URL:          resolvedPackage.Repository.URL,
+				Architecture: pkg.Arch,
+			})
+		}
+		lockFile.Contents.Packages = append(lockFile.Contents.Packages, LockPackage{
+			Name:         pkg.Name,
+			URL:          packageURL(resolvedPackage),
+			Version:      pkg.Version,
+			Architecture: pkg.Arch,
+			Checksum:     "Q1" + base64.StdEncoding.EncodeToString(pkg.Checksum),
+		})
+	}
+	return lockFile
+}
+
+// LockDrift is a locked package which no longer matches the repositories
+type LockDrift struct {
+	Name    string
+	Version string
+	// Reason is "missing" when the version is no longer in any repository or "checksum" when it was rebuilt
+	Reason          string
+	LockedChecksum  string
+	CurrentChecksum string `json:",omitempty"`
+}
+
+// findLockDrift returns the packages of the lock file whose pinned version is no longer in the repositories, or whose
+// checksum has changed. The versions found in the repositories are keyed by name=version.
+func findLockDrift(lockFile LockFile, currentChecksums map[string]string) []LockDrift {
+	var drift []LockDrift
+	for _, lockedPackage := range lockFile.Contents.Packages {
+		currentChecksum, found := currentChecksums[lockedPackage.Name+"="+lockedPackage.Version]
+		switch {
+		case !found:
+			drift = append(drift, LockDrift{Name: lockedPackage.Name, Version: lockedPackage.Version, Reason: "missing", LockedChecksum: lockedPackage.Checksum})
+		case currentChecksum != lockedPackage.Checksum:
+			drift = append(drift, LockDrift{Name: lockedPackage.Name, Version: lockedPackage.Version, Reason: "checksum", LockedChecksum: lockedPackage.Checksum, CurrentChecksum: currentChecksum})
+		}
+	}
+	return drift
+}
+
+// runLockVerify checks that every package pinned in the lock file is still in the repositories with the same checksum.
+// It returns exitConstraintViolated when any package has drifted.
+func runLockVerify(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s lock verify FILE\n", os.Args[0])
+		return exitUsage
+	}
+	content, err := os.ReadFile(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read lock file: %v\n", err)
+		return exitUsage
+	}
+	var lockFile LockFile
+	if err := json.Unmarshal(content, &lockFile); err != nil {
+		fmt.Fprintf(stderr, "Failed to parse lock file %s: %v\n", args[0], err)
+		return exitUsage
+	}
+
+	lockedPackageNames := make(map[string]struct{})
+	for _, lockedPackage := range lockFile.Contents.Packages {
+		lockedPackageNames[lockedPackage.Name] = struct{}{}
+	}
+	currentChecksums := make(map[string]string)
+	_, err = forEachIndexedPackage(repositories, authToken, func(_ Repository, pkg *repository.Package) {
+		if _, found := lockedPackageNames[pkg.Name]; found {
+			currentChecksums[pkg.Name+"="+pkg.Version] = "Q1" + base64.StdEncoding.EncodeToString(pkg.Checksum)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	drift := findLockDrift(lockFile, currentChecksums)
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(drift, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	} else {
+		for _, drifted := range drift {
+			if drifted.Reason == "missing" {
+				fmt.Fprintf(stdout, "Package %s version %s is no longer in any repository\n", drifted.Name, drifted.Version)
+			} else {
+				fmt.Fprintf(stdout, "Package %s version %s has changed: checksum %s is now %s\n", drifted.Name, drifted.Version, drifted.LockedChecksum, drifted.CurrentChecksum)
+			}
+		}
+		if len(drift) == 0 {
+			fmt.Fprintf(stdout, "All %d locked packages are unchanged\n", len(lockFile.Contents.Packages))
+		}
+	}
+	if len(drift) > 0 {
+		return exitConstraintViolated
+	}
+	return 0
+}
+
+// runLock resolves the package constraints, and their dependencies, against the repositories and prints an apko style
+// resolved lock file pinning each package to an exact version
+func runLock(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) > 0 && args[0] == "verify" {
+		return runLockVerify(args[1:], repositories, authToken, asJSON)
+	}
+	if len(args) == 0 {
+		fmt.Fprintf(stderr, "Usage: %s lock CONSTRAINT...\n       %s lock verify FILE\n", os.Args[0], os.Args[0])
+		return exitUsage
+	}
+	var constraints []Constraint
+	for _, arg := range args {
+		constraint, err := parseConstraint(arg)
+		if err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+		constraints = append(constraints, constraint)
+	}
+
+	index, err := buildPackageIndex(repositories, authToken)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	resolvedPackages, err := index.resolve(constraints)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to resolve packages: %v\n", err)
+		return exitNoMatches
+	}
+	jsonOutput, err := json.MarshalIndent(newLockFile(resolvedPackages), "", "  ")
+	if err != nil {
+		fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+		return exitUsage
+	}
+	fmt.Fprintln(stdout, string(jsonOutput))
+	return 0
+}
@@ -1,3 +1,5 @@
+//go:build !(js && wasm)
+
 // Author: Phil Roche - phil.roche@chainguard.dev
 // Date: 20240808
 // This is a simple CLI tool that lists the latest version of a given package across all wolfi repositories
@@ -8,36 +10,15 @@
 package main
 
 import (
-	"encoding/base64"
-	"encoding/json"
+	"errors"
 	"flag"
 	"fmt"
-	"github.com/dustin/go-humanize"
-	"github.com/knqyf263/go-apk-version"
 	"gitlab.alpinelinux.org/alpine/go/repository"
-	"io"
-	"log"
-	"net/http"
 	"os"
-	"path/filepath"
-	"regexp"
-	"sort"
+	"strings"
 	"time"
 )
 
-func isValidRegex(pattern string) bool {
-	_, err := regexp.Compile(pattern)
-	return err == nil
-}
-
-func matchRegex(s string, pattern string) bool {
-	regex, err := regexp.Compile(pattern)
-	if err != nil {
-		log.Fatalf("Invalid regex pattern: %v", err)
-	}
-	return regex.MatchString(s)
-}
-
 func getEnvOrFlag(envName string, flagValue *string) string {
 	if value, exists := os.LookupEnv(envName); exists {
 		return value
@@ -45,282 +26,714 @@ func getEnvOrFlag(envName string, flagValue *string) string {
 	return *flagValue
 }
 
-func removeDuplicates(stringsList []string) []string {
-	seen := make(map[string]struct{})
-	var result []string
-
-	for _, item := range stringsList {
-		if _, found := seen[item]; !found {
-			seen[item] = struct{}{}
-			result = append(result, item)
+// parseArgs parses the command line flags which, unlike with flag.Parse, may be interspersed with the positional
+// arguments, e.g. `latest python-3.12 --repo wolfi`. Everything following `--` is treated as a positional argument.
+func parseArgs(arguments []string) []string {
+	var positional []string
+	for {
+		_ = flag.CommandLine.Parse(arguments)
+		remaining := flag.Args()
+		if len(remaining) == 0 {
+			return positional
 		}
+		if consumed := len(arguments) - len(remaining); consumed > 0 && arguments[consumed-1] == "--" {
+			return append(positional, remaining...)
+		}
+		positional = append(positional, remaining[0])
+		arguments = remaining[1:]
 	}
+}
 
-	return result
+// readPackageNames returns the package names queried, the non flag arguments and the lines of inputFile, with aliases
+// resolved. Only the non flag arguments are package names, otherwise flags would be matched as package names and listing
+// all packages would not be possible when any flag is specified.
+func readPackageNames(arguments []string, inputFile string) ([]string, error) {
+	packageNames := arguments
+	if inputFile != "" {
+		lines, err := readInputLines(inputFile)
+		if err != nil {
+			return nil, err
+		}
+		for _, line := range lines {
+			if line = strings.TrimSpace(line); line != "" && !strings.HasPrefix(line, "#") {
+				packageNames = append(packageNames, line)
+			}
+		}
+	}
+	for i, packageName := range packageNames {
+		packageNames[i] = resolveAlias(packageName)
+	}
+	return packageNames, nil
 }
 
 func main() {
 	matchAsRegex := flag.Bool("regex", false, "Parse package names as regex")
+	regexEngineFlag := flag.String("regex-engine", "re2", "Engine regex queries are matched with - re2, or pcre for Perl compatible lookaheads, lookbehinds and backreferences")
+	matchKind := flag.String("match", "exact", "How package names without a prefix such as re: or glob: are matched - "+strings.Join(matcherKindNames(), ", "))
 	listAllVersions := flag.Bool("all-versions", false, "List all matching package versions - not only the latest")
+	lastVersions := flag.Int("last", 0, "List the newest N versions of each matching package - between only the latest and --all-versions")
+	architecture := flag.String("arch", "", "Architecture of the packages to query - x86_64 or aarch64, default the architecture of this machine")
 	localAPKINDEX := flag.String("local-apkindex", "", "Path to a local APKINDEX file")
+	localPackages := flag.String("local-packages", "", "Path to a directory of .apk files, e.g. built by melange, to query as another repository")
 	localAuthToken := flag.String("auth-token", "", "Specify auth token to use when querying non public wolfi package repositories - enterprise-packages and extra-packages - use $(chainctl auth token --audience apk.cgr.dev). You can also set environment variable HTTP_AUTH.")
 	showParentPackageInformation := flag.Bool("show-parent-package", false, "This might be a sub package of a parent package, show the parent package information")
-	showSubPackageInformation := flag.Bool("show-sub-packages", false, "Show the sub package information. This will only take effect when a non regex package name filter is used.")
+	showSubPackageInformation := flag.Bool("show-sub-packages", false, "Show the sub package information.")
 	helpText := flag.Bool("help", false, "Display usage information")
 	outputJSON := flag.Bool("json", false, "Render output in JSON format")
-	flag.Parse()
-	httpBasicAuthPassword := getEnvOrFlag("HTTP_AUTH", localAuthToken)
-	if httpBasicAuthPassword == "" {
-		fmt.Print("Specifying an auth token is required. Use `chainctl auth token --audience apk.cgr.dev` to get the required token. Please enter token now - alternatively, you can also specify this via --auth-token flag or by setting HTTP_AUTH environment variable: ")
-		_, _ = fmt.Scanln(&httpBasicAuthPassword)
+	jsonErrors := flag.Bool("json-errors", false, "With --json, nest the results under a results key alongside an errors array of the repositories which could not be loaded and the queries which matched no package, rather than failing or warning on stderr")
+	outputFormat := flag.String("format", "text", "Output format - text, json, apk, junit or gha for the check and outdated commands, wolfictl for the outdated command, or sarif for the advisories command")
+	showMatchedQueries := flag.Bool("show-matched-queries", false, "Show which of the specified package names or regular expressions matched each package")
+	countOnly := flag.Bool("count", false, "Only print the number of matching packages")
+	countPerRepository := flag.Bool("count-per-repository", false, "Only print the number of matching packages in each repository")
+	inputFile := flag.String("input-file", "", "Read package names, or regular expressions with --regex, one per line from this file, or stdin when -, in addition to those specified as arguments")
+	openInBrowser := flag.Bool("open", false, "Open the melange build definition, or the homepage, of the first matching package in the default browser")
+	mergeRepositories := flag.Bool("merge-repositories", false, "Print the same version of a package in several repositories once, listing all the repositories, and flag builds whose checksums differ between them")
+	explain := flag.Bool("explain", false, "Explain why each package was included - which query matched, whether it matched the name, origin or a virtual provide, and the repository it was first seen in")
+	showSiblings := flag.Bool("show-siblings", false, "Show the other packages built from the same origin package as each matching sub package")
+	showURL := flag.Bool("show-url", false, "Show the homepage URL of the upstream project of each package")
+	resolveParent := flag.Bool("resolve-parent", false, "Also show the versions of the parent/origin package of each matching sub package")
+	subPackageDetails := flag.Bool("sub-package-details", false, "List each sub package with its own latest version, build time and repository in origins output, as objects in JSON output rather than names")
+	showDefinitions := flag.Bool("show-definitions", false, "Show the URL of the melange build definition of the origin package of each wolfi os package")
+	readReplacesPriority := flag.Bool("replaces-priority", false, "Download the apk of each package which replaces others to read its replaces_priority, which the APKINDEX does not record - for info and --json output")
+	showSizes := flag.Bool("show-sizes", false, "Show the human readable size and installed size of each package version")
+	timeFormatFlag := flag.String("time-format", "", "Format of times in text output - relative, rfc3339, unix or a Go time layout such as 2006-01-02 - by default both relative and full times")
+	utc := flag.Bool("utc", false, "Render times in text output in UTC")
+	timezone := flag.String("timezone", "", "Render times in text output in this IANA time zone, e.g. Europe/Dublin")
+	sortOrder := flag.String("sort", "name", "Order of the packages in text and apk output - name or buildtime for the most recently built packages first")
+	showSummary := flag.Bool("summary", false, "Print summary statistics after the results")
+	showTimings := flag.Bool("timings", false, "Print the download, decompress+parse and match time per repository and the peak memory used to stderr")
+	groupBy := flag.String("group-by", "", "Nest the results in text and JSON output under each query - query is the only grouping")
+	apkRepositoriesFile := flag.String("apk-repositories", "", "Query the repositories listed in this file, in order, instead of the default repositories - in the format of /etc/apk/repositories, one base URL or directory per line optionally prefixed with @TAG")
+	showPolicy := flag.Bool("policy", false, "Show the candidate version per repository and which repository apk would install from, like `apk policy`")
+	var orgs stringSliceFlag
+	flag.Var(&orgs, "org", "Also query the apk repository of this cgr.dev organization, https://apk.cgr.dev/NAME/ARCH/APKINDEX.tar.gz, which requires an auth token. Can be specified multiple times.")
+	var indices stringSliceFlag
+	flag.Var(&indices, "indices", "APKINDEX of a repository specified as NAME=URL, replacing the URL of the wolfi, enterprise, extra or local repository or adding a public repository named NAME. Can be specified multiple times, or as a comma separated list in WOLFI_PACKAGE_STATUS_INDICES.")
+	var repositoryRenames stringSliceFlag
+	flag.Var(&repositoryRenames, "repo-name", "Name of a repository used in output specified as REPOSITORY=NAME, e.g. enterprise=cgr-private. Can be specified multiple times.")
+	var mirrors stringSliceFlag
+	flag.Var(&mirrors, "mirror", "Mirror of a repository specified as REPOSITORY=URL, tried in order when fetching from the repository fails. Can be specified multiple times.")
+	flag.BoolVar(&lowMemory, "low-memory", false, "Decompress each APKINDEX to a temporary file and parse it from disk, rather than in memory, to keep the peak memory use predictable for very large indices")
+	flag.BoolVar(&verbose, "verbose", false, "Print verbose output, e.g. which mirror served each APKINDEX, to stderr")
+	minVersion := flag.String("min-version", "", "Only include package versions from this version up, compared as apk compares versions")
+	maxVersion := flag.String("max-version", "", "Only include package versions up to and including this version, compared as apk compares versions")
+	exactVersion := flag.String("version", "", "Only show packages at exactly this version, e.g. 3.12.5-r1, reporting the repositories which have the package but not this version")
+	strict := flag.Bool("strict", false, "Exit non zero when skew finds a non public repository serving an older version of a package than a public repository, arch-skew finds a version mismatch between architectures, or a query matches more packages than --max-matches")
+	maxMatches := flag.Int("max-matches", 0, "Warn when a single query matches more than this number of packages, usually a regex missing an anchor, failing without printing the results with --strict - default no limit")
+	matchOrigin := flag.Bool("match-origin", false, "Match package names, or regexes with --regex, against the origin package and list each matching origin package with all of its sub packages and their versions")
+	originFilter := flag.String("origin", "", "Only show packages whose origin package is this name, or matches this regex when --regex is used")
+	var requires stringSliceFlag
+	flag.Var(&requires, "require", "Requirement evaluated by the check command, a package name optionally with a version constraint such as python-3.12>=3.12.4. Can be specified multiple times.")
+	var repositorySelectors stringSliceFlag
+	flag.Var(&repositorySelectors, "repo", "Only include results from the repository with this ID or name - wolfi, enterprise, extra or local. Can be specified multiple times.")
+	flag.Var(&repositorySelectors, "against", "The repository with this ID or name to compare a local APKINDEX against with the diff command - the same as --repo. Can be specified multiple times.")
+	signingKey := flag.String("signing-key", "", "Path to the RSA private key to sign the APKINDEX written by the index command with, e.g. melange.rsa")
+	diffLocal := flag.String("local", "", "Path to a locally built APKINDEX.tar.gz to compare against the repositories with the diff command")
+	configPath := flag.String("config", "", "Path to the configuration file - default config.yaml in the wolfi-package-status directory of the user configuration directory")
+	var ignorePatterns stringSliceFlag
+	flag.Var(&ignorePatterns, "ignore", "Exclude packages whose whole name is this name or matches this regex from all output, in addition to those ignored in the configuration file. Can be specified multiple times.")
+	watchInterval := flag.Duration("interval", 15*time.Minute, "Time between polls of the repositories in watch mode, e.g. 5m or 1h")
+	listenAddress := flag.String("listen", "", "Address to serve the Atom feed of package changes on in watch mode, the API in serve mode or the repositories in proxy mode, e.g. localhost:8080")
+	var slackWebhooks, discordWebhooks stringSliceFlag
+	flag.Var(&slackWebhooks, "notify-slack", "Slack incoming webhook URL to post new package versions found in watch mode to. Can be specified multiple times.")
+	flag.Var(&discordWebhooks, "notify-discord", "Discord webhook URL to post new package versions found in watch mode to. Can be specified multiple times.")
+	notifyTemplate := flag.String("notify-template", "", "Go template formatting each new package version in Slack and Discord notifications, e.g. '{{.Name}} {{.Version}} ({{.Repository}})'")
+	onChangeCommand := flag.String("on-change", "", "Shell command run for each new package version found in watch mode, with the change as JSON on stdin")
+	dumpParquet := flag.Bool("parquet", false, "Write the dump command output in parquet format")
+	dumpCSV := flag.Bool("csv", false, "Write the dump command output in CSV format")
+	socketPath := flag.String("socket", "", "Path of the Unix socket of the daemon started with the serve command - default daemon.sock in the wolfi-package-status directory of the user state directory, e.g. ~/.local/state/wolfi-package-status")
+	publicOnly := flag.Bool("public-only", false, "Only query public repositories, never prompting for, reading or sending an auth token, e.g. in untrusted CI")
+	dryRun := flag.Bool("dry-run", false, "Print which APKINDEX URLs would be fetched, with which auth token source and architecture, and which matchers would be applied, without fetching anything")
+	noDaemon := flag.Bool("no-daemon", false, "Always fetch the APKINDEX of each repository, even when a daemon is running")
+	// profiling flags are not listed in the help text as they are only of use to investigate performance
+	cpuProfile := flag.String("pprof-cpu", "", "Write a CPU profile of the run to this file")
+	memProfile := flag.String("pprof-mem", "", "Write a heap profile at the end of the run to this file")
+	traceFile := flag.String("trace", "", "Write an execution trace of the run to this file")
+	proxyMaxAge := flag.Duration("max-age", 5*time.Minute, "How long the proxy serves a cached APKINDEX before revalidating it with the repository")
+	// flags can also be set from the environment, e.g. WOLFI_PACKAGE_STATUS_JSON=true for --json, with flags specified
+	// on the command line taking precedence
+	if err := setFlagsFromEnv(flag.CommandLine); err != nil {
+		fmt.Fprintf(stdout, "Invalid environment variable %v\n", err)
+		os.Exit(exitUsage)
+	}
+	arguments := parseArgs(os.Args[1:])
+	switch *outputFormat {
+	case "text", "apk":
+	case "json":
+		*outputJSON = true
+	case "junit", "gha":
+		if len(arguments) == 0 || (arguments[0] != "check" && arguments[0] != "outdated") {
+			fmt.Fprintf(stdout, "Output format %s is only supported by the check and outdated commands\n", *outputFormat)
+			os.Exit(exitUsage)
+		}
+	case "wolfictl":
+		if len(arguments) == 0 || arguments[0] != "outdated" {
+			fmt.Fprintln(stdout, "Output format wolfictl is only supported by the outdated command")
+			os.Exit(exitUsage)
+		}
+	case "sarif":
+		if len(arguments) == 0 || arguments[0] != "advisories" {
+			fmt.Fprintln(stdout, "Output format sarif is only supported by the advisories command")
+			os.Exit(exitUsage)
+		}
+	default:
+		fmt.Fprintf(stdout, "Unsupported output format %s - supported formats are text, json, apk, junit, gha, wolfictl and sarif\n", *outputFormat)
+		os.Exit(exitUsage)
+	}
+	if *outputJSON {
+		*outputFormat = "json"
+	}
+	if err := setTimeFormat(*timeFormatFlag, *utc, *timezone); err != nil {
+		fmt.Fprintf(stdout, "Invalid time format: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if err := setRegexEngine(*regexEngineFlag); err != nil {
+		fmt.Fprintf(stdout, "Invalid --regex-engine: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if err := setDefaultMatcherKind(*matchKind); err != nil {
+		fmt.Fprintf(stdout, "Invalid --match: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	if *groupBy != "" && *groupBy != "query" {
+		fmt.Fprintf(stdout, "Unsupported grouping %s - the only supported grouping is query\n", *groupBy)
+		os.Exit(exitUsage)
+	}
+	if *groupBy != "" && *outputFormat == "apk" {
+		fmt.Fprintln(stdout, "Option --group-by is not supported by output format apk")
+		os.Exit(exitUsage)
+	}
+	if *sortOrder != "name" && *sortOrder != "buildtime" {
+		fmt.Fprintf(stdout, "Unsupported sort order %s - supported orders are name and buildtime\n", *sortOrder)
+		os.Exit(exitUsage)
+	}
+	// the hidden profiling flags write profiles of the run, to investigate performance regressions in parsing and
+	// matching without rebuilding the tool
+	if err := startProfiling(*cpuProfile, *memProfile, *traceFile); err != nil {
+		fmt.Fprintf(stdout, "Failed to start profiling: %v\n", err)
+		os.Exit(exitUsage)
+	}
+	defer stopProfiling()
+	// diff-json only reads local files so never needs an auth token
+	if len(arguments) > 0 && arguments[0] == "diff-json" && !*helpText {
+		exit(runDiffJSON(arguments[1:], *outputJSON))
+	}
+	// index only reads local files so never needs an auth token
+	if len(arguments) > 0 && arguments[0] == "index" && !*helpText {
+		exit(runIndex(arguments[1:], *signingKey))
 	}
 	if *helpText {
-		fmt.Printf("Usage: %s [options] [package names]\n", os.Args[0])
-		fmt.Println("\t* Multiple package names can be specified separated by space")
-		fmt.Println("\t* Option `--regex` can be used to match package names on specified regular expression. Multiple regular expressions can be specified separated by space")
-		fmt.Println("\t* Option `--all-versions` can be used to list all package versions, not only the latest.")
-		fmt.Println("\t* Option `--auth-token` specify auth token to use when querying wolfi non public package repositories - enterprise-packages and extra-packages - use $(chainctl auth token --audience apk.cgr.dev). You can also set environment variable HTTP_AUTH.")
-		fmt.Println("\t* Option `--show-parent-package` can be used to show the parent package information as the package being queried might be defined as a sub package.")
-		fmt.Println("\t* Option `--show-sub-packages` can be used to show the sub package information as the package being queried might be defined as a parent/origin package. This will only take effect when a non regex package name filter is used.")
-		fmt.Println("\t* Option `--local-apkindex` can be used to specify a local APKINDEX.tar.gz file to use instead of querying remote repositories.")
-		fmt.Println("\t* Option `--json` can be used to render output in JSON format.")
-		fmt.Println("\t* Option `--help` can be used to display this usage message")
-		os.Exit(0)
-	}
-	packageNames := []string{}
-	args := os.Args
-	if len(args) > 1 {
-		for i := 1; i < len(args); i++ {
-			packageNames = append(packageNames, args[i])
-		}
-	}
-	var APKINDEXURLs = make(map[string]string)
-
-	if *localAPKINDEX != "" {
-		APKINDEXURLs["local apkindex"] = *localAPKINDEX
-	} else {
-		APKINDEXURLs["wolfi os"] = "https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz"
-		APKINDEXURLs["enterprise packages"] = "https://apk.cgr.dev/chainguard-private/x86_64/APKINDEX.tar.gz"
-		APKINDEXURLs["extra packages"] = "https://apk.cgr.dev/extra-packages/x86_64/APKINDEX.tar.gz"
+		fmt.Fprintf(stdout, "Usage: %s [options] [package names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] latest PACKAGE\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] outdated [FILE]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] files PACKAGE[=VERSION]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] info PACKAGE[=VERSION]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] pkginfo PACKAGE[=VERSION]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] verify FILE.apk\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] skew [package names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] streams [project names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] auth check\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] repos [discover]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] arches\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] arch-skew [package names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] removed [OLD_APKINDEX.tar.gz]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] origins [origin package names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] consumers so:SONAME\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] lock CONSTRAINT...\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] lock verify FILE\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] track report [FILE]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] check --require REQUIREMENT...\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] advisories [FILE]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] watch [package names]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] diff --local APKINDEX.tar.gz [--against REPOSITORY]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] diff-json OLD.json NEW.json\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] index DIR\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] dump --csv|--parquet [FILE]\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] serve\n", os.Args[0])
+		fmt.Fprintf(stdout, "       %s [options] proxy --listen ADDRESS\n", os.Args[0])
+		fmt.Fprintln(stdout, "\t* Multiple package names can be specified separated by space")
+		fmt.Fprintln(stdout, "\t* Virtual provides such as `cmd:python3`, `so:libcrypto.so.3` or `pc:libffi` can be specified instead of package names.")
+		fmt.Fprintln(stdout, "\t* Options can be specified before or after package names. Use `--` to stop option parsing.")
+		fmt.Fprintln(stdout, "\t* Option `--regex` can be used to match package names on specified regular expression. Multiple regular expressions can be specified separated by space")
+		fmt.Fprintln(stdout, "\t* Option `--regex-engine pcre` can be used to match regex queries with a Perl compatible engine.")
+		fmt.Fprintln(stdout, "\t* Option `--match KIND` can be used to match package names as exact (default), regex, glob or fuzzy.")
+		fmt.Fprintln(stdout, "\t* Option `--input-file FILE` can be used to read package names one per line from FILE, or stdin when FILE is `-`.")
+		fmt.Fprintln(stdout, "\t* Option `--all-versions` can be used to list all package versions, not only the latest.")
+		fmt.Fprintln(stdout, "\t* Option `--last N` can be used to list the newest N versions of each package.")
+		fmt.Fprintln(stdout, "\t* Option `--auth-token` specify auth token to use when querying wolfi non public package repositories - enterprise-packages and extra-packages - use $(chainctl auth token --audience apk.cgr.dev). You can also set environment variable HTTP_AUTH.")
+		fmt.Fprintln(stdout, "\t* Option `--show-parent-package` can be used to show the parent package information as the package being queried might be defined as a sub package.")
+		fmt.Fprintln(stdout, "\t* Option `--resolve-parent` can be used to also show the parent/origin package of each matching sub package.")
+		fmt.Fprintln(stdout, "\t* Option `--show-sub-packages` can be used to show the sub package information as the package being queried might be defined as a parent/origin package.")
+		fmt.Fprintln(stdout, "\t* Option `--show-siblings` can be used to list the other packages built from the same origin as each matching sub package.")
+		fmt.Fprintln(stdout, "\t* Option `--origin NAME` can be used to only show packages whose parent/origin package is NAME.")
+		fmt.Fprintln(stdout, "\t* Option `--match-origin` can be used to match package names against the parent/origin package rather than the package name.")
+		fmt.Fprintln(stdout, "\t* Option `--version VERSION` can be used to only show packages at exactly VERSION.")
+		fmt.Fprintln(stdout, "\t* Options `--min-version VERSION` and `--max-version VERSION` can be used to only include versions in a range.")
+		fmt.Fprintln(stdout, "\t* Option `--arch ARCH` can be used to query the x86_64 or aarch64 packages.")
+		fmt.Fprintln(stdout, "\t* Option `--local-apkindex` can be used to specify a local APKINDEX.tar.gz file to use instead of querying remote repositories.")
+		fmt.Fprintln(stdout, "\t* Option `--local-packages DIR` can be used to query a directory of .apk files as another repository.")
+		fmt.Fprintln(stdout, "\t* Option `--apk-repositories FILE` can be used to query the repositories listed in FILE, e.g. /etc/apk/repositories.")
+		fmt.Fprintln(stdout, "\t* Option `--org NAME` can be used to also query the apk repository of a cgr.dev organization.")
+		fmt.Fprintln(stdout, "\t* Option `--indices NAME=URL` can be used to set the APKINDEX.tar.gz URL of a repository or add a public repository.")
+		fmt.Fprintln(stdout, "\t* Option `--mirror REPOSITORY=URL` can be used to add a mirror APKINDEX.tar.gz URL to a repository.")
+		fmt.Fprintln(stdout, "\t* Option `--repo` can be used to only include results from the repository with this ID or name.")
+		fmt.Fprintln(stdout, "\t* Option `--repo-name REPOSITORY=NAME` can be used to name a repository in output.")
+		fmt.Fprintln(stdout, "\t* Option `--public-only` can be used to only query public repositories, never reading or sending the auth token.")
+		fmt.Fprintln(stdout, "\t* Option `--json` can be used to render output in JSON format.")
+		fmt.Fprintln(stdout, "\t* Option `--json-errors` can be used with `--json` to include the problems which did not stop the query in an `errors` array.")
+		fmt.Fprintln(stdout, "\t* Option `--format` can be used to select the output format - `text` (default), `json` or `apk`.")
+		fmt.Fprintln(stdout, "\t* Option `--show-url` can be used to show the homepage URL of each package.")
+		fmt.Fprintln(stdout, "\t* Option `--show-definitions` can be used to show the URL of the melange build definition of each package.")
+		fmt.Fprintln(stdout, "\t* Option `--open` can be used to open the build definition of the first matching package in the default browser.")
+		fmt.Fprintln(stdout, "\t* Option `--show-sizes` can be used to show the size and installed size of each package version.")
+		fmt.Fprintln(stdout, "\t* Option `--replaces-priority` can be used to download the apk of packages which replace others to read their replaces_priority.")
+		fmt.Fprintln(stdout, "\t* Option `--show-matched-queries` can be used to show which of the specified package names or regular expressions matched each package.")
+		fmt.Fprintln(stdout, "\t* Option `--explain` can be used to show why each package was included.")
+		fmt.Fprintln(stdout, "\t* Option `--merge-repositories` can be used to print the same version found in several repositories once.")
+		fmt.Fprintln(stdout, "\t* Option `--group-by query` can be used to nest the results under each query.")
+		fmt.Fprintln(stdout, "\t* Option `--sort` can be used to sort the packages by `name` (default) or `buildtime`.")
+		fmt.Fprintln(stdout, "\t* Option `--time-format` can be used to select how times are rendered in text output.")
+		fmt.Fprintln(stdout, "\t* Options `--utc` and `--timezone ZONE` can be used to render times in UTC or in an IANA time zone.")
+		fmt.Fprintln(stdout, "\t* Option `--count` can be used to only print the number of matching packages.")
+		fmt.Fprintln(stdout, "\t* Option `--count-per-repository` can be used to only print the number of matching packages in each repository queried.")
+		fmt.Fprintln(stdout, "\t* Option `--summary` can be used to print summary statistics after the results.")
+		fmt.Fprintln(stdout, "\t* Option `--timings` can be used to print the time and memory taken for each repository to stderr.")
+		fmt.Fprintln(stdout, "\t* Option `--policy` can be used to show, like `apk policy`, which repository and version apk would install.")
+		fmt.Fprintln(stdout, "\t* Option `--max-matches N` can be used to warn when a query matches more than N packages, failing with `--strict`.")
+		fmt.Fprintln(stdout, "\t* Option `--dry-run` can be used to print what would be fetched and matched without any network calls.")
+		fmt.Fprintln(stdout, "\t* Option `--low-memory` can be used to parse each APKINDEX from a temporary file rather than in memory.")
+		fmt.Fprintln(stdout, "\t* Option `--config FILE` can be used to specify the configuration file.")
+		fmt.Fprintln(stdout, "\t* Option `--ignore PATTERN` can be used to exclude packages matching PATTERN from all output.")
+		fmt.Fprintln(stdout, "\t* Option `--verbose` can be used to print verbose output to stderr.")
+		fmt.Fprintln(stdout, "\t* Command `latest PACKAGE` prints only the newest version string of the package.")
+		fmt.Fprintln(stdout, "\t* Command `info PACKAGE[=VERSION]` prints the APKINDEX entry and dependency footprint of a package.")
+		fmt.Fprintln(stdout, "\t* Command `files PACKAGE[=VERSION]` lists the files a package installs.")
+		fmt.Fprintln(stdout, "\t* Command `pkginfo PACKAGE[=VERSION]` prints the .PKGINFO fields of a package.")
+		fmt.Fprintln(stdout, "\t* Command `verify FILE.apk` checks the checksum of a local apk file against the repositories.")
+		fmt.Fprintln(stdout, "\t* Command `outdated [FILE]` reports which name=version pairs are behind the repositories.")
+		fmt.Fprintln(stdout, "\t* Command `advisories [FILE]` reports the vulnerabilities affecting name=version pairs.")
+		fmt.Fprintln(stdout, "\t* Command `check --require REQUIREMENT...` evaluates presence and version requirements.")
+		fmt.Fprintln(stdout, "\t* Command `lock CONSTRAINT...` resolves package constraints and their dependencies into a lock file.")
+		fmt.Fprintln(stdout, "\t* Command `lock verify FILE` checks every package pinned in a lock file is unchanged in the repositories.")
+		fmt.Fprintln(stdout, "\t* Command `track report [FILE]` reports the latest version, constraint violations and staleness of tracked packages.")
+		fmt.Fprintln(stdout, "\t* Command `consumers so:SONAME` lists the packages providing a shared library and every package depending on it.")
+		fmt.Fprintln(stdout, "\t* Command `origins [origin package names]` lists each origin package with its sub packages.")
+		fmt.Fprintln(stdout, "\t* Command `streams [project names]` lists the upstream projects with several versioned streams.")
+		fmt.Fprintln(stdout, "\t* Command `skew [package names]` reports the version skew between repositories.")
+		fmt.Fprintln(stdout, "\t* Command `arch-skew [package names]` reports the packages whose latest version differs between architectures.")
+		fmt.Fprintln(stdout, "\t* Command `arches` reports which architectures each repository publishes.")
+		fmt.Fprintln(stdout, "\t* Command `removed [OLD_APKINDEX.tar.gz]` reports packages which disappeared from each repository.")
+		fmt.Fprintln(stdout, "\t* Command `diff --local APKINDEX.tar.gz` validates a locally built APKINDEX against the published repositories.")
+		fmt.Fprintln(stdout, "\t* Command `diff-json OLD.json NEW.json` prints the changelog between two saved `--json` outputs.")
+		fmt.Fprintln(stdout, "\t* Command `index DIR` writes DIR/APKINDEX.tar.gz listing the .apk files in DIR.")
+		fmt.Fprintln(stdout, "\t* Command `dump --csv|--parquet [FILE]` writes every package of the repositories with all of its metadata.")
+		fmt.Fprintln(stdout, "\t* Command `watch [package names]` polls the repositories every `--interval` and reports new package versions.")
+		fmt.Fprintln(stdout, "\t* Option `--listen ADDRESS` can be used in watch mode to serve an Atom feed of the new package versions.")
+		fmt.Fprintln(stdout, "\t* Options `--notify-slack WEBHOOK` and `--notify-discord WEBHOOK` can be used in watch mode to post new package versions.")
+		fmt.Fprintln(stdout, "\t* Option `--notify-template TEMPLATE` can be used to change the Go template formatting notifications.")
+		fmt.Fprintln(stdout, "\t* Option `--on-change CMD` can be used in watch mode to run CMD for each new package version.")
+		fmt.Fprintln(stdout, "\t* Command `serve` runs a daemon which keeps the parsed indices in memory and answers queries on a Unix socket.")
+		fmt.Fprintln(stdout, "\t* Option `--socket PATH` can be used to specify the Unix socket of the daemon.")
+		fmt.Fprintln(stdout, "\t* Option `--no-daemon` can be used to always download the indices, even when a daemon is running.")
+		fmt.Fprintln(stdout, "\t* Command `proxy --listen ADDRESS` serves cached copies of the APKINDEX of each repository.")
+		fmt.Fprintln(stdout, "\t* Command `auth check` validates the auth token against each non public repository.")
+		fmt.Fprintln(stdout, "\t* Command `repos` lists the repositories and where the auth token was found.")
+		fmt.Fprintln(stdout, "\t* Every option can also be set with an environment variable, e.g. WOLFI_PACKAGE_STATUS_ALL_VERSIONS=true.")
+		fmt.Fprintln(stdout, "\t* Option `--help` can be used to display this usage message")
+		fmt.Fprintln(stdout, "Exit codes:")
+		fmt.Fprintln(stdout, "\t* 0 - success")
+		fmt.Fprintln(stdout, "\t* 1 - invalid usage, e.g. an unknown option or a malformed input file, or another error")
+		fmt.Fprintln(stdout, "\t* 2 - a repository, package or security database could not be downloaded, e.g. a network failure or an invalid auth token")
+		fmt.Fprintln(stdout, "\t* 3 - no package matched the queries or a named package does not exist")
+		fmt.Fprintln(stdout, "\t* 4 - a check failed, e.g. an unmet requirement, an outdated package or a drifted lock file")
+		exit(0)
+	}
+	configFile := *configPath
+	if configFile == "" {
+		var err error
+		if configFile, err = defaultConfigPath(); err != nil {
+			fmt.Fprintf(stderr, "Failed to find configuration directory: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+	config, err := loadConfig(configFile, *configPath != "")
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to load configuration: %v\n", err)
+		exit(exitUsage)
+	}
+	if err := setIgnoredPackages(append(config.Ignore, ignorePatterns...)); err != nil {
+		fmt.Fprintf(stderr, "Invalid ignore list: %v\n", err)
+		exit(exitUsage)
+	}
+	packageAliases = config.Aliases
+	// the daemon and the proxy keep what they fetch in memory and never use the cache
+	if len(arguments) == 0 || (arguments[0] != "serve" && arguments[0] != "proxy") {
+		packageCache, err = openCache(config.Cache)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to open cache: %v\n", err)
+			exit(exitUsage)
+		}
 	}
 
-	var matchingPackagesAllVersions = make(map[string][]map[string]interface{})
-	var matchingPackagesLatestVersion = make(map[string]map[string]interface{})
-	var subPackageNames []string
-	//for each of the APKINDEXURLs create an instance of the repository class
-	for APKINDEXFriendlyName, APKINDEXurl := range APKINDEXURLs {
-		// check to see of APKINDEXurl is a local file
-		localAPKINDEXPath := ""
-		temporaryAPKINDEXdir := ""
-		if _, err := os.Stat(APKINDEXurl); err == nil {
-			localAPKINDEXPath = APKINDEXurl
-		} else {
-			// Download each of the APKINDEX files to temporary directory using "net/http"
-
-			// use localAuthToken if it is set when making request to non public repositories
-			// Create a new request
-			req, err := http.NewRequest("GET", APKINDEXurl, nil)
-			if err != nil {
-				fmt.Println("Error creating request:", err)
-				return
-			}
-
-			// Add the auth token to the request header but only for non public repositories
-			if httpBasicAuthPassword != "" && APKINDEXFriendlyName != "wolfi os" {
-				encodedAuth := base64.StdEncoding.EncodeToString([]byte("user:" + httpBasicAuthPassword))
-				req.Header.Set("Authorization", "Basic "+encodedAuth)
-			}
-
-			req.Header.Set("Accept", "application/gzip")
-			req.Header.Add("User-Agent", "curl/7.68.0")
+	if *socketPath == "" {
+		if *socketPath, err = defaultDaemonSocket(); err != nil {
+			fmt.Fprintf(stderr, "Failed to find state directory: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+	// runs load packages from a running daemon rather than downloading the indices again
+	if _, err := os.Stat(*socketPath); err == nil && !*noDaemon && (len(arguments) == 0 || arguments[0] != "serve") {
+		daemonSocket = *socketPath
+	}
 
-			// Send the request via a client
-			client := &http.Client{}
-			resp, err := client.Do(req)
-			if err != nil {
-				fmt.Printf("Failed to download APKINDEX file %s: %v\n", APKINDEXurl, err)
-				os.Exit(1)
-			}
+	var repositories []Repository
+	// arch is the architecture queried, unknown for a local APKINDEX
+	var arch string
 
-			// write the response variable resp to a file in a temporary directory
-			defer resp.Body.Close()
-			// Create a temporary directory
-			temporaryAPKINDEXdir, err = os.MkdirTemp("", "wolfi-package-status")
-			if err != nil {
-				fmt.Printf("Failed to create temporary directory %s: %v\n", temporaryAPKINDEXdir, err)
-				os.Exit(1)
+	if *localAPKINDEX != "" {
+		// the security database of a local repository is expected alongside its APKINDEX
+		localSecurityDB := (*localAPKINDEX)[:strings.LastIndex(*localAPKINDEX, "/")+1] + "security.json"
+		repositories = []Repository{{ID: "local", Name: "local apkindex", URL: *localAPKINDEX, SecurityDB: localSecurityDB}}
+	} else {
+		if arch, err = apkArch(*architecture); err != nil {
+			fmt.Fprintf(stdout, "Invalid --arch: %v\n", err)
+			exit(exitUsage)
+		}
+		logVerbose("Querying %s packages", arch)
+		repositories = defaultRepositories(arch)
+		if *apkRepositoriesFile != "" {
+			if repositories, err = readAPKRepositories(*apkRepositoriesFile, arch); err != nil {
+				fmt.Fprintf(stdout, "Invalid --apk-repositories: %v\n", err)
+				exit(exitUsage)
 			}
+		}
+		// organizations from the configuration file are queried as if specified with --org
+		organizationRepositories, err := orgRepositories(removeDuplicates(append(config.Orgs, orgs...)), arch)
+		if err != nil {
+			fmt.Fprintf(stdout, "Invalid --org: %v\n", err)
+			exit(exitUsage)
+		}
+		repositories = append(repositories, organizationRepositories...)
+	}
+	if repositories, err = addIndices(repositories, indices, arch); err != nil {
+		fmt.Fprintf(stdout, "Invalid --indices: %v\n", err)
+		exit(exitUsage)
+	}
+	if *localPackages != "" {
+		repositories = append(repositories, localPackagesRepository(*localPackages))
+	}
+	// names from the configuration file are applied first so --repo-name can override them
+	var renames []string
+	for selector, name := range config.Names {
+		renames = append(renames, selector+"="+name)
+	}
+	if err := renameRepositories(repositories, append(renames, repositoryRenames...)); err != nil {
+		fmt.Fprintf(stdout, "Invalid repository name: %v\n", err)
+		exit(exitUsage)
+	}
+	if err := addMirrors(repositories, mirrors); err != nil {
+		fmt.Fprintf(stdout, "Invalid --mirror: %v\n", err)
+		exit(exitUsage)
+	}
 
-			// Create a file in the temporary directory
-			localAPKINDEXPath = filepath.Join(temporaryAPKINDEXdir, "APKINDEX.tar.gz")
-			localAPKINDEXfile, err := os.Create(localAPKINDEXPath)
-			if err != nil {
-				fmt.Printf("Failed to write APKINDEX file to temporary directory %s: %v\n", temporaryAPKINDEXdir, err)
-				os.Exit(1)
+	// repositories selected in the configuration file apply unless --repo is specified
+	if len(repositorySelectors) == 0 {
+		repositorySelectors = config.Repositories
+	}
+	selectedRepositories := repositories
+	if len(repositorySelectors) > 0 {
+		var err error
+		selectedRepositories, err = selectRepositories(repositories, repositorySelectors)
+		if err != nil {
+			fmt.Fprintf(stdout, "Invalid --repo: %v\n", err)
+			exit(exitUsage)
+		}
+	}
+	if *publicOnly {
+		for _, selectedRepository := range selectedRepositories {
+			if selectedRepository.RequiresAuth && len(repositorySelectors) > 0 {
+				fmt.Fprintf(stdout, "Invalid --repo: %s is not a public repository and --public-only is specified\n", selectedRepository.ID)
+				exit(exitUsage)
 			}
-			defer localAPKINDEXfile.Close()
-
-			// Write the response to file
-			_, err = io.Copy(localAPKINDEXfile, resp.Body)
 		}
-
-		indexFile, err := os.Open(localAPKINDEXPath)
-		apkIndex, err := repository.IndexFromArchive(indexFile)
-		packages := apkIndex.Packages
-		for _, _package := range packages {
-			var matchFound bool
-			if len(packageNames) > 0 {
-				for _, packageName := range packageNames {
-					if packageName == _package.Name {
-						matchFound = true
-					}
-					if *matchAsRegex && isValidRegex(packageName) && matchRegex(_package.Name, packageName) {
-						matchFound = true
-					}
-
-					if matchFound {
-						// if this is the first time we have encountered this package - ensure the inner map is initialized
-						matchingPackagesLatestVersionInnerMap := matchingPackagesLatestVersion[_package.Name]
-						if matchingPackagesLatestVersionInnerMap == nil {
-							matchingPackagesLatestVersionInnerMap = make(map[string]interface{})
-							matchingPackagesLatestVersion[_package.Name] = matchingPackagesLatestVersionInnerMap
-						}
-
-						// if this is the first time we have encountered this package - ensure the inner map is initialized
-						matchingPackagesAllVersionsInnerMap := matchingPackagesAllVersions[_package.Name]
-						if matchingPackagesAllVersionsInnerMap == nil {
-							matchingPackagesAllVersionsInnerMap = []map[string]interface{}{}
-							matchingPackagesAllVersions[_package.Name] = matchingPackagesAllVersionsInnerMap
-						}
-						// add the package to the list of all versions
-						matchingPackagesAllVersions[_package.Name] = append(
-							matchingPackagesAllVersions[_package.Name],
-							map[string]interface{}{
-								"Version":    _package.Version,
-								"BuildTime":  _package.BuildTime,
-								"Repository": APKINDEXFriendlyName,
-								"Origin":     _package.Origin,
-							},
-						)
-
-						// Now check to see if this is the latest version
-						latestVersion, latestVersionFound := matchingPackagesLatestVersion[_package.Name]["Version"].(string)
-						semver_latestVersion, _ := version.NewVersion(latestVersion)
-						semver_packageVersion, _ := version.NewVersion(_package.Version)
-						if !latestVersionFound || latestVersion == "" || semver_packageVersion.GreaterThan(semver_latestVersion) {
-							matchingPackagesLatestVersion[_package.Name]["Version"] = _package.Version
-							matchingPackagesLatestVersion[_package.Name]["BuildTime"] = _package.BuildTime
-							matchingPackagesLatestVersion[_package.Name]["Repository"] = APKINDEXFriendlyName
-							matchingPackagesLatestVersion[_package.Name]["Origin"] = _package.Origin
-						}
-					} else {
-						if *showSubPackageInformation && !*matchAsRegex {
-							//is there an origin of this package and if so does it match the package name filter
-							if _package.Origin != "" && _package.Origin == packageName {
-								subPackageNames = append(subPackageNames, _package.Name)
-							}
-						}
-					}
-				}
-			} else {
-				// we are not matching any packages here so print all found package names and versions
-				_parentPackageInformation := ""
-				if *showParentPackageInformation {
-					_parentPackageInformation = " - Parent/Origin package: " + _package.Origin
-				}
-				fmt.Printf("%s version %s (%s - %s) in %s repository%s\n", _package.Name, _package.Version, humanize.Time(_package.BuildTime), _package.BuildTime, APKINDEXFriendlyName, _parentPackageInformation)
+		repositories = publicRepositories(repositories)
+		selectedRepositories = publicRepositories(selectedRepositories)
+	}
+	// only the selected repositories are fetched, --policy compares the candidate versions of the selected repositories
+	// as apk would if only they were listed in /etc/apk/repositories
+	queriedRepositories := selectedRepositories
+	// a dry run reports what would be fetched and matched, without acquiring the auth token or any network calls
+	if *dryRun {
+		var credentialsSource, command string
+		if !*publicOnly {
+			_, credentialsSource = authTokenSource(*localAuthToken)
+		}
+		var packageNames []string
+		if len(arguments) > 0 && isCommand(arguments[0]) {
+			command = arguments[0]
+		} else if packageNames, err = readPackageNames(arguments, *inputFile); err != nil {
+			fmt.Fprintf(stdout, "Failed to read --input-file: %v\n", err)
+			exit(exitUsage)
+		}
+		exit(printDryRun(newDryRun(queriedRepositories, command, packageNames, *matchAsRegex, *matchOrigin, *originFilter, credentialsSource), *outputJSON))
+	}
+	// repos reports whether an auth token was found rather than prompting for one
+	if len(arguments) > 0 && arguments[0] == "repos" {
+		var authToken, credentialsSource string
+		if !*publicOnly {
+			authToken, credentialsSource = authTokenSource(*localAuthToken)
+		}
+		exit(runRepos(arguments[1:], selectedRepositories, authToken, credentialsSource, configFile, *outputJSON))
+	}
+	// the auth token is only acquired when a non public repository is going to be queried, so queries of public
+	// repositories never prompt for it
+	var httpBasicAuthPassword string
+	if !*publicOnly && requiresAuth(queriedRepositories) {
+		httpBasicAuthPassword = getEnvOrFlag("HTTP_AUTH", localAuthToken)
+		if httpBasicAuthPassword == "" {
+			var err error
+			httpBasicAuthPassword, err = promptForToken("Specifying an auth token is required. Use `chainctl auth token --audience apk.cgr.dev` to get the required token. Please enter token now, it is not echoed - alternatively, you can also specify this via --auth-token flag or by setting HTTP_AUTH environment variable: ")
+			if err != nil {
+				fmt.Fprintf(stderr, "Unable to prompt for the auth token: %v\n", err)
+				exit(exitUsage)
 			}
 		}
+	}
 
-		if localAPKINDEXPath != APKINDEXurl {
-			// delete the temporary directory
-			err = os.RemoveAll(temporaryAPKINDEXdir)
+	if len(arguments) > 0 {
+		commandRepositories := selectedRepositories
+		switch arguments[0] {
+		case "outdated":
+			exit(runOutdated(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputFormat))
+		case "files":
+			exit(runFiles(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "info":
+			exit(runInfo(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON, *readReplacesPriority))
+		case "pkginfo":
+			exit(runPKGINFO(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "verify":
+			exit(runVerify(arguments[1:], commandRepositories, httpBasicAuthPassword))
+		case "skew":
+			exit(runSkew(arguments[1:], commandRepositories, httpBasicAuthPassword, *matchAsRegex, *outputJSON, *strict))
+		case "diff":
+			exit(runDiff(arguments[1:], *diffLocal, commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "arches":
+			exit(runArches(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "arch-skew":
+			exit(runArchSkew(arguments[1:], commandRepositories, httpBasicAuthPassword, *matchAsRegex, *outputJSON, *strict))
+		case "auth":
+			exit(runAuth(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "streams":
+			exit(runStreams(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "removed":
+			exit(runRemoved(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "origins":
+			exit(runOrigins(arguments[1:], commandRepositories, httpBasicAuthPassword, *matchAsRegex, *outputJSON, *subPackageDetails))
+		case "consumers":
+			exit(runConsumers(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "lock":
+			exit(runLock(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "advisories":
+			exit(runAdvisories(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputFormat))
+		case "check":
+			exit(runCheck(arguments[1:], requires, commandRepositories, httpBasicAuthPassword, *outputFormat))
+		case "track":
+			exit(runTrack(arguments[1:], commandRepositories, httpBasicAuthPassword, *outputJSON))
+		case "watch":
+			notifiers, err := newNotifiers(slackWebhooks, discordWebhooks, *notifyTemplate, *onChangeCommand)
 			if err != nil {
-				fmt.Printf("Unable to delete temporary directory %s: %v\n", temporaryAPKINDEXdir, err)
-				os.Exit(1)
+				fmt.Fprintf(stdout, "Invalid --notify-template: %v\n", err)
+				exit(exitUsage)
 			}
+			exit(runWatch(arguments[1:], commandRepositories, httpBasicAuthPassword, WatchOptions{
+				MatchAsRegex:  *matchAsRegex,
+				JSON:          *outputJSON,
+				Interval:      *watchInterval,
+				ListenAddress: *listenAddress,
+				Notifiers:     notifiers,
+			}))
+		case "dump":
+			exit(runDump(arguments[1:], commandRepositories, httpBasicAuthPassword, DumpOptions{Parquet: *dumpParquet, CSV: *dumpCSV}))
+		case "serve":
+			exit(runServe(arguments[1:], commandRepositories, httpBasicAuthPassword, ServeOptions{Socket: *socketPath, Interval: *watchInterval, ListenAddress: *listenAddress}))
+		case "proxy":
+			exit(runProxy(arguments[1:], commandRepositories, httpBasicAuthPassword, ProxyOptions{ListenAddress: *listenAddress, MaxAge: *proxyMaxAge}))
+		case "latest":
+			exit(runLatest(arguments[1:], commandRepositories, httpBasicAuthPassword))
 		}
 	}
-	// ensure if subPackageNames is not empty that we remove any duplicates
-	subPackageNames = removeDuplicates(subPackageNames)
 
-	if *outputJSON {
-		if *listAllVersions {
-			// Sort the package names
-			packageNameKeys := make([]string, 0, len(matchingPackagesAllVersions))
-			for k := range matchingPackagesAllVersions {
-				packageNameKeys = append(packageNameKeys, k)
+	packageNames, err := readPackageNames(arguments, *inputFile)
+	if err != nil {
+		fmt.Fprintf(stdout, "Failed to read --input-file: %v\n", err)
+		exit(exitUsage)
+	}
+	matchers := newMatchers(packageNames, *matchAsRegex)
+	var originMatchers []Matcher
+	if *originFilter != "" {
+		originMatchers = newMatchers([]string{*originFilter}, *matchAsRegex)
+	}
+	if *maxMatches < 0 {
+		fmt.Fprintln(stdout, "Invalid --max-matches: must not be negative")
+		exit(exitUsage)
+	}
+	if *lastVersions < 0 {
+		fmt.Fprintln(stdout, "Invalid --last: must not be negative")
+		exit(exitUsage)
+	}
+	// with JSON output a repository which cannot be loaded is reported in the document alongside the partial results
+	partialResults = *outputJSON && *jsonErrors && (len(packageNames) > 0 || *originFilter != "")
+	queryOptions := QueryOptions{
+		PackageNames:    packageNames,
+		Matchers:        matchers,
+		OriginMatchers:  originMatchers,
+		MatchAsRegex:    *matchAsRegex,
+		Origin:          *originFilter,
+		MatchOrigin:     *matchOrigin,
+		Version:         *exactVersion,
+		MinVersion:      *minVersion,
+		MaxVersion:      *maxVersion,
+		ResolveParent:   *resolveParent,
+		ShowSubPackages: *showSubPackageInformation,
+		ShowSiblings:    *showSiblings,
+		Explain:         *explain,
+		MaxMatches:      *maxMatches,
+		Strict:          *strict,
+		KeepAll:         *countOnly || *countPerRepository || *showSummary,
+	}
+	// when no package names or origin are specified every package is printed as it is loaded, unless only counted
+	if !*countOnly && !*countPerRepository {
+		queryOptions.List = func(apkRepository Repository, _package *repository.Package) {
+			if *outputFormat == "apk" {
+				fmt.Fprintf(stdout, "%s-%s - %s\n", _package.Name, _package.Version, _package.Description)
+				return
 			}
-			sort.Strings(packageNameKeys)
-
-			// Create a sorted map
-			sortedMatchingPackagesAllVersions := make(map[string][]map[string]interface{})
-			for _, packageName := range packageNameKeys {
-				versions := matchingPackagesAllVersions[packageName]
-				sort.Slice(versions, func(i, j int) bool {
-					compare_version_i, _ := version.NewVersion(versions[i]["Version"].(string))
-					compare_version_j, _ := version.NewVersion(versions[j]["Version"].(string))
-					return compare_version_j.GreaterThan(compare_version_i)
-				})
-				sortedMatchingPackagesAllVersions[packageName] = versions
+			_parentPackageInformation := ""
+			if *showParentPackageInformation {
+				_parentPackageInformation = " - Parent/Origin package: " + _package.Origin
 			}
-
-			// Marshal the sorted map to JSON
-			jsonOutput, err := json.MarshalIndent(sortedMatchingPackagesAllVersions, "", "  ")
-			if err != nil {
-				log.Fatalf("Error marshalling JSON: %v", err)
+			if *showURL && _package.URL != "" {
+				_parentPackageInformation += " - Homepage: " + _package.URL
 			}
-			fmt.Println(string(jsonOutput))
-		} else {
-			jsonOutput, err := json.MarshalIndent(matchingPackagesLatestVersion, "", "  ")
-			if err != nil {
-				log.Fatalf("Error marshalling JSON: %v", err)
+			if *showDefinitions && apkRepository.DefinitionsURL != "" {
+				_parentPackageInformation += " - Definition: " + definitionURL(apkRepository.DefinitionsURL, _package)
 			}
-			fmt.Println(string(jsonOutput))
-		}
-	} else {
-		if *listAllVersions {
-			packageNameKeys := make([]string, 0, len(matchingPackagesAllVersions))
-			for k := range matchingPackagesAllVersions {
-				packageNameKeys = append(packageNameKeys, k)
+			if *showSizes {
+				_parentPackageInformation += sizeAnnotation(_package.Size, _package.InstalledSize)
 			}
-			sort.Strings(packageNameKeys)
-			for _, matchingPackageAllVersionsPackageName := range packageNameKeys {
-				matchingPackageMap := matchingPackagesAllVersions[matchingPackageAllVersionsPackageName]
-				fmt.Printf("The versions of package %s are:\n", matchingPackageAllVersionsPackageName)
-				sort.Slice(matchingPackageMap, func(i, j int) bool {
-					compare_version_i, _ := version.NewVersion(matchingPackageMap[i]["Version"].(string))
-					compare_version_j, _ := version.NewVersion(matchingPackageMap[j]["Version"].(string))
-					return compare_version_j.GreaterThan(compare_version_i)
-				})
-				for _, versionMap := range matchingPackageMap {
-					_parentPackageInformation := ""
-					if *showParentPackageInformation {
-						_parentPackageInformation = " - Parent/Origin package: " + versionMap["Origin"].(string)
-					}
-					fmt.Printf("%s (%s - %s) in %s repository%s\n", versionMap["Version"].(string), humanize.Time(versionMap["BuildTime"].(time.Time)), versionMap["BuildTime"].(time.Time), versionMap["Repository"].(string), _parentPackageInformation)
+			fmt.Fprintf(stdout, "%s version %s (%s) in %s repository%s\n", _package.Name, _package.Version, formatTime(_package.BuildTime), apkRepository.Name, _parentPackageInformation)
+		}
+	}
+	results, err := runQuery(queriedRepositories, httpBasicAuthPassword, queryOptions)
+	if results == nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		if errors.Is(err, errLoadFailed) {
+			exit(exitFetchFailed)
+		}
+		exit(exitUsage)
+	}
+	if *readReplacesPriority && *outputJSON {
+		results.SetReplacesPriorities(func(packageName string, packageMeta PackageMeta) uint64 {
+			pkg := &repository.Package{Name: packageName, Version: packageMeta.Version, Checksum: packageMeta.Checksum}
+			for _, apkRepository := range queriedRepositories {
+				if apkRepository.Name != packageMeta.Repository {
+					continue
 				}
-			}
-		} else {
-			packageNameKeys := make([]string, 0, len(matchingPackagesLatestVersion))
-			for k := range matchingPackagesLatestVersion {
-				packageNameKeys = append(packageNameKeys, k)
-			}
-			sort.Strings(packageNameKeys)
-			for _, matchingPackageLatestVersionPackageName := range packageNameKeys {
-				matchingPackageMap := matchingPackagesLatestVersion[matchingPackageLatestVersionPackageName]
-				_parentPackageInformation := ""
-				if *showParentPackageInformation {
-					_parentPackageInformation = " - Parent/Origin package: " + matchingPackageMap["Origin"].(string)
+				replacesPriority, err := fetchReplacesPriority(apkRepository, pkg, httpBasicAuthPassword)
+				if err != nil {
+					fmt.Fprintf(stderr, "Warning: failed to read replaces_priority of %s: %v\n", pkg.Filename(), err)
 				}
-				fmt.Printf("The latest version of package %s is %s (%s - %s) in %s repository%s\n", matchingPackageLatestVersionPackageName, matchingPackageMap["Version"].(string), humanize.Time(matchingPackageMap["BuildTime"].(time.Time)), matchingPackageMap["BuildTime"].(time.Time), matchingPackageMap["Repository"].(string), _parentPackageInformation)
+				return replacesPriority
+			}
+			return 0
+		})
+	}
+	if *showTimings {
+		// timings go to stderr so they never mix with the results, and are printed after the results whichever output
+		// mode returns
+		defer results.PrintTimings(stderr)
+	}
+	if *countOnly || *countPerRepository {
+		err := results.PrintCount(stdout, *countPerRepository, *outputJSON)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error rendering output: %v\n", err)
+			exit(exitUsage)
+		}
+		return
+	}
+	if *showPolicy {
+		err := results.PrintPolicy(stdout, queriedRepositories, *outputJSON)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error rendering output: %v\n", err)
+			exit(exitUsage)
+		}
+		return
+	}
+	if !*outputJSON || !*jsonErrors {
+		for _, reportedError := range results.Errors {
+			fmt.Fprintf(stderr, "Warning: %s\n", reportedError.Message)
+		}
+	}
+	// with --strict over broad queries fail without printing their results, so a pipeline never processes the whole
+	// index by accident
+	if errors.Is(err, errBroadQueries) {
+		if *outputJSON && *jsonErrors {
+			failed := NewResults()
+			failed.Errors = results.Errors
+			if err := failed.Print(stdout, PrintOptions{JSON: true, JSONErrors: true}); err != nil {
+				fmt.Fprintf(stderr, "Error rendering output: %v\n", err)
+				exit(exitUsage)
 			}
 		}
-		if *showSubPackageInformation && !*matchAsRegex && len(subPackageNames) > 0 {
-			fmt.Println("Sub packages:")
-			for _, subPackageName := range subPackageNames {
-				fmt.Println(subPackageName)
+		exit(exitConstraintViolated)
+	}
+	// when no package names or origin are specified all packages have already been printed by queryOptions.List
+	if len(packageNames) > 0 || *originFilter != "" {
+		printOptions := PrintOptions{
+			JSON:               *outputJSON,
+			JSONErrors:         *jsonErrors,
+			APK:                *outputFormat == "apk",
+			AllVersions:        *listAllVersions || *matchOrigin || *lastVersions > 0,
+			Last:               *lastVersions,
+			ShowParentPackage:  *showParentPackageInformation,
+			ShowSubPackages:    *showSubPackageInformation || *matchOrigin,
+			ShowMatchedQueries: *showMatchedQueries,
+			ShowSizes:          *showSizes,
+			ShowDefinitions:    *showDefinitions,
+			ShowURL:            *showURL,
+			ShowSiblings:       *showSiblings,
+			Explain:            *explain,
+			MergeRepositories:  *mergeRepositories,
+			GroupBy:            *groupBy,
+			Queries:            packageNames,
+			Sort:               *sortOrder,
+		}
+		// the portions of package names matched are highlighted in text output on a terminal
+		if colorOutput(os.Stdout) {
+			printOptions.Highlight = append(matchers, originMatchers...)
+		}
+		if err := results.Print(stdout, printOptions); err != nil {
+			fmt.Fprintf(stderr, "Error rendering output: %v\n", err)
+			exit(exitUsage)
+		}
+		if *openInBrowser {
+			if err := results.OpenFirstMatch(printOptions); err != nil {
+				fmt.Fprintf(stderr, "Failed to open in browser: %v\n", err)
 			}
 		}
 	}
+	if *showSummary {
+		// keep JSON output on stdout parsable
+		summaryWriter := stdout
+		if *outputJSON {
+			summaryWriter = stderr
+		}
+		results.PrintSummary(summaryWriter, *listAllVersions || (len(packageNames) == 0 && *originFilter == ""))
+	}
+	if err != nil {
+		if *showTimings {
+			results.PrintTimings(stderr)
+		}
+		exit(queryExitCode(err))
+	}
 }
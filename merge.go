@@ -0,0 +1,62 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+)
+
+// mergeRepositories collapses the same version of a package in several repositories into one entry, listing all the
+// repositories in Repositories and flagging builds whose checksums differ between them. versions are ordered from
+// oldest to newest, as by sortedVersions, so the same version in several repositories is adjacent.
+func mergeRepositories(versions []PackageMeta) []PackageMeta {
+	merged := make([]PackageMeta, 0, len(versions))
+	for _, packageMeta := range versions {
+		last := len(merged) - 1
+		if last < 0 || merged[last].Version != packageMeta.Version {
+			packageMeta.Repositories = []string{packageMeta.Repository}
+			merged = append(merged, packageMeta)
+			continue
+		}
+		merged[last].Repositories = append(merged[last].Repositories, packageMeta.Repository)
+		// packages read from a directory of .apk files have no checksum to compare
+		if len(merged[last].Checksum) > 0 && len(packageMeta.Checksum) > 0 && !bytes.Equal(merged[last].Checksum, packageMeta.Checksum) {
+			merged[last].ChecksumMismatch = true
+		}
+	}
+	// a version found in only one repository is printed as before
+	for i := range merged {
+		if len(merged[i].Repositories) == 1 {
+			merged[i].Repositories = nil
+		}
+	}
+	return merged
+}
+
+// printedLatestVersion returns the latest version of the named package to print, with every repository publishing the
+// same version when options.MergeRepositories is set
+func (r *Results) printedLatestVersion(packageName string, options PrintOptions) PackageMeta {
+	latestVersion := r.LatestVersion[packageName]
+	if !options.MergeRepositories {
+		return latestVersion
+	}
+	for _, packageMeta := range mergeRepositories(r.sortedVersions(packageName)) {
+		if packageMeta.Version == latestVersion.Version {
+			latestVersion.Repositories = packageMeta.Repositories
+			latestVersion.ChecksumMismatch = packageMeta.ChecksumMismatch
+		}
+	}
+	return latestVersion
+}
+
+// repositoryLabel renders the repository of a package version in text output, e.g. `wolfi os repository` or, for a
+// version merged across repositories, `wolfi os and enterprise packages repositories`
+func repositoryLabel(packageMeta PackageMeta) string {
+	repositories := packageMeta.Repositories
+	switch len(repositories) {
+	case 0:
+		return packageMeta.Repository + " repository"
+	case 1:
+		return repositories[0] + " repository"
+	}
+	return strings.Join(repositories[:len(repositories)-1], ", ") + " and " + repositories[len(repositories)-1] + " repositories"
+}
@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os/exec"
+	"strings"
+	"text/template"
+)
+
+// defaultSlackTemplate and defaultDiscordTemplate format a single change in the markup of each service
+const (
+	defaultSlackTemplate   = "New version *{{.Version}}* of package *{{.Name}}* in {{.Repository}} repository"
+	defaultDiscordTemplate = "New version **{{.Version}}** of package **{{.Name}}** in {{.Repository}} repository"
+)
+
+// notifier sends the changes found by a poll of watch somewhere
+type notifier interface {
+	Notify(changes []PackageChange) error
+}
+
+// webhookNotifier posts the changes, one formatted line per change, to a chat service incoming webhook. The changes of
+// a poll are sent as a single message to stay within the rate limits of the services.
+type webhookNotifier struct {
+	webhookURL string
+	template   *template.Template
+	// payload wraps the message in the JSON body the service expects
+	payload func(message string) interface{}
+}
+
+// newSlackNotifier returns a notifier posting to a Slack incoming webhook, formatting each change with messageTemplate
+// or the default Slack template when it is empty
+func newSlackNotifier(webhookURL string, messageTemplate string) (notifier, error) {
+	return newWebhookNotifier(webhookURL, messageTemplate, defaultSlackTemplate, func(message string) interface{} {
+		return map[string]string{"text": message}
+	})
+}
+
+// newDiscordNotifier returns a notifier posting to a Discord webhook, formatting each change with messageTemplate or
+// the default Discord template when it is empty
+func newDiscordNotifier(webhookURL string, messageTemplate string) (notifier, error) {
+	return newWebhookNotifier(webhookURL, messageTemplate, defaultDiscordTemplate, func(message string) interface{} {
+		return map[string]string{"content": message}
+	})
+}
+
+func newWebhookNotifier(webhookURL string, messageTemplate string, defaultTemplate string, payload func(message string) interface{}) (notifier, error) {
+	if messageTemplate == "" {
+		messageTemplate = defaultTemplate
+	}
+	parsedTemplate, err := template.New("message").Parse(messageTemplate)
+	if err != nil {
+		return nil, fmt.Errorf("invalid message template: %w", err)
+	}
+	return &webhookNotifier{webhookURL: webhookURL, template: parsedTemplate, payload: payload}, nil
+}
+
+func (n *webhookNotifier) Notify(changes []PackageChange) error {
+	if len(changes) == 0 {
+		return nil
+	}
+	var lines []string
+	for _, change := range changes {
+		var line strings.Builder
+		if err := n.template.Execute(&line, change); err != nil {
+			return fmt.Errorf("failed to format message: %w", err)
+		}
+		lines = append(lines, line.String())
+	}
+	body, err := json.Marshal(n.payload(strings.Join(lines, "\n")))
+	if err != nil {
+		return err
+	}
+	resp, err := http.Post(n.webhookURL, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("webhook returned status %s", resp.Status)
+	}
+	return nil
+}
+
+// commandNotifier runs a shell command for each change, with the change as JSON on stdin
+type commandNotifier struct {
+	command string
+}
+
+func (n *commandNotifier) Notify(changes []PackageChange) error {
+	var commandErrors []error
+	for _, change := range changes {
+		changeJSON, err := json.Marshal(change)
+		if err != nil {
+			return err
+		}
+		cmd := exec.Command("sh", "-c", n.command)
+		cmd.Stdin = bytes.NewReader(changeJSON)
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+		if err := cmd.Run(); err != nil {
+			commandErrors = append(commandErrors, fmt.Errorf("on change command for %s=%s failed: %w", change.Name, change.Version, err))
+		}
+	}
+	return errors.Join(commandErrors...)
+}
+
+// newNotifiers returns a notifier for each Slack and Discord webhook and the on change command, if any
+func newNotifiers(slackWebhooks []string, discordWebhooks []string, messageTemplate string, onChangeCommand string) ([]notifier, error) {
+	var notifiers []notifier
+	if onChangeCommand != "" {
+		notifiers = append(notifiers, &commandNotifier{command: onChangeCommand})
+	}
+	for _, webhookURL := range slackWebhooks {
+		slackNotifier, err := newSlackNotifier(webhookURL, messageTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, slackNotifier)
+	}
+	for _, webhookURL := range discordWebhooks {
+		discordNotifier, err := newDiscordNotifier(webhookURL, messageTemplate)
+		if err != nil {
+			return nil, err
+		}
+		notifiers = append(notifiers, discordNotifier)
+	}
+	return notifiers, nil
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// OriginPackage is an origin package, i.e. a melange build, and the sub packages it produces
+type OriginPackage struct {
+	SubPackageCount int
+	SubPackages     []string
+}
+
+// SubPackage is a sub package with its own latest version, so consumers need not query each sub package again
+type SubPackage struct {
+	Name       string
+	Version    string
+	BuildTime  time.Time
+	Repository string
+}
+
+// OriginPackageDetails is an OriginPackage with the latest version of each sub package rather than only its name
+type OriginPackageDetails struct {
+	SubPackageCount int
+	SubPackages     []SubPackage
+}
+
+// originDetails returns the origin packages with the latest version of each of their sub packages in the results
+func (r *Results) originDetails(origins map[string]OriginPackage) map[string]OriginPackageDetails {
+	details := make(map[string]OriginPackageDetails, len(origins))
+	for origin, originPackage := range origins {
+		subPackages := make([]SubPackage, 0, len(originPackage.SubPackages))
+		for _, subPackageName := range originPackage.SubPackages {
+			latestVersion := r.LatestVersion[subPackageName]
+			subPackages = append(subPackages, SubPackage{Name: subPackageName, Version: latestVersion.Version, BuildTime: latestVersion.BuildTime, Repository: latestVersion.Repository})
+		}
+		details[origin] = OriginPackageDetails{SubPackageCount: originPackage.SubPackageCount, SubPackages: subPackages}
+	}
+	return details
+}
+
+// Origins groups the packages in the results by their origin package. Packages without an origin are their own origin.
+func (r *Results) Origins() map[string]OriginPackage {
+	origins := make(map[string]OriginPackage)
+	for _, packageName := range r.sortedPackageNames() {
+		origin := r.LatestVersion[packageName].Origin
+		if origin == "" {
+			origin = packageName
+		}
+		originPackage, found := origins[origin]
+		if !found {
+			originPackage.SubPackages = []string{}
+		}
+		if packageName != origin {
+			originPackage.SubPackages = append(originPackage.SubPackages, packageName)
+			originPackage.SubPackageCount++
+		}
+		origins[origin] = originPackage
+	}
+	return origins
+}
+
+// runOrigins reports each origin package, or only those matching the queries, with its sub package count and names, or
+// with subPackageDetails the latest version of each sub package
+func runOrigins(args []string, repositories []Repository, authToken string, matchAsRegex bool, asJSON bool, subPackageDetails bool) int {
+	matchers := newMatchers(args, matchAsRegex)
+	results := NewResults()
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		results.AddPackageMeta(pkg, apkRepository.Name, nil)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	origins := results.Origins()
+	for origin := range origins {
+		if len(matchers) > 0 && len(matchReference(matchers, &repository.Package{Name: origin})) == 0 {
+			delete(origins, origin)
+		}
+	}
+
+	if asJSON {
+		var report interface{} = origins
+		if subPackageDetails {
+			report = results.originDetails(origins)
+		}
+		jsonOutput, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	originNames := make([]string, 0, len(origins))
+	for origin := range origins {
+		originNames = append(originNames, origin)
+	}
+	sort.Strings(originNames)
+	for _, origin := range originNames {
+		originPackage := origins[origin]
+		if originPackage.SubPackageCount == 0 {
+			fmt.Fprintf(stdout, "%s (0 sub packages)\n", origin)
+			continue
+		}
+		subPackages := originPackage.SubPackages
+		if subPackageDetails {
+			subPackages = make([]string, 0, len(originPackage.SubPackages))
+			for _, subPackageName := range originPackage.SubPackages {
+				latestVersion := results.LatestVersion[subPackageName]
+				subPackages = append(subPackages, fmt.Sprintf("%s %s in %s repository", subPackageName, latestVersion.Version, latestVersion.Repository))
+			}
+		}
+		fmt.Fprintf(stdout, "%s (%d sub packages): %s\n", origin, originPackage.SubPackageCount, strings.Join(subPackages, ", "))
+	}
+	return 0
+}
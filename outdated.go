@@ -0,0 +1,218 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// InstalledPackage is a package name and version pair, e.g. as installed in an image
+type InstalledPackage struct {
+	Name    string
+	Version string
+	// Line is the line the package was read from, used to annotate it
+	Line int `json:"-"`
+}
+
+// OutdatedPackage is an installed package for which a newer version is available in the repositories
+type OutdatedPackage struct {
+	Name             string
+	InstalledVersion string
+	LatestVersion    string
+	Repository       string
+	LatestBuildTime  time.Time
+	// InstalledBuildTime is only set when the installed version is still available in the repositories
+	InstalledBuildTime *time.Time `json:",omitempty"`
+}
+
+// parseInstalledPackages parses name=version lines, ignoring blank lines and # comments
+func parseInstalledPackages(lines []string) ([]InstalledPackage, error) {
+	var installedPackages []InstalledPackage
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		name, installedVersion, found := strings.Cut(line, "=")
+		if !found || name == "" || installedVersion == "" {
+			return nil, fmt.Errorf("invalid line %q - expected name=version", line)
+		}
+		installedPackages = append(installedPackages, InstalledPackage{Name: strings.TrimSpace(name), Version: strings.TrimSpace(installedVersion), Line: i + 1})
+	}
+	return installedPackages, nil
+}
+
+// findOutdated compares the installed packages to the results, returning the outdated ones and the names of those which
+// could not be found in any repository
+func findOutdated(installedPackages []InstalledPackage, results *Results) ([]OutdatedPackage, []string) {
+	var outdatedPackages []OutdatedPackage
+	var missingPackages []string
+	for _, installedPackage := range installedPackages {
+		latestVersion, found := results.LatestVersion[installedPackage.Name]
+		if !found {
+			missingPackages = append(missingPackages, installedPackage.Name)
+			continue
+		}
+		if !versionGreaterThan(latestVersion.Version, installedPackage.Version) {
+			continue
+		}
+		outdatedPackage := OutdatedPackage{
+			Name:             installedPackage.Name,
+			InstalledVersion: installedPackage.Version,
+			LatestVersion:    latestVersion.Version,
+			Repository:       latestVersion.Repository,
+			LatestBuildTime:  latestVersion.BuildTime,
+		}
+		for _, packageMeta := range results.AllVersions[installedPackage.Name] {
+			if packageMeta.Version == installedPackage.Version {
+				installedBuildTime := packageMeta.BuildTime
+				outdatedPackage.InstalledBuildTime = &installedBuildTime
+				break
+			}
+		}
+		outdatedPackages = append(outdatedPackages, outdatedPackage)
+	}
+	return outdatedPackages, missingPackages
+}
+
+// outdatedTestCases returns a JUnit test case for each installed package, failing for outdated packages and skipped for
+// packages not found in any repository
+func outdatedTestCases(installedPackages []InstalledPackage, outdatedPackages []OutdatedPackage, missingPackages []string) []junitTestCase {
+	outdatedByName := make(map[string]OutdatedPackage, len(outdatedPackages))
+	for _, outdatedPackage := range outdatedPackages {
+		outdatedByName[outdatedPackage.Name] = outdatedPackage
+	}
+	missing := make(map[string]struct{}, len(missingPackages))
+	for _, missingPackage := range missingPackages {
+		missing[missingPackage] = struct{}{}
+	}
+	testCases := make([]junitTestCase, 0, len(installedPackages))
+	for _, installedPackage := range installedPackages {
+		testCase := junitTestCase{Name: installedPackage.Name + "=" + installedPackage.Version, ClassName: "outdated"}
+		if outdatedPackage, found := outdatedByName[installedPackage.Name]; found {
+			testCase.Failure = &junitMessage{Message: fmt.Sprintf("outdated: %s -> %s in %s repository", outdatedPackage.InstalledVersion, outdatedPackage.LatestVersion, outdatedPackage.Repository), Type: "outdated"}
+		} else if _, found := missing[installedPackage.Name]; found {
+			testCase.Skipped = &junitMessage{Message: "not found in any repository"}
+		}
+		testCases = append(testCases, testCase)
+	}
+	return testCases
+}
+
+// outdatedAnnotations returns a GitHub Actions annotation of the line of inputPath each outdated, as a warning, or
+// missing, as an error, package was read from. Packages read from stdin annotate the workflow run.
+func outdatedAnnotations(inputPath string, installedPackages []InstalledPackage, outdatedPackages []OutdatedPackage, missingPackages []string) []ghaAnnotation {
+	if inputPath == "-" {
+		inputPath = ""
+	}
+	lines := make(map[string]int, len(installedPackages))
+	for _, installedPackage := range installedPackages {
+		lines[installedPackage.Name] = installedPackage.Line
+	}
+	var annotations []ghaAnnotation
+	for _, outdatedPackage := range outdatedPackages {
+		annotations = append(annotations, ghaAnnotation{
+			Level:   "warning",
+			File:    inputPath,
+			Line:    lines[outdatedPackage.Name],
+			Title:   "Outdated package " + outdatedPackage.Name,
+			Message: fmt.Sprintf("Package %s is outdated: %s -> %s in %s repository", outdatedPackage.Name, outdatedPackage.InstalledVersion, outdatedPackage.LatestVersion, outdatedPackage.Repository),
+		})
+	}
+	for _, missingPackage := range missingPackages {
+		annotations = append(annotations, ghaAnnotation{
+			Level:   "error",
+			File:    inputPath,
+			Line:    lines[missingPackage],
+			Title:   "Missing package " + missingPackage,
+			Message: fmt.Sprintf("Package %s not found in any repository", missingPackage),
+		})
+	}
+	return annotations
+}
+
+// runOutdated reports which of the name=version pairs read from a file, or stdin, are behind the repositories. It returns
+// exitConstraintViolated when any package is outdated.
+func runOutdated(args []string, repositories []Repository, authToken string, outputFormat string) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "Usage: %s outdated [FILE]\n", os.Args[0])
+		return exitUsage
+	}
+	inputPath := "-"
+	if len(args) == 1 {
+		inputPath = args[0]
+	}
+	lines, err := readInputLines(inputPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read installed packages: %v\n", err)
+		return exitUsage
+	}
+	installedPackages, err := parseInstalledPackages(lines)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to parse installed packages: %v\n", err)
+		return exitUsage
+	}
+
+	installedPackageNames := make(map[string]struct{})
+	for _, installedPackage := range installedPackages {
+		installedPackageNames[installedPackage.Name] = struct{}{}
+	}
+	results := NewResults()
+	_, err = forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if _, found := installedPackageNames[pkg.Name]; found {
+			results.AddPackageMeta(pkg, apkRepository.Name, nil)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	outdatedPackages, missingPackages := findOutdated(installedPackages, results)
+	for _, missingPackage := range missingPackages {
+		fmt.Fprintf(stderr, "Package %s not found in any repository\n", missingPackage)
+	}
+	switch outputFormat {
+	case "wolfictl":
+		if err := writeWolfictl(stdout, wolfictlUpdates(outdatedPackages)); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "gha":
+		if err := writeGHA(stdout, outdatedAnnotations(inputPath, installedPackages, outdatedPackages, missingPackages)); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "junit":
+		if err := writeJUnit(stdout, "outdated", outdatedTestCases(installedPackages, outdatedPackages, missingPackages)); err != nil {
+			fmt.Fprintf(stderr, "%v\n", err)
+			return exitUsage
+		}
+	case "json":
+		jsonOutput, err := json.MarshalIndent(outdatedPackages, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	default:
+		for _, outdatedPackage := range outdatedPackages {
+			installedBuildAge := ""
+			if outdatedPackage.InstalledBuildTime != nil {
+				installedBuildAge = fmt.Sprintf(", installed version built %s", formatRelativeTime(*outdatedPackage.InstalledBuildTime))
+			}
+			fmt.Fprintf(stdout, "Package %s is outdated: %s -> %s (built %s in %s repository%s)\n", outdatedPackage.Name, outdatedPackage.InstalledVersion, outdatedPackage.LatestVersion, formatRelativeTime(outdatedPackage.LatestBuildTime), outdatedPackage.Repository, installedBuildAge)
+		}
+		if len(outdatedPackages) == 0 {
+			fmt.Fprintln(stdout, "All packages are up to date")
+		}
+	}
+	if len(outdatedPackages) > 0 {
+		return exitConstraintViolated
+	}
+	return 0
+}
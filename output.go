@@ -0,0 +1,27 @@
+package main
+
+import (
+	"io"
+	"os"
+	"sync"
+)
+
+// syncWriter serialises the writes of concurrent goroutines to w, so lines written with a single write, as fmt.Fprintf
+// does, are never interleaved
+type syncWriter struct {
+	mu sync.Mutex
+	w  io.Writer
+}
+
+func (s *syncWriter) Write(p []byte) (int, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.w.Write(p)
+}
+
+// stdout and stderr are written to by the goroutines fetching repositories, serving and watching, as well as by the
+// main goroutine, so output is written through them rather than os.Stdout and os.Stderr directly
+var (
+	stdout io.Writer = &syncWriter{w: os.Stdout}
+	stderr io.Writer = &syncWriter{w: os.Stderr}
+)
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+)
+
+// appDirectoryName is the name of the directory of the tool in each of the user directories
+const appDirectoryName = "wolfi-package-status"
+
+// configDir returns the directory of the configuration file - $XDG_CONFIG_HOME/wolfi-package-status, by default
+// ~/.config/wolfi-package-status, on Linux, ~/Library/Application Support/wolfi-package-status on macOS and
+// %AppData%\wolfi-package-status on Windows
+func configDir() (string, error) {
+	userConfigDir, err := os.UserConfigDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userConfigDir, appDirectoryName), nil
+}
+
+// cacheDir returns the directory of the cache, which can be deleted at any time - $XDG_CACHE_HOME/wolfi-package-status,
+// by default ~/.cache/wolfi-package-status, on Linux, ~/Library/Caches/wolfi-package-status on macOS and
+// %LocalAppData%\wolfi-package-status on Windows
+func cacheDir() (string, error) {
+	userCacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userCacheDir, appDirectoryName), nil
+}
+
+// stateDir returns the directory of state which should persist between runs but is not configuration, such as the
+// socket of the daemon - $XDG_STATE_HOME/wolfi-package-status, by default ~/.local/state/wolfi-package-status, on
+// Linux, ~/Library/Application Support/wolfi-package-status on macOS and %LocalAppData%\wolfi-package-status on Windows
+func stateDir() (string, error) {
+	switch runtime.GOOS {
+	case "windows":
+		// the user cache directory on Windows is %LocalAppData%
+		return cacheDir()
+	case "darwin", "ios":
+		return configDir()
+	}
+	// relative paths are invalid per the XDG base directory specification and are ignored
+	if xdgStateHome := os.Getenv("XDG_STATE_HOME"); filepath.IsAbs(xdgStateHome) {
+		return filepath.Join(xdgStateHome, appDirectoryName), nil
+	}
+	userHomeDir, err := os.UserHomeDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(userHomeDir, ".local", "state", appDirectoryName), nil
+}
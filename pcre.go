@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/dlclark/regexp2"
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// regexEngine is the engine regex queries are compiled with, set by --regex-engine - re2, Go's regexp package which
+// runs in linear time, or pcre for Perl compatible features such as lookaheads, lookbehinds and backreferences
+var regexEngine = "re2"
+
+// pcreMatchTimeout bounds the time a pcre pattern may take to match a single package name, as backtracking patterns
+// can take exponential time
+const pcreMatchTimeout = time.Second
+
+// pcreQueryTimeout bounds the total time a pcre query may take matching package names, otherwise a pattern taking
+// pcreMatchTimeout on every name would take hours on a whole index
+const pcreQueryTimeout = 10 * time.Second
+
+// pcreBudget is the matching time a pcre query has left, shared by its matcher
+type pcreBudget struct {
+	mutex     sync.Mutex
+	remaining time.Duration
+}
+
+// setRegexEngine selects the engine regex queries are compiled with
+func setRegexEngine(engine string) error {
+	if engine != "re2" && engine != "pcre" {
+		return fmt.Errorf("unsupported regex engine %s - supported engines are re2 and pcre", engine)
+	}
+	regexEngine = engine
+	return nil
+}
+
+// compilePCRE compiles the pattern with the Perl compatible engine
+func compilePCRE(pattern string) (*regexp2.Regexp, error) {
+	regex, err := regexp2.Compile(pattern, regexp2.None)
+	if err != nil {
+		return nil, err
+	}
+	regex.MatchTimeout = pcreMatchTimeout
+	return regex, nil
+}
+
+// newPCREMatcher returns the matcher of a query whose pattern compiled to regex, with the whole pcreQueryTimeout left
+func newPCREMatcher(query string, pattern string, regex *regexp2.Regexp) pcreMatcher {
+	return pcreMatcher{query: query, pattern: pattern, regex: regex, budget: &pcreBudget{remaining: pcreQueryTimeout}}
+}
+
+// pcreMatcher matches packages whose name is equal to the pattern or matches it as a Perl compatible regular
+// expression, e.g. ^python(?!.*-doc$) for the python packages which are not documentation
+type pcreMatcher struct {
+	query   string
+	pattern string
+	regex   *regexp2.Regexp
+	budget  *pcreBudget
+}
+
+// timed runs match within the matching time the query has left, skipping it once the query has used all its time so
+// the remaining packages are treated as not matching
+func (m pcreMatcher) timed(match func()) {
+	m.budget.mutex.Lock()
+	defer m.budget.mutex.Unlock()
+	if m.budget.remaining <= 0 {
+		return
+	}
+	m.regex.MatchTimeout = min(pcreMatchTimeout, m.budget.remaining)
+	startTime := time.Now()
+	match()
+	m.budget.remaining -= time.Since(startTime)
+	if m.budget.remaining <= 0 {
+		fmt.Fprintf(stderr, "Warning: the query %s took longer than %s matching package names - the remaining packages are not matched against it\n", m.query, pcreQueryTimeout)
+	}
+}
+
+func (m pcreMatcher) Query() string {
+	return m.query
+}
+
+// Matches treats a pattern which times out matching the name as not matching
+func (m pcreMatcher) Matches(pkg *repository.Package) bool {
+	if pkg.Name == m.pattern {
+		return true
+	}
+	var matched bool
+	var err error
+	m.timed(func() {
+		matched, err = m.regex.MatchString(pkg.Name)
+	})
+	if err != nil {
+		logVerbose("Matching %s against %s failed: %v", pkg.Name, m.query, err)
+	}
+	return matched
+}
+
+// matchedSpans of a Perl compatible regular expression are its capture groups, when it has any, and otherwise each
+// match, as for regexMatcher. regexp2 offsets are in runes, package names are ASCII.
+func (m pcreMatcher) matchedSpans(name string) [][2]int {
+	if name == m.pattern {
+		return [][2]int{{0, len(name)}}
+	}
+	var spans [][2]int
+	m.timed(func() {
+		match, err := m.regex.FindStringMatch(name)
+		for err == nil && match != nil {
+			groups := match.Groups()
+			if len(groups) == 1 {
+				spans = append(spans, [2]int{match.Index, match.Index + match.Length})
+			}
+			for _, group := range groups[1:] {
+				if len(group.Captures) > 0 {
+					spans = append(spans, [2]int{group.Index, group.Index + group.Length})
+				}
+			}
+			match, err = m.regex.FindNextMatch(match)
+		}
+	})
+	return spans
+}
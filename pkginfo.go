@@ -0,0 +1,80 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+)
+
+// PKGINFO is the content of the control section of a package - the .PKGINFO fields, which carry more than the APKINDEX,
+// and which install scripts are present
+type PKGINFO struct {
+	Fields  map[string][]string
+	Scripts []string
+}
+
+// runPKGINFO prints the .PKGINFO fields of the latest, or the specified, version of a package
+func runPKGINFO(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s pkginfo PACKAGE[=VERSION]\n", os.Args[0])
+		return exitUsage
+	}
+	packageName, packageVersion := splitPackageReference(args[0])
+	packageName = resolveAlias(packageName)
+	apkRepository, pkg, err := resolvePackage(repositories, authToken, packageName, packageVersion)
+	if errors.Is(err, errLoadFailed) {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitNoMatches
+	}
+	apk, err := fetchAPK(apkRepository, pkg, authToken)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to fetch %s: %v\n", pkg.Filename(), err)
+		return exitFetchFailed
+	}
+	fields, scripts, err := apk.Control()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read .PKGINFO of %s: %v\n", pkg.Filename(), err)
+		return exitUsage
+	}
+
+	if asJSON {
+		pkginfo := PKGINFO{Fields: make(map[string][]string), Scripts: scripts}
+		for _, field := range fields {
+			pkginfo.Fields[field.Key] = append(pkginfo.Fields[field.Key], field.Value)
+		}
+		jsonOutput, err := json.MarshalIndent(pkginfo, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	fmt.Fprintf(stdout, ".PKGINFO of package %s version %s in %s repository:\n", pkg.Name, pkg.Version, apkRepository.Name)
+	for _, field := range fields {
+		value := field.Value
+		if field.Key == "builddate" {
+			if buildDate, err := strconv.ParseInt(field.Value, 10, 64); err == nil {
+				buildTime := time.Unix(buildDate, 0).UTC()
+				value = fmt.Sprintf("%s (%s)", field.Value, formatTime(buildTime))
+			}
+		}
+		fmt.Fprintf(stdout, "%s = %s\n", field.Key, value)
+	}
+	if len(scripts) == 0 {
+		fmt.Fprintln(stdout, "Scripts: none")
+	} else {
+		fmt.Fprintln(stdout, "Scripts:")
+		for _, script := range scripts {
+			fmt.Fprintf(stdout, "\t%s\n", script)
+		}
+	}
+	return 0
+}
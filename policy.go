@@ -0,0 +1,100 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// PolicyVersion is a single version of a package and the repositories, in preference order, which provide it
+type PolicyVersion struct {
+	Version      string
+	Repositories []string
+}
+
+// PackagePolicy mimics `apk policy` - the candidate version which apk would install and every available version. There
+// is no candidate when the package is only available from tagged repositories.
+type PackagePolicy struct {
+	Candidate *PackageMeta `json:",omitempty"`
+	Versions  []PolicyVersion
+}
+
+// Policy computes the apk policy of every package in the results. apk installs the highest version available across all
+// repositories, in the order given, and when the same version is available in more than one repository, the repository
+// listed first wins. Tagged repositories are never the candidate, apk only installs from them when the package is
+// pinned to their tag.
+func (r *Results) Policy(repositories []Repository) map[string]PackagePolicy {
+	repositoryPreference := make(map[string]int)
+	taggedRepositories := make(map[string]struct{})
+	for i, apkRepository := range repositories {
+		repositoryPreference[apkRepository.Name] = i
+		if apkRepository.Tag != "" {
+			taggedRepositories[apkRepository.Name] = struct{}{}
+		}
+	}
+
+	policies := make(map[string]PackagePolicy)
+	for _, packageName := range r.sortedPackageNames() {
+		versions := append([]PackageMeta(nil), r.AllVersions[packageName]...)
+		// newest version first and within the same version the most preferred repository first
+		sort.SliceStable(versions, func(i, j int) bool {
+			if versions[i].Version != versions[j].Version {
+				return versionGreaterThan(versions[i].Version, versions[j].Version)
+			}
+			return repositoryPreference[versions[i].Repository] < repositoryPreference[versions[j].Repository]
+		})
+
+		var policy PackagePolicy
+		for _, packageMeta := range versions {
+			if _, tagged := taggedRepositories[packageMeta.Repository]; !tagged && policy.Candidate == nil {
+				candidate := packageMeta
+				policy.Candidate = &candidate
+			}
+			lastVersion := len(policy.Versions) - 1
+			if lastVersion >= 0 && policy.Versions[lastVersion].Version == packageMeta.Version {
+				policy.Versions[lastVersion].Repositories = append(policy.Versions[lastVersion].Repositories, packageMeta.Repository)
+				continue
+			}
+			policy.Versions = append(policy.Versions, PolicyVersion{Version: packageMeta.Version, Repositories: []string{packageMeta.Repository}})
+		}
+		policies[packageName] = policy
+	}
+	return policies
+}
+
+// PrintPolicy renders the apk policy of every package in the results either as text, in the style of `apk policy`, or JSON
+func (r *Results) PrintPolicy(w io.Writer, repositories []Repository, asJSON bool) error {
+	policies := r.Policy(repositories)
+	repositoryTags := make(map[string]string)
+	for _, apkRepository := range repositories {
+		if apkRepository.Tag != "" {
+			repositoryTags[apkRepository.Name] = apkRepository.Tag
+		}
+	}
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(policies, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(jsonOutput))
+		return err
+	}
+	for _, packageName := range r.sortedPackageNames() {
+		policy := policies[packageName]
+		fmt.Fprintf(w, "%s policy:\n", packageName)
+		for _, policyVersion := range policy.Versions {
+			fmt.Fprintf(w, "  %s:\n", policyVersion.Version)
+			for _, repositoryName := range policyVersion.Repositories {
+				candidate := ""
+				if tag, tagged := repositoryTags[repositoryName]; tagged {
+					candidate = " (@" + tag + ", only when pinned)"
+				} else if policy.Candidate != nil && policyVersion.Version == policy.Candidate.Version && repositoryName == policy.Candidate.Repository {
+					candidate = " (candidate)"
+				}
+				fmt.Fprintf(w, "    %s%s\n", repositoryName, candidate)
+			}
+		}
+	}
+	return nil
+}
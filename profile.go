@@ -0,0 +1,81 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"runtime/trace"
+)
+
+// stopProfiling stops the profiling of the run and writes the profiles, it does nothing until profiling starts
+var stopProfiling = func() {}
+
+// exit writes the profiles of the run, when profiling, and exits with code. The run exits through it rather than
+// os.Exit so the profiles cover the whole run.
+func exit(code int) {
+	stopProfiling()
+	os.Exit(code)
+}
+
+// startProfiling starts writing a CPU profile to cpuProfilePath and an execution trace to tracePath, and sets
+// stopProfiling to stop them and write a heap profile to memProfilePath. Empty paths are not written.
+func startProfiling(cpuProfilePath string, memProfilePath string, tracePath string) error {
+	var stops []func()
+	stop := func() {
+		for _, stopProfile := range stops {
+			stopProfile()
+		}
+	}
+	if cpuProfilePath != "" {
+		cpuProfile, err := os.Create(cpuProfilePath)
+		if err != nil {
+			return err
+		}
+		if err := pprof.StartCPUProfile(cpuProfile); err != nil {
+			cpuProfile.Close()
+			return err
+		}
+		stops = append(stops, func() {
+			pprof.StopCPUProfile()
+			cpuProfile.Close()
+		})
+	}
+	if tracePath != "" {
+		traceFile, err := os.Create(tracePath)
+		if err != nil {
+			stop()
+			return err
+		}
+		if err := trace.Start(traceFile); err != nil {
+			traceFile.Close()
+			stop()
+			return err
+		}
+		stops = append(stops, func() {
+			trace.Stop()
+			traceFile.Close()
+		})
+	}
+	if memProfilePath != "" {
+		stops = append(stops, func() {
+			memProfile, err := os.Create(memProfilePath)
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to write memory profile: %v\n", err)
+				return
+			}
+			defer memProfile.Close()
+			// the heap profile is of the live objects as of the last garbage collection
+			runtime.GC()
+			if err := pprof.WriteHeapProfile(memProfile); err != nil {
+				fmt.Fprintf(stderr, "Failed to write memory profile: %v\n", err)
+			}
+		})
+	}
+	stopProfiling = func() {
+		stop()
+		// the profiles are only written once, however the run exits
+		stopProfiling = func() {}
+	}
+	return nil
+}
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"sort"
+)
+
+// preferredProvider reports whether apk would choose the provider candidate over current for a virtual provide - the
+// highest provider_priority wins, then the highest version and then the repository listed first
+func preferredProvider(candidate PackageMeta, current PackageMeta, repositoryOrder map[string]int) bool {
+	if candidate.ProviderPriority != current.ProviderPriority {
+		return candidate.ProviderPriority > current.ProviderPriority
+	}
+	if candidate.Version != current.Version {
+		return versionGreaterThan(candidate.Version, current.Version)
+	}
+	return repositoryOrder[candidate.Repository] < repositoryOrder[current.Repository]
+}
+
+// sortedProviders returns the names of the packages in the results ordered by the preference of apk for a virtual provide
+// they all provide, the provider apk would choose first
+func (r *Results) sortedProviders(repositoryNames []string) []string {
+	repositoryOrder := make(map[string]int)
+	for i, repositoryName := range repositoryNames {
+		repositoryOrder[repositoryName] = i
+	}
+	packageNames := r.sortedPackageNames()
+	sort.SliceStable(packageNames, func(i, j int) bool {
+		return preferredProvider(r.LatestVersion[packageNames[i]], r.LatestVersion[packageNames[j]], repositoryOrder)
+	})
+	return packageNames
+}
+
+// MarkChosenProviders records, for each virtual provide query such as cmd:python3, which of the matching packages apk
+// would choose to install. Only the latest version of each provider is considered. repositoryNames are the repositories
+// queried in order of preference.
+func (r *Results) MarkChosenProviders(queries []string, repositoryNames []string) {
+	for _, query := range removeDuplicates(queries) {
+		if !isVirtualProvide(query) {
+			continue
+		}
+		providers := newResults()
+		for packageName, packageMeta := range r.LatestVersion {
+			for _, matchedQuery := range packageMeta.MatchedQueries {
+				if matchedQuery == query {
+					providers.LatestVersion[packageName] = packageMeta
+					providers.AllVersions[packageName] = []PackageMeta{packageMeta}
+				}
+			}
+		}
+		if len(providers.LatestVersion) == 0 {
+			continue
+		}
+		chosenName := providers.sortedProviders(repositoryNames)[0]
+		chosen := r.LatestVersion[chosenName]
+		chosen.ChosenProviderFor = append(chosen.ChosenProviderFor, query)
+		r.LatestVersion[chosenName] = chosen
+		versions := r.AllVersions[chosenName]
+		for i := range versions {
+			if versions[i].Version == chosen.Version && versions[i].Repository == chosen.Repository {
+				versions[i].ChosenProviderFor = chosen.ChosenProviderFor
+			}
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+// ProxyOptions are the options of the proxy command
+type ProxyOptions struct {
+	ListenAddress string
+	// MaxAge is how long a cached APKINDEX is served before it is revalidated with the upstream repository
+	MaxAge time.Duration
+}
+
+// cachedAPKINDEX is an APKINDEX cached by the proxy together with the validators upstream returned for it
+type cachedAPKINDEX struct {
+	content      []byte
+	etag         string
+	lastModified string
+	modified     time.Time
+	validated    time.Time
+}
+
+// apkindexProxy serves cached copies of the APKINDEX of the repositories, injecting the auth token upstream so clients
+// never need it
+type apkindexProxy struct {
+	repositories map[string]Repository
+	authToken    string
+	maxAge       time.Duration
+	// mutex guards cached and locks
+	mutex sync.Mutex
+	// cached is keyed by repository ID and architecture, see cacheKey
+	cached map[string]*cachedAPKINDEX
+	// locks serialise the revalidation of each cached APKINDEX, guarding its validated time, so a slow repository does
+	// not hold up the others
+	locks map[string]*sync.Mutex
+}
+
+// proxyUpstreamClient is the HTTP client the proxy revalidates with, its timeout bounds how long clients wait on a
+// repository which does not respond before a stale copy is served
+var proxyUpstreamClient = &http.Client{Timeout: 30 * time.Second}
+
+// lock locks revalidating the cached APKINDEX of the key, returning the function which unlocks it
+func (p *apkindexProxy) lock(key string) func() {
+	p.mutex.Lock()
+	lock, found := p.locks[key]
+	if !found {
+		lock = &sync.Mutex{}
+		p.locks[key] = lock
+	}
+	p.mutex.Unlock()
+	lock.Lock()
+	return lock.Unlock
+}
+
+// revalidate returns the cached APKINDEX of the repository, fetching it when it is not cached and revalidating it with
+// a conditional request once it is older than the maximum age. A stale copy is served when upstream cannot be reached.
+func (p *apkindexProxy) revalidate(apkRepository Repository) (*cachedAPKINDEX, error) {
+	key := cacheKey(apkRepository)
+	defer p.lock(key)()
+	p.mutex.Lock()
+	cached := p.cached[key]
+	p.mutex.Unlock()
+	if cached != nil && time.Since(cached.validated) < p.maxAge {
+		return cached, nil
+	}
+
+	var fetchErrors []error
+	for _, APKINDEXurl := range apkRepository.URLs() {
+		req, err := newRepositoryRequest(APKINDEXurl, apkRepository, p.authToken)
+		if err != nil {
+			return nil, err
+		}
+		if cached != nil && cached.etag != "" {
+			req.Header.Set("If-None-Match", cached.etag)
+		}
+		if cached != nil && cached.lastModified != "" {
+			req.Header.Set("If-Modified-Since", cached.lastModified)
+		}
+		resp, err := proxyUpstreamClient.Do(req)
+		if err != nil {
+			fetchErrors = append(fetchErrors, err)
+			continue
+		}
+		content, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		switch {
+		case resp.StatusCode == http.StatusNotModified && cached != nil:
+			logVerbose("APKINDEX of %s repository not modified at %s", apkRepository.Name, APKINDEXurl)
+			cached.validated = time.Now()
+			return cached, nil
+		case resp.StatusCode != http.StatusOK:
+			fetchErrors = append(fetchErrors, fmt.Errorf("failed to download %s: %s", APKINDEXurl, resp.Status))
+			continue
+		case err != nil:
+			fetchErrors = append(fetchErrors, fmt.Errorf("failed to download %s: %w", APKINDEXurl, err))
+			continue
+		}
+		logVerbose("Fetched APKINDEX of %s repository from %s", apkRepository.Name, APKINDEXurl)
+		cached = &cachedAPKINDEX{
+			content:      content,
+			etag:         resp.Header.Get("ETag"),
+			lastModified: resp.Header.Get("Last-Modified"),
+			modified:     time.Now(),
+			validated:    time.Now(),
+		}
+		if lastModified, err := http.ParseTime(cached.lastModified); err == nil {
+			cached.modified = lastModified
+		}
+		p.mutex.Lock()
+		p.cached[key] = cached
+		p.mutex.Unlock()
+		return cached, nil
+	}
+	if cached != nil {
+		logVerbose("Serving stale APKINDEX of %s repository: %v", apkRepository.Name, errors.Join(fetchErrors...))
+		return cached, nil
+	}
+	return nil, errors.Join(fetchErrors...)
+}
+
+// cacheKey returns the key of the cached APKINDEX of the repository, its ID and architecture
+func cacheKey(apkRepository Repository) string {
+	return apkRepository.ID + "/" + apkRepository.Arch
+}
+
+// ServeHTTP serves /REPOSITORY[/ARCH]/APKINDEX.tar.gz from the cache and passes /REPOSITORY[/ARCH]/PACKAGE.apk through
+// to the repository, so a client can use http://PROXY/REPOSITORY as its repository URL. Without ARCH the architecture
+// the repository is configured for is served, with ARCH the repository of that architecture, as apk requests
+// REPOSITORY/ARCH/APKINDEX.tar.gz.
+func (p *apkindexProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		http.Error(w, "only GET and HEAD are supported", http.StatusMethodNotAllowed)
+		return
+	}
+	segments := strings.Split(strings.TrimPrefix(r.URL.Path, "/"), "/")
+	apkRepository, found := p.repositories[segments[0]]
+	if !found || len(segments) < 2 || len(segments) > 3 {
+		http.NotFound(w, r)
+		return
+	}
+	fileName := segments[len(segments)-1]
+	if len(segments) == 3 {
+		arch := segments[1]
+		if !isSupportedArch(arch) {
+			http.NotFound(w, r)
+			return
+		}
+		// the APKINDEX of a repository whose architecture is unknown, such as one given as an APKINDEX.tar.gz URL,
+		// cannot be found for another architecture
+		if arch != apkRepository.Arch {
+			if apkRepository.Arch == "" {
+				http.NotFound(w, r)
+				return
+			}
+			apkRepository = apkRepository.forArch(arch)
+		}
+	}
+
+	switch {
+	case fileName == "APKINDEX.tar.gz":
+		cached, err := p.revalidate(apkRepository)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		checksum := sha256.Sum256(cached.content)
+		w.Header().Set("ETag", `"`+hex.EncodeToString(checksum[:])+`"`)
+		w.Header().Set("Content-Type", "application/gzip")
+		// ServeContent answers conditional requests from clients with 304 Not Modified
+		http.ServeContent(w, r, fileName, cached.modified, bytes.NewReader(cached.content))
+	case strings.HasSuffix(fileName, ".apk"):
+		p.passThrough(w, apkRepository, fileName)
+	default:
+		http.NotFound(w, r)
+	}
+}
+
+// passThrough streams a package from the first of the repository URL or its mirrors which serves it
+func (p *apkindexProxy) passThrough(w http.ResponseWriter, apkRepository Repository, fileName string) {
+	var downloadErrors []error
+	for _, APKINDEXurl := range apkRepository.URLs() {
+		apkLocation := APKINDEXurl[:strings.LastIndex(APKINDEXurl, "/")+1] + fileName
+		resp, err := httpGet(apkLocation, apkRepository, p.authToken)
+		if err != nil {
+			downloadErrors = append(downloadErrors, err)
+			continue
+		}
+		defer resp.Body.Close()
+		w.Header().Set("Content-Type", "application/octet-stream")
+		if resp.ContentLength >= 0 {
+			w.Header().Set("Content-Length", fmt.Sprint(resp.ContentLength))
+		}
+		if _, err := io.Copy(w, resp.Body); err != nil {
+			logVerbose("Failed to pass through %s: %v", apkLocation, err)
+		}
+		return
+	}
+	http.Error(w, errors.Join(downloadErrors...).Error(), http.StatusBadGateway)
+}
+
+// runProxy serves cached copies of the APKINDEX of the repositories over HTTP, revalidating them upstream with
+// conditional requests and injecting the auth token, so a fleet of CI runners behind the proxy neither hammers the
+// repositories nor needs the token
+func runProxy(args []string, repositories []Repository, authToken string, options ProxyOptions) int {
+	if len(args) > 0 || options.ListenAddress == "" || options.MaxAge < 0 {
+		fmt.Fprintf(stderr, "Usage: %s proxy --listen ADDRESS [--max-age DURATION]\n", os.Args[0])
+		return exitUsage
+	}
+	proxy := &apkindexProxy{
+		repositories: make(map[string]Repository),
+		authToken:    authToken,
+		maxAge:       options.MaxAge,
+		cached:       make(map[string]*cachedAPKINDEX),
+		locks:        make(map[string]*sync.Mutex),
+	}
+	for _, apkRepository := range repositories {
+		proxy.repositories[apkRepository.ID] = apkRepository
+		fmt.Fprintf(stderr, "Proxying %s repository on http://%s/%s\n", apkRepository.Name, options.ListenAddress, apkRepository.ID)
+	}
+	if err := http.ListenAndServe(options.ListenAddress, proxy); err != nil {
+		fmt.Fprintf(stderr, "Failed to serve on %s: %v\n", options.ListenAddress, err)
+		return exitUsage
+	}
+	return 0
+}
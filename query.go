@@ -0,0 +1,354 @@
+package main
+
+import (
+	"fmt"
+	"path"
+	"regexp"
+	"sort"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// Matcher decides whether a package found in an APKINDEX satisfies a single query passed on the command line
+type Matcher interface {
+	// Query returns the query string exactly as it was specified by the user
+	Query() string
+	// Matches reports whether the package satisfies the query
+	Matches(pkg *repository.Package) bool
+}
+
+// exactMatcher matches packages whose name is exactly the query
+type exactMatcher struct {
+	query string
+}
+
+func (m exactMatcher) Query() string {
+	return m.query
+}
+
+func (m exactMatcher) Matches(pkg *repository.Package) bool {
+	return pkg.Name == m.query
+}
+
+// exactMatcherSet matches packages whose name is exactly one of many queries with a single map lookup, so matching
+// stays linear in the number of packages however many names are queried, e.g. with --input-file
+type exactMatcherSet struct {
+	names map[string]struct{}
+	// order maps every query, exact or not, to its index in the queries as specified, as the set is matched after the
+	// other matchers
+	order map[string]int
+}
+
+// Query returns the queries of the set separated by space
+func (m exactMatcherSet) Query() string {
+	names := make([]string, 0, len(m.names))
+	for name := range m.names {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, " ")
+}
+
+func (m exactMatcherSet) Matches(pkg *repository.Package) bool {
+	_, found := m.names[pkg.Name]
+	return found
+}
+
+// regexMatcher matches packages whose name is equal to the pattern or matches it as a regular expression
+type regexMatcher struct {
+	query   string
+	pattern string
+	regex   *regexp.Regexp
+}
+
+func (m regexMatcher) Query() string {
+	return m.query
+}
+
+func (m regexMatcher) Matches(pkg *repository.Package) bool {
+	return pkg.Name == m.pattern || m.regex.MatchString(pkg.Name)
+}
+
+// globMatcher matches packages whose whole name matches the shell pattern, e.g. py3.13-*
+type globMatcher struct {
+	query   string
+	pattern string
+}
+
+func (m globMatcher) Query() string {
+	return m.query
+}
+
+func (m globMatcher) Matches(pkg *repository.Package) bool {
+	matched, _ := path.Match(m.pattern, pkg.Name)
+	return matched
+}
+
+// fuzzyMatcher matches packages whose name is within a small edit distance of the pattern, a third of its length, to
+// find packages when the exact name is not known
+type fuzzyMatcher struct {
+	query   string
+	pattern string
+}
+
+func (m fuzzyMatcher) Query() string {
+	return m.query
+}
+
+func (m fuzzyMatcher) Matches(pkg *repository.Package) bool {
+	return levenshteinDistance(m.pattern, pkg.Name) <= max(1, len(m.pattern)/3)
+}
+
+// virtualProvidePrefixes are the prefixes of queries which are resolved through the provides of packages rather than
+// their names
+var virtualProvidePrefixes = []string{"cmd:", "so:", "pc:"}
+
+// providesMatcher matches packages which provide the virtual query, e.g. cmd:python3, so:libcrypto.so.3 or pc:libffi
+type providesMatcher struct {
+	query string
+}
+
+func (m providesMatcher) Query() string {
+	return m.query
+}
+
+func (m providesMatcher) Matches(pkg *repository.Package) bool {
+	for _, provide := range pkg.Provides {
+		if provideName(provide) == m.query {
+			return true
+		}
+	}
+	return false
+}
+
+// provideName strips the version from a provides entry, e.g. cmd:python3=3.12.4-r0 becomes cmd:python3
+func provideName(provide string) string {
+	if i := strings.IndexAny(provide, "=<>~"); i >= 0 {
+		return provide[:i]
+	}
+	return provide
+}
+
+// provideVersion returns the version of a versioned provide such as so:libc.so.6=6, empty for a provide without one
+func provideVersion(provide string) string {
+	if i := strings.IndexByte(provide, '='); i >= 0 {
+		return provide[i+1:]
+	}
+	return ""
+}
+
+// isVirtualProvide reports whether the query is for a virtual provide such as cmd:python3
+func isVirtualProvide(query string) bool {
+	for _, prefix := range virtualProvidePrefixes {
+		if strings.HasPrefix(query, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// isValidRegex reports whether the pattern is a valid regular expression for the engine selected by --regex-engine
+func isValidRegex(pattern string) bool {
+	if regexEngine == "pcre" {
+		_, err := compilePCRE(pattern)
+		return err == nil
+	}
+	_, err := regexp.Compile(pattern)
+	return err == nil
+}
+
+// matcherKind is a registered way of matching queries against packages. The kind of a query is selected by its
+// prefix, e.g. re:python-3\.1[23], and otherwise by --match, so new kinds of matchers are added by registering them
+// rather than with new flags.
+type matcherKind struct {
+	// Name selects the kind with --match
+	Name string
+	// Prefixes select the kind for queries starting with one of them
+	Prefixes []string
+	// KeepPrefix passes the whole query as the pattern, for kinds whose prefix is part of what is matched such as the
+	// cmd: of virtual provides
+	KeepPrefix bool
+	// New returns the matcher of the query, as specified by the user, for pattern
+	New func(query string, pattern string) Matcher
+}
+
+// matcherKinds are the registered kinds of matchers, in the order they were registered
+var matcherKinds []matcherKind
+
+// defaultMatcherKind is the kind of matcher of queries without a prefix, set by --match
+var defaultMatcherKind = "exact"
+
+// registerMatcherKind adds a kind of matcher which can be selected by --match or a query prefix
+func registerMatcherKind(kind matcherKind) {
+	matcherKinds = append(matcherKinds, kind)
+}
+
+func init() {
+	registerMatcherKind(matcherKind{Name: "exact", New: func(query string, pattern string) Matcher {
+		return exactMatcher{query: query}
+	}})
+	registerMatcherKind(matcherKind{Name: "regex", Prefixes: []string{"re:"}, New: func(query string, pattern string) Matcher {
+		return newRegexMatcher(query, pattern, regexEngine)
+	}})
+	registerMatcherKind(matcherKind{Name: "glob", Prefixes: []string{"glob:"}, New: func(query string, pattern string) Matcher {
+		return globMatcher{query: query, pattern: pattern}
+	}})
+	registerMatcherKind(matcherKind{Name: "fuzzy", Prefixes: []string{"fuzzy:"}, New: func(query string, pattern string) Matcher {
+		return fuzzyMatcher{query: query, pattern: pattern}
+	}})
+	registerMatcherKind(matcherKind{Name: "provides", Prefixes: virtualProvidePrefixes, KeepPrefix: true, New: func(query string, pattern string) Matcher {
+		return providesMatcher{query: query}
+	}})
+}
+
+// newRegexMatcher returns the matcher of a regex query compiled with the engine, re2 or pcre. Queries which are not valid
+// regular expressions are matched on exact package name.
+func newRegexMatcher(query string, pattern string, engine string) Matcher {
+	if engine == "pcre" {
+		regex, err := compilePCRE(pattern)
+		if err != nil {
+			return exactMatcher{query: query}
+		}
+		return newPCREMatcher(query, pattern, regex)
+	}
+	regex, err := regexp.Compile(pattern)
+	if err != nil {
+		return exactMatcher{query: query}
+	}
+	return regexMatcher{query: query, pattern: pattern, regex: regex}
+}
+
+// matcherKindNames returns the names of the registered kinds of matchers
+func matcherKindNames() []string {
+	names := make([]string, 0, len(matcherKinds))
+	for _, kind := range matcherKinds {
+		names = append(names, kind.Name)
+	}
+	return names
+}
+
+// setDefaultMatcherKind selects the kind of matcher of queries without a prefix
+func setDefaultMatcherKind(name string) error {
+	for _, kind := range matcherKinds {
+		if kind.Name == name {
+			defaultMatcherKind = name
+			return nil
+		}
+	}
+	return fmt.Errorf("unsupported matcher %s - supported matchers are %s", name, strings.Join(matcherKindNames(), ", "))
+}
+
+// queryMatcherKind returns the kind of matcher of the query and the pattern it matches - the kind selected by the
+// prefix of the query, otherwise defaultKind, which is always registered
+func queryMatcherKind(query string, defaultKind string) (matcherKind, string) {
+	var fallback matcherKind
+	for _, kind := range matcherKinds {
+		for _, prefix := range kind.Prefixes {
+			if strings.HasPrefix(query, prefix) {
+				if kind.KeepPrefix {
+					return kind, query
+				}
+				return kind, strings.TrimPrefix(query, prefix)
+			}
+		}
+		if kind.Name == defaultKind {
+			fallback = kind
+		}
+	}
+	return fallback, query
+}
+
+// queryDefaultKind returns the kind of matcher of queries without a prefix, regex when matchAsRegex is set
+func queryDefaultKind(matchAsRegex bool) string {
+	if matchAsRegex {
+		return "regex"
+	}
+	return defaultMatcherKind
+}
+
+// isNameQuery reports whether the query is matched on exact package name, so can be misspelled
+func isNameQuery(query string, matchAsRegex bool) bool {
+	kind, pattern := queryMatcherKind(query, queryDefaultKind(matchAsRegex))
+	return kind.Name == "exact" || (kind.Name == "regex" && !isValidRegex(pattern))
+}
+
+// newMatchers builds a Matcher for each query of the kind selected by its prefix, e.g. cmd:python3 for the packages
+// providing a virtual provide or glob:py3.13-*, or otherwise of the kind selected by --match. When matchAsRegex is set
+// queries without a prefix are matched as regular expressions. Queries which are not valid regular expressions are
+// matched on exact package name.
+func newMatchers(queries []string, matchAsRegex bool) []Matcher {
+	return newMatchersWithEngine(queries, matchAsRegex, regexEngine)
+}
+
+// newRemoteMatchers is newMatchers for queries received over the network, such as by the daemon or the Atom feed of
+// watch mode. Regex queries are always compiled with re2, which runs in linear time, whatever --regex-engine is, so a
+// client cannot tie the server up with a backtracking pattern.
+func newRemoteMatchers(queries []string, matchAsRegex bool) []Matcher {
+	return newMatchersWithEngine(queries, matchAsRegex, "re2")
+}
+
+// newMatchersWithEngine is newMatchers compiling regex queries with the engine
+func newMatchersWithEngine(queries []string, matchAsRegex bool, engine string) []Matcher {
+	defaultKind := queryDefaultKind(matchAsRegex)
+	matchers := make([]Matcher, 0, len(queries))
+	var exactQueries []string
+	order := make(map[string]int, len(queries))
+	for i, query := range queries {
+		if _, seen := order[query]; !seen {
+			order[query] = i
+		}
+		kind, pattern := queryMatcherKind(query, defaultKind)
+		var matcher Matcher
+		if kind.Name == "regex" {
+			matcher = newRegexMatcher(query, pattern, engine)
+		} else {
+			matcher = kind.New(query, pattern)
+		}
+		if exact, isExact := matcher.(exactMatcher); isExact {
+			exactQueries = append(exactQueries, exact.query)
+		}
+		matchers = append(matchers, matcher)
+	}
+	// many exact names are matched with a single lookup rather than one comparison per name
+	if len(exactQueries) > 1 {
+		set := exactMatcherSet{names: make(map[string]struct{}, len(exactQueries)), order: order}
+		for _, query := range exactQueries {
+			set.names[query] = struct{}{}
+		}
+		otherMatchers := make([]Matcher, 0, len(matchers)-len(exactQueries)+1)
+		for _, matcher := range matchers {
+			if _, isExact := matcher.(exactMatcher); !isExact {
+				otherMatchers = append(otherMatchers, matcher)
+			}
+		}
+		return append(otherMatchers, set)
+	}
+	return matchers
+}
+
+// matchReference returns the queries which matched the package, in the order they were specified
+func matchReference(matchers []Matcher, pkg *repository.Package) []string {
+	var matchedQueries []string
+	var set *exactMatcherSet
+	for _, matcher := range matchers {
+		if !matcher.Matches(pkg) {
+			continue
+		}
+		// the query of a set which matched is the package name itself
+		if exactSet, isSet := matcher.(exactMatcherSet); isSet {
+			set = &exactSet
+			matchedQueries = append(matchedQueries, pkg.Name)
+		} else {
+			matchedQueries = append(matchedQueries, matcher.Query())
+		}
+	}
+	// the other matchers are in the order their queries were specified, the name matched by the set is put back
+	// among them
+	if set != nil && len(matchedQueries) > 1 {
+		sort.SliceStable(matchedQueries, func(i, j int) bool {
+			return set.order[matchedQueries[i]] < set.order[matchedQueries[j]]
+		})
+	}
+	return removeDuplicates(matchedQueries)
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+
+	"github.com/dustin/go-humanize"
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// RemovedPackage is a package which was in a previous snapshot of a repository but no longer is
+type RemovedPackage struct {
+	Name        string
+	LastVersion string
+}
+
+// latestVersions returns the latest version of each package in the packages
+func latestVersions(packages []*repository.Package) map[string]string {
+	versions := make(map[string]string)
+	for _, pkg := range packages {
+		if latestVersion, found := versions[pkg.Name]; !found || versionGreaterThan(pkg.Version, latestVersion) {
+			versions[pkg.Name] = pkg.Version
+		}
+	}
+	return versions
+}
+
+// findRemoved returns the packages in previous which are not in current, sorted by name. Ignored packages are never
+// reported as they are not in current.
+func findRemoved(previous map[string]string, current map[string]string) []RemovedPackage {
+	var removedPackages []RemovedPackage
+	for packageName, lastVersion := range previous {
+		if _, found := current[packageName]; !found && !isIgnored(packageName) {
+			removedPackages = append(removedPackages, RemovedPackage{Name: packageName, LastVersion: lastVersion})
+		}
+	}
+	sort.Slice(removedPackages, func(i, j int) bool {
+		return removedPackages[i].Name < removedPackages[j].Name
+	})
+	return removedPackages
+}
+
+// runRemoved reports packages which disappeared from the repositories. Each repository is compared against the baseline
+// of its packages saved by the previous run, which is then advanced to the current packages, or, when specified, all
+// repositories are compared against an older APKINDEX.tar.gz file. Only this command advances the baselines, so other
+// commands fetching the repositories in between do not hide removals.
+func runRemoved(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) > 1 {
+		fmt.Fprintf(stderr, "Usage: %s removed [OLD_APKINDEX.tar.gz]\n", os.Args[0])
+		return exitUsage
+	}
+
+	previousPackages := make(map[string]map[string]string)
+	if len(args) == 1 {
+		previousIndex, err := fetchAPKINDEX(Repository{ID: "previous", Name: "previous index", URL: args[0]}, "")
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to load %s: %v\n", args[0], err)
+			return exitUsage
+		}
+		previousPackages[args[0]] = latestVersions(previousIndex.Packages)
+	} else {
+		for _, apkRepository := range repositories {
+			baseline, err := packageCache.Get(removedBaselineKey(apkRepository))
+			if err != nil {
+				fmt.Fprintf(stderr, "Failed to read the cache: %v\n", err)
+				return exitUsage
+			}
+			if baseline == nil {
+				fmt.Fprintf(stderr, "No previous snapshot of %s repository - removed packages will be reported from the next run\n", apkRepository.Name)
+				continue
+			}
+			var previous map[string]string
+			if err := json.Unmarshal(baseline.Value, &previous); err != nil {
+				fmt.Fprintf(stderr, "Failed to load previous snapshot of %s repository: %v\n", apkRepository.Name, err)
+				return exitUsage
+			}
+			logVerbose("Comparing %s repository against the snapshot taken %s", apkRepository.Name, humanize.Time(baseline.Modified))
+			previousPackages[apkRepository.Name] = previous
+		}
+	}
+
+	currentPackages := make(map[string][]*repository.Package)
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		currentPackages[apkRepository.Name] = append(currentPackages[apkRepository.Name], pkg)
+		if len(args) == 1 {
+			currentPackages[args[0]] = append(currentPackages[args[0]], pkg)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	if len(args) == 0 {
+		// a repository which could not be loaded keeps its baseline rather than report every package as removed next run
+		skipped := make(map[string]bool)
+		for _, loadError := range loadErrors {
+			skipped[loadError.Repository] = true
+		}
+		for _, apkRepository := range repositories {
+			if !skipped[apkRepository.Name] {
+				saveRemovedBaseline(apkRepository, latestVersions(currentPackages[apkRepository.Name]))
+			}
+		}
+	}
+
+	removedPackages := make(map[string][]RemovedPackage)
+	for previousName, previous := range previousPackages {
+		if removed := findRemoved(previous, latestVersions(currentPackages[previousName])); len(removed) > 0 {
+			removedPackages[previousName] = removed
+		}
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(removedPackages, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	if len(removedPackages) == 0 {
+		fmt.Fprintln(stdout, "No packages removed")
+		return 0
+	}
+	previousNames := make([]string, 0, len(removedPackages))
+	for previousName := range removedPackages {
+		previousNames = append(previousNames, previousName)
+	}
+	sort.Strings(previousNames)
+	for _, previousName := range previousNames {
+		if len(args) == 1 {
+			fmt.Fprintf(stdout, "Packages in %s which are no longer in any repository:\n", previousName)
+		} else {
+			fmt.Fprintf(stdout, "Packages removed from %s repository since the previous snapshot:\n", previousName)
+		}
+		for _, removedPackage := range removedPackages[previousName] {
+			fmt.Fprintf(stdout, "%s (last version %s)\n", removedPackage.Name, removedPackage.LastVersion)
+		}
+	}
+	return 0
+}
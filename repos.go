@@ -0,0 +1,93 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// RepositoryStatus is a configured repository and, for non public repositories, whether credentials were found and
+// accepted. The token itself is never included.
+type RepositoryStatus struct {
+	ID           string
+	Name         string
+	URL          string
+	Arch         string `json:",omitempty"`
+	RequiresAuth bool
+	// CredentialsSource is where the auth token was found, empty when there is none
+	CredentialsSource string `json:",omitempty"`
+	// Accepted is whether the repository accepted the auth token, only set when there is one to check
+	Accepted *bool  `json:",omitempty"`
+	Status   string `json:",omitempty"`
+}
+
+// authTokenSource returns the auth token and where it was found - the HTTP_AUTH environment variable, the --auth-token
+// flag or its own environment variable - or empty strings when there is none
+func authTokenSource(flagValue string) (string, string) {
+	if value, exists := os.LookupEnv("HTTP_AUTH"); exists {
+		if value == "" {
+			return "", ""
+		}
+		return value, "HTTP_AUTH environment variable"
+	}
+	if flagValue == "" {
+		return "", ""
+	}
+	if envValue, exists := os.LookupEnv(flagEnvName("auth-token")); exists && envValue == flagValue {
+		return flagValue, flagEnvName("auth-token") + " environment variable"
+	}
+	return flagValue, "--auth-token flag"
+}
+
+// runRepos lists the configured repositories, which of them require authentication, where the auth token was found and
+// whether each non public repository accepted it. The token is never prompted for. repos discover finds the
+// repositories of the organizations the current chainctl identity can access.
+func runRepos(args []string, repositories []Repository, authToken string, credentialsSource string, configPath string, asJSON bool) int {
+	if len(args) == 1 && args[0] == "discover" {
+		return runReposDiscover(repositories, authToken, configPath, asJSON)
+	}
+	if len(args) != 0 {
+		fmt.Fprintf(stderr, "Usage: %s repos [discover]\n", os.Args[0])
+		return exitUsage
+	}
+	statuses := make([]RepositoryStatus, 0, len(repositories))
+	for _, apkRepository := range repositories {
+		status := RepositoryStatus{ID: apkRepository.ID, Name: apkRepository.Name, URL: apkRepository.URL, Arch: apkRepository.Arch, RequiresAuth: apkRepository.RequiresAuth}
+		if apkRepository.RequiresAuth && authToken != "" {
+			status.CredentialsSource = credentialsSource
+			access := checkAccess(apkRepository, authToken)
+			status.Accepted = &access.Accessible
+			status.Status = access.Status
+		}
+		statuses = append(statuses, status)
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return 0
+	}
+	for _, status := range statuses {
+		var details []string
+		if status.Arch != "" {
+			details = append(details, status.Arch)
+		}
+		switch {
+		case !status.RequiresAuth:
+			details = append(details, "public")
+		case status.CredentialsSource == "":
+			details = append(details, "requires auth, no credentials found")
+		case *status.Accepted:
+			details = append(details, "requires auth, credentials from "+status.CredentialsSource+" accepted")
+		default:
+			details = append(details, "requires auth, credentials from "+status.CredentialsSource+" not accepted - "+status.Status)
+		}
+		fmt.Fprintf(stdout, "%s (%s repository) %s - %s\n", status.ID, status.Name, status.URL, strings.Join(details, ", "))
+	}
+	return 0
+}
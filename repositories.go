@@ -0,0 +1,234 @@
+package main
+
+import (
+	"fmt"
+	"runtime"
+	"strings"
+)
+
+// Repository is an apk repository whose APKINDEX is queried
+type Repository struct {
+	// ID is the short identifier of the repository used to select it, e.g. with --repo
+	ID string
+	// Name is the friendly name of the repository used in output
+	Name string
+	// URL is the location of the APKINDEX.tar.gz file, either a remote URL or a local path
+	URL string
+	// Arch is the apk architecture of the packages of the repository, e.g. x86_64 or aarch64, empty when unknown such
+	// as for a local APKINDEX
+	Arch string
+	// Mirrors are alternative locations of the APKINDEX.tar.gz file, tried in order when URL fails
+	Mirrors []string
+	// Tag is the @tag of a repository listed in /etc/apk/repositories as `@tag URL`. apk only installs packages from a
+	// tagged repository when they are pinned to it, e.g. python-3.12@staging.
+	Tag string
+	// RequiresAuth is set for non public repositories which need an auth token
+	RequiresAuth bool
+	// PackagesDir, when set, is a local directory of .apk files whose packages are read from their .PKGINFO rather than
+	// from an APKINDEX
+	PackagesDir string
+	// DefinitionsURL is the base URL of the build definitions of the packages of the repository, the definition of a
+	// package is the origin package name with .yaml appended
+	DefinitionsURL string
+	// CommitsURL is the base URL of the commits of the build definitions, the commit recorded in the APKINDEX entry of
+	// a package is appended
+	CommitsURL string
+	// SecurityDB is the location of the security database (secdb) of the repository, listing the vulnerabilities fixed
+	// in each package version, either a remote URL or a local path
+	SecurityDB string
+}
+
+// apkArchitectures maps Go architectures to the apk architectures packages are published for
+var apkArchitectures = map[string]string{
+	"amd64": "x86_64",
+	"arm64": "aarch64",
+}
+
+// apkArch returns the apk architecture for arch, either an apk architecture or a Go architecture such as amd64. The
+// architecture of the running machine is used when arch is empty, falling back to x86_64 for architectures packages
+// are not published for.
+func apkArch(arch string) (string, error) {
+	if arch == "" {
+		if apkArchitecture, found := apkArchitectures[runtime.GOARCH]; found {
+			return apkArchitecture, nil
+		}
+		return "x86_64", nil
+	}
+	for goArchitecture, apkArchitecture := range apkArchitectures {
+		if arch == goArchitecture || arch == apkArchitecture {
+			return apkArchitecture, nil
+		}
+	}
+	return "", fmt.Errorf("unsupported architecture %s - supported architectures are x86_64 and aarch64", arch)
+}
+
+// defaultRepositories returns the repositories of the arch packages queried when no local APKINDEX is specified. The
+// order matters - as with /etc/apk/repositories, apk prefers earlier repositories when the same version is available in
+// more than one.
+func defaultRepositories(arch string) []Repository {
+	return []Repository{
+		{ID: "wolfi", Name: "wolfi os", Arch: arch, URL: "https://packages.wolfi.dev/os/" + arch + "/APKINDEX.tar.gz", SecurityDB: "https://packages.wolfi.dev/os/security.json", DefinitionsURL: "https://github.com/wolfi-dev/os/blob/main/", CommitsURL: "https://github.com/wolfi-dev/os/commit/"},
+		{ID: "enterprise", Name: "enterprise packages", Arch: arch, URL: "https://apk.cgr.dev/chainguard-private/" + arch + "/APKINDEX.tar.gz", RequiresAuth: true},
+		{ID: "extra", Name: "extra packages", Arch: arch, URL: "https://apk.cgr.dev/extra-packages/" + arch + "/APKINDEX.tar.gz", RequiresAuth: true},
+	}
+}
+
+// orgRepositories returns the non public apk repositories of cgr.dev organizations, queried alongside the default
+// repositories. Each is selected by the organization name.
+func orgRepositories(orgs []string, arch string) ([]Repository, error) {
+	var repositories []Repository
+	for _, org := range orgs {
+		if org == "" || strings.ContainsAny(org, "/?#") {
+			return nil, fmt.Errorf("invalid organization %q", org)
+		}
+		repositories = append(repositories, Repository{ID: org, Name: org + " organization", Arch: arch, URL: "https://apk.cgr.dev/" + org + "/" + arch + "/APKINDEX.tar.gz", RequiresAuth: true})
+	}
+	return repositories, nil
+}
+
+// URLs returns the primary URL of the repository followed by its mirrors
+func (r Repository) URLs() []string {
+	return append([]string{r.URL}, r.Mirrors...)
+}
+
+// expandRepositoryURL returns the APKINDEX URL of the architecture for a repository given as a base URL, as written in
+// /etc/apk/repositories, e.g. https://packages.wolfi.dev/os becomes https://packages.wolfi.dev/os/x86_64/APKINDEX.tar.gz.
+// URLs of an APKINDEX.tar.gz file are returned as is, as are all URLs when the architecture is unknown.
+func expandRepositoryURL(repositoryURL string, arch string) (string, bool) {
+	if arch == "" || strings.HasSuffix(repositoryURL, ".tar.gz") {
+		return repositoryURL, false
+	}
+	return strings.TrimSuffix(repositoryURL, "/") + "/" + arch + "/APKINDEX.tar.gz", true
+}
+
+// addMirrors adds mirrors, each specified as ID=URL or name=URL, to the repositories. Base URLs are expanded for the
+// architecture of the repository.
+func addMirrors(repositories []Repository, mirrors []string) error {
+	for _, mirror := range mirrors {
+		selector, mirrorURL, found := strings.Cut(mirror, "=")
+		if !found || mirrorURL == "" {
+			return fmt.Errorf("invalid mirror %q - expected REPOSITORY=URL", mirror)
+		}
+		matched := false
+		for i := range repositories {
+			if strings.EqualFold(repositories[i].ID, selector) || strings.EqualFold(repositories[i].Name, selector) {
+				expandedURL, _ := expandRepositoryURL(mirrorURL, repositories[i].Arch)
+				repositories[i].Mirrors = append(repositories[i].Mirrors, expandedURL)
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("unknown repository %s for mirror %s", selector, mirrorURL)
+		}
+	}
+	return nil
+}
+
+// addIndices sets the APKINDEX URL of repositories, each index specified as NAME=URL. NAME is the ID or name of a known
+// repository whose URL is replaced, otherwise a public repository with ID and name NAME is added. Base URLs are
+// expanded for arch, the architecture queried.
+func addIndices(repositories []Repository, indices []string, arch string) ([]Repository, error) {
+	for _, index := range indices {
+		name, indexURL, found := strings.Cut(index, "=")
+		if !found || name == "" || indexURL == "" {
+			return nil, fmt.Errorf("invalid index %q - expected NAME=URL", index)
+		}
+		indexURL, expanded := expandRepositoryURL(indexURL, arch)
+		matched := false
+		for i := range repositories {
+			if strings.EqualFold(repositories[i].ID, name) || strings.EqualFold(repositories[i].Name, name) {
+				repositories[i].URL = indexURL
+				if expanded {
+					repositories[i].Arch = arch
+				}
+				matched = true
+			}
+		}
+		if !matched {
+			apkRepository := Repository{ID: name, Name: name, URL: indexURL}
+			// the architecture is known, so the repository can be queried for other architectures too
+			if expanded {
+				apkRepository.Arch = arch
+			}
+			repositories = append(repositories, apkRepository)
+		}
+	}
+	return repositories, nil
+}
+
+// renameRepositories sets the names used in output of the repositories, each rename specified as ID=NAME or
+// name=NAME. The ID is unchanged so the repository can still be selected by it.
+func renameRepositories(repositories []Repository, renames []string) error {
+	for _, rename := range renames {
+		selector, name, found := strings.Cut(rename, "=")
+		if !found || name == "" {
+			return fmt.Errorf("invalid repository name %q - expected REPOSITORY=NAME", rename)
+		}
+		matched := false
+		for i := range repositories {
+			if strings.EqualFold(repositories[i].ID, selector) || strings.EqualFold(repositories[i].Name, selector) {
+				repositories[i].Name = name
+				matched = true
+			}
+		}
+		if !matched {
+			return fmt.Errorf("unknown repository %s to name %s", selector, name)
+		}
+	}
+	return nil
+}
+
+// repositoryNames returns the names of the repositories in order
+func repositoryNames(repositories []Repository) []string {
+	names := make([]string, 0, len(repositories))
+	for _, apkRepository := range repositories {
+		names = append(names, apkRepository.Name)
+	}
+	return names
+}
+
+// requiresAuth reports whether any of the repositories is non public and needs an auth token
+func requiresAuth(repositories []Repository) bool {
+	for _, apkRepository := range repositories {
+		if apkRepository.RequiresAuth {
+			return true
+		}
+	}
+	return false
+}
+
+// publicRepositories returns, in their original order, the repositories which do not need an auth token
+func publicRepositories(repositories []Repository) []Repository {
+	var public []Repository
+	for _, apkRepository := range repositories {
+		if !apkRepository.RequiresAuth {
+			public = append(public, apkRepository)
+		}
+	}
+	return public
+}
+
+// selectRepositories returns, in their original order, the repositories whose ID or name matches any of the selectors,
+// ignoring case. An error is returned for a selector which matches no repository.
+func selectRepositories(repositories []Repository, selectors []string) ([]Repository, error) {
+	selected := make(map[int]struct{})
+	for _, selector := range selectors {
+		matched := false
+		for i, apkRepository := range repositories {
+			if strings.EqualFold(apkRepository.ID, selector) || strings.EqualFold(apkRepository.Name, selector) {
+				selected[i] = struct{}{}
+				matched = true
+			}
+		}
+		if !matched {
+			return nil, fmt.Errorf("unknown repository %s", selector)
+		}
+	}
+	var selectedRepositories []Repository
+	for i, apkRepository := range repositories {
+		if _, found := selected[i]; found {
+			selectedRepositories = append(selectedRepositories, apkRepository)
+		}
+	}
+	return selectedRepositories, nil
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"fmt"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// indexedPackage is a package together with the repository it was found in
+type indexedPackage struct {
+	Repository Repository
+	Package    *repository.Package
+	// repositoryOrder is the position of the repository in the repositories queried, apk prefers earlier repositories
+	repositoryOrder int
+}
+
+// packageIndex is every package of the repositories indexed by name and by the virtual names it provides
+type packageIndex struct {
+	byName    map[string][]indexedPackage
+	byProvide map[string][]indexedPackage
+}
+
+// buildPackageIndex loads every package of the repositories into a packageIndex
+func buildPackageIndex(repositories []Repository, authToken string) (*packageIndex, error) {
+	index := &packageIndex{byName: make(map[string][]indexedPackage), byProvide: make(map[string][]indexedPackage)}
+	repositoryOrder := make(map[string]int)
+	for i, apkRepository := range repositories {
+		repositoryOrder[apkRepository.Name] = i
+	}
+	_, err := forEachIndexedPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		candidate := indexedPackage{Repository: apkRepository, Package: pkg, repositoryOrder: repositoryOrder[apkRepository.Name]}
+		index.byName[pkg.Name] = append(index.byName[pkg.Name], candidate)
+		for _, provide := range pkg.Provides {
+			index.byProvide[provideName(provide)] = append(index.byProvide[provideName(provide)], candidate)
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	return index, nil
+}
+
+// better reports whether candidate is preferred over current - the highest version wins and for the same version the
+// repository queried first
+func (candidate indexedPackage) better(current indexedPackage) bool {
+	if candidate.Package.Version != current.Package.Version {
+		return versionGreaterThan(candidate.Package.Version, current.Package.Version)
+	}
+	return candidate.repositoryOrder < current.repositoryOrder
+}
+
+// satisfies reports whether the package satisfies the constraint, by its name and version or through one of the
+// virtual names it provides. A provide without a version only satisfies a constraint without one, as with apk.
+func (candidate indexedPackage) satisfies(constraint Constraint) bool {
+	if candidate.Package.Name == constraint.Name {
+		return constraint.Satisfied(candidate.Package.Version)
+	}
+	for _, provide := range candidate.Package.Provides {
+		if provideName(provide) != constraint.Name {
+			continue
+		}
+		if constraint.Operator == "" || (provideVersion(provide) != "" && constraint.Satisfied(provideVersion(provide))) {
+			return true
+		}
+	}
+	return false
+}
+
+// best returns the preferred package satisfying the constraint. Constraints on package names are resolved by name and
+// otherwise through the virtual names packages provide, preferring the highest provider_priority.
+func (index *packageIndex) best(constraint Constraint) (indexedPackage, bool) {
+	var resolved indexedPackage
+	found := false
+	for _, candidate := range index.byName[constraint.Name] {
+		if candidate.satisfies(constraint) && (!found || candidate.better(resolved)) {
+			resolved, found = candidate, true
+		}
+	}
+	if found {
+		return resolved, true
+	}
+	for _, candidate := range index.byProvide[constraint.Name] {
+		if !candidate.satisfies(constraint) {
+			continue
+		}
+		if !found || candidate.Package.ProviderPriority > resolved.Package.ProviderPriority ||
+			(candidate.Package.ProviderPriority == resolved.Package.ProviderPriority && candidate.better(resolved)) {
+			resolved, found = candidate, true
+		}
+	}
+	return resolved, found
+}
+
+// resolve resolves the constraints and, transitively, the dependencies of the resolved packages. The packages are
+// returned in the order they were resolved. A constraint which the package already resolved for its name does not
+// satisfy is an error, as only one version of a package can be installed.
+func (index *packageIndex) resolve(constraints []Constraint) ([]indexedPackage, error) {
+	var resolved []indexedPackage
+	// resolvedBy are the packages resolved for each name, package or virtual, constrained so far
+	resolvedBy := make(map[string]indexedPackage)
+	queue := append([]Constraint(nil), constraints...)
+	for len(queue) > 0 {
+		constraint := queue[0]
+		queue = queue[1:]
+		if previous, done := resolvedBy[constraint.Name]; done {
+			if !previous.satisfies(constraint) {
+				return nil, fmt.Errorf("unable to resolve %s, %s-%s is already resolved", constraint, previous.Package.Name, previous.Package.Version)
+			}
+			continue
+		}
+		resolvedPackage, found := index.best(constraint)
+		if !found {
+			return nil, fmt.Errorf("unable to resolve %s", constraint)
+		}
+		// the provider may already be resolved by its name or through another of its provides
+		if previous, done := resolvedBy[resolvedPackage.Package.Name]; done {
+			if !previous.satisfies(constraint) {
+				return nil, fmt.Errorf("unable to resolve %s, %s-%s is already resolved", constraint, previous.Package.Name, previous.Package.Version)
+			}
+			resolvedBy[constraint.Name] = previous
+			continue
+		}
+		resolvedBy[constraint.Name] = resolvedPackage
+		resolvedBy[resolvedPackage.Package.Name] = resolvedPackage
+		resolved = append(resolved, resolvedPackage)
+		for _, dependency := range resolvedPackage.Package.Dependencies {
+			// conflicts are not dependencies
+			if strings.HasPrefix(dependency, "!") {
+				continue
+			}
+			dependencyConstraint, err := parseConstraint(dependency)
+			if err != nil {
+				return nil, fmt.Errorf("invalid dependency %s of %s: %w", dependency, resolvedPackage.Package.Name, err)
+			}
+			queue = append(queue, dependencyConstraint)
+		}
+	}
+	return resolved, nil
+}
@@ -0,0 +1,76 @@
+package main
+
+import (
+	"testing"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// newTestPackageIndex indexes the packages as if they were all in one repository
+func newTestPackageIndex(packages ...*repository.Package) *packageIndex {
+	index := &packageIndex{byName: make(map[string][]indexedPackage), byProvide: make(map[string][]indexedPackage)}
+	for _, pkg := range packages {
+		candidate := indexedPackage{Repository: Repository{Name: "test"}, Package: pkg}
+		index.byName[pkg.Name] = append(index.byName[pkg.Name], candidate)
+		for _, provide := range pkg.Provides {
+			index.byProvide[provideName(provide)] = append(index.byProvide[provideName(provide)], candidate)
+		}
+	}
+	return index
+}
+
+func TestPackageIndexResolve(t *testing.T) {
+	index := newTestPackageIndex(
+		&repository.Package{Name: "python-3.12", Version: "3.12.5-r1", Provides: []string{"cmd:python3=3.12.5-r1"}, Dependencies: []string{"so:libc.so.6"}},
+		&repository.Package{Name: "python-3.12", Version: "3.12.6-r0", Provides: []string{"cmd:python3=3.12.6-r0"}, Dependencies: []string{"so:libc.so.6"}},
+		&repository.Package{Name: "python-3.13", Version: "3.13.0-r0", Provides: []string{"cmd:python3=3.13.0-r0"}, ProviderPriority: 10},
+		&repository.Package{Name: "glibc", Version: "2.40-r0", Provides: []string{"so:libc.so.6=6"}},
+		&repository.Package{Name: "app", Version: "1.0-r0", Dependencies: []string{"python-3.12=3.12.5-r1", "cmd:python3<3.13"}},
+		&repository.Package{Name: "conflicting", Version: "1.0-r0", Dependencies: []string{"python-3.12=3.12.5-r1", "python-3.12>=3.12.6"}},
+		&repository.Package{Name: "unversioned", Version: "1.0-r0", Dependencies: []string{"so:libc.so.6>=7"}},
+	)
+	tests := []struct {
+		name        string
+		constraints []string
+		want        []string
+		wantErr     bool
+	}{
+		{name: "latest version", constraints: []string{"python-3.12"}, want: []string{"python-3.12-3.12.6-r0", "glibc-2.40-r0"}},
+		{name: "exact version", constraints: []string{"python-3.12=3.12.5-r1"}, want: []string{"python-3.12-3.12.5-r1", "glibc-2.40-r0"}},
+		{name: "highest provider priority", constraints: []string{"cmd:python3"}, want: []string{"python-3.13-3.13.0-r0"}},
+		{name: "provide version", constraints: []string{"cmd:python3<3.13"}, want: []string{"python-3.12-3.12.6-r0", "glibc-2.40-r0"}},
+		{name: "provide of resolved package", constraints: []string{"app"}, want: []string{"app-1.0-r0", "python-3.12-3.12.5-r1", "glibc-2.40-r0"}},
+		{name: "unsatisfiable version", constraints: []string{"python-3.12>3.12.6-r0"}, wantErr: true},
+		{name: "unknown package", constraints: []string{"nodejs"}, wantErr: true},
+		{name: "resolved package does not satisfy", constraints: []string{"conflicting"}, wantErr: true},
+		{name: "provide without version", constraints: []string{"unversioned"}, wantErr: true},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			var constraints []Constraint
+			for _, constraint := range test.constraints {
+				parsed, err := parseConstraint(constraint)
+				if err != nil {
+					t.Fatalf("parseConstraint(%q) error = %v", constraint, err)
+				}
+				constraints = append(constraints, parsed)
+			}
+			resolved, err := index.resolve(constraints)
+			if (err != nil) != test.wantErr {
+				t.Fatalf("resolve(%v) error = %v, want error %v", test.constraints, err, test.wantErr)
+			}
+			var got []string
+			for _, resolvedPackage := range resolved {
+				got = append(got, resolvedPackage.Package.Name+"-"+resolvedPackage.Package.Version)
+			}
+			if len(got) != len(test.want) {
+				t.Fatalf("resolve(%v) = %v, want %v", test.constraints, got, test.want)
+			}
+			for i := range got {
+				if got[i] != test.want[i] {
+					t.Fatalf("resolve(%v) = %v, want %v", test.constraints, got, test.want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,730 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/dustin/go-humanize"
+	"github.com/knqyf263/go-apk-version"
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// PackageMeta is the information we report about a single version of a package found in a repository
+type PackageMeta struct {
+	Version    string
+	BuildTime  time.Time
+	Repository string
+	Origin     string
+	// Size is the size of the apk and InstalledSize the size of its files once installed, both in bytes
+	Size          uint64
+	InstalledSize uint64
+	// Description is the package description, only rendered in apk output format
+	Description string `json:"-"`
+	// URL is the homepage of the upstream project of the package
+	URL string `json:",omitempty"`
+	// Commit is the commit of the build definitions which produced the build
+	Commit string `json:",omitempty"`
+	// Replaces are the packages whose files this package may overwrite and InstallIf the packages whose installation
+	// pulls this package in automatically
+	Replaces  []string `json:",omitempty"`
+	InstallIf []string `json:",omitempty"`
+	// ReplacesPriority decides which package owns a file when both replace each other. It is only recorded in the
+	// apk, not the APKINDEX, so it is only set with --replaces-priority.
+	ReplacesPriority uint64 `json:",omitempty"`
+	// ProviderPriority decides which of the packages providing the same virtual provide apk installs
+	ProviderPriority uint64 `json:",omitempty"`
+	// ChosenProviderFor are the virtual provide queries, e.g. cmd:python3, for which apk would install this package
+	// rather than one of the other providers
+	ChosenProviderFor []string `json:",omitempty"`
+	// DefinitionURL is the melange build definition of the origin package, when the repository publishes its definitions
+	DefinitionURL string `json:",omitempty"`
+	// MatchedQueries are the queries specified on the command line which matched this package
+	MatchedQueries []string `json:",omitempty"`
+	// Siblings are the other packages built from the same origin package as this sub package, e.g. its -dev and -doc
+	// companions
+	Siblings []string `json:",omitempty"`
+	// Explanation is why the package was included in the results, only recorded with --explain
+	Explanation *Explanation `json:",omitempty"`
+	// Checksum is the checksum of the apk recorded in the APKINDEX
+	Checksum []byte `json:"-"`
+	// Repositories are all the repositories publishing this version, only set when identical versions are merged with
+	// --merge-repositories, and ChecksumMismatch is set when their builds differ
+	Repositories     []string `json:",omitempty"`
+	ChecksumMismatch bool     `json:",omitempty"`
+}
+
+// Results holds all packages matching the queries across all the repositories queried
+type Results struct {
+	AllVersions   map[string][]PackageMeta
+	LatestVersion map[string]PackageMeta
+	// SubPackages holds the sub packages of the matching packages, i.e. packages whose origin is a matching package
+	SubPackages *Results
+	// Repositories records each repository queried and how long it took to fetch and process its APKINDEX
+	Repositories []RepositoryTiming
+	// Elapsed is the wall clock time taken to fetch and process all the repositories, which are fetched concurrently
+	// so it is less than the sum of their times
+	Elapsed time.Duration
+	// Errors are included in JSON output with JSONErrors as an errors array, so automation can tell partial results from
+	// clean ones
+	Errors []ReportedError
+	// MinVersion and MaxVersion, when set, bound the versions added to the results, both inclusive
+	MinVersion string
+	MaxVersion string
+	// DefinitionsURLs maps repository names to the base URL of the build definitions of their packages
+	DefinitionsURLs map[string]string
+}
+
+// ReportedError is a problem found while querying which did not stop the command, e.g. a repository which could not be
+// loaded or a query which matched no package
+type ReportedError struct {
+	// Kind is repository for a repository which could not be loaded, unmatched for a query which matched no package or
+	// broad for a query which matched more packages than --max-matches
+	Kind       string
+	Repository string `json:",omitempty"`
+	Query      string `json:",omitempty"`
+	Message    string
+	// Suggestions are the package names closest to an unmatched exact query
+	Suggestions []string `json:",omitempty"`
+}
+
+// inRepository reports whether any version of the named package was found in the repository
+func (r *Results) inRepository(packageName string, repositoryName string) bool {
+	for _, packageMeta := range r.AllVersions[packageName] {
+		if packageMeta.Repository == repositoryName {
+			return true
+		}
+	}
+	return false
+}
+
+// UnmatchedQueries returns the queries which matched none of the results, in the order they were specified
+func (r *Results) UnmatchedQueries(queries []string) []string {
+	matchedQueries := make(map[string]struct{})
+	for _, results := range []*Results{r, r.SubPackages} {
+		for _, versions := range results.AllVersions {
+			for _, packageMeta := range versions {
+				for _, matchedQuery := range packageMeta.MatchedQueries {
+					matchedQueries[matchedQuery] = struct{}{}
+				}
+			}
+		}
+	}
+	var unmatchedQueries []string
+	for _, query := range removeDuplicates(queries) {
+		if _, matched := matchedQueries[query]; !matched {
+			unmatchedQueries = append(unmatchedQueries, query)
+		}
+	}
+	return unmatchedQueries
+}
+
+// MatchCounts returns the number of packages, including sub packages, matched by each of the queries
+func (r *Results) MatchCounts(queries []string) map[string]int {
+	counts := make(map[string]int)
+	for _, query := range removeDuplicates(queries) {
+		// a package matched by a regex may also be nested as a sub package of another matched package
+		matchedPackages := make(map[string]struct{})
+		for _, results := range []*Results{r, r.SubPackages} {
+			for packageName, versions := range results.AllVersions {
+				if matchedQuery(versions, query) {
+					matchedPackages[packageName] = struct{}{}
+				}
+			}
+		}
+		counts[query] = len(matchedPackages)
+	}
+	return counts
+}
+
+// RepositoryTiming records the time taken to fetch and process the APKINDEX of a single repository
+type RepositoryTiming struct {
+	Name    string
+	Elapsed time.Duration
+	// Download, Parse and Match break Elapsed down into downloading, decompressing and parsing, and matching the
+	// packages of the APKINDEX
+	Download time.Duration
+	Parse    time.Duration
+	Match    time.Duration
+	// HeapInUse is the heap in use once the packages of the repository had been handled
+	HeapInUse uint64
+}
+
+// PrintOptions controls how Results are rendered
+type PrintOptions struct {
+	JSON               bool
+	APK                bool
+	AllVersions        bool
+	ShowParentPackage  bool
+	ShowSubPackages    bool
+	ShowMatchedQueries bool
+	ShowSizes          bool
+	ShowDefinitions    bool
+	ShowURL            bool
+	ShowSiblings       bool
+	Explain            bool
+	// JSONErrors nests the JSON document under a results key alongside the errors, see errorsDocument
+	JSONErrors bool
+	// MergeRepositories collapses the same version of a package in several repositories into one entry
+	MergeRepositories bool
+	// Highlight are the matchers whose matched portions of package names are highlighted in text output
+	Highlight []Matcher
+	// Sort is the order of the packages in text and apk output - name, the default, or buildtime for the most recently
+	// built packages first
+	Sort string
+	// Last limits the versions listed with AllVersions to the newest Last versions of each package when non zero
+	Last int
+	// GroupBy is query to nest the results under each of Queries, the queries in the order they were specified
+	GroupBy string
+	Queries []string
+}
+
+func NewResults() *Results {
+	results := newResults()
+	results.SubPackages = newResults()
+	return results
+}
+
+func newResults() *Results {
+	return &Results{
+		AllVersions:   make(map[string][]PackageMeta),
+		LatestVersion: make(map[string]PackageMeta),
+	}
+}
+
+// subPackagesOf returns the names of the sub packages whose origin is the named package, in alphabetical order
+func (r *Results) subPackagesOf(origin string) []string {
+	var subPackageNames []string
+	for _, subPackageName := range r.SubPackages.sortedPackageNames() {
+		if r.SubPackages.LatestVersion[subPackageName].Origin == origin {
+			subPackageNames = append(subPackageNames, subPackageName)
+		}
+	}
+	return subPackageNames
+}
+
+// treeOrigins returns the packages at the top of the tree in alphabetical order - the matching packages, except those
+// already nested as a sub package, together with the origins of all sub packages. A package matched by a regex which is
+// also a sub package of another matched package is only rendered once, nested beneath its origin.
+func (r *Results) treeOrigins() []string {
+	var origins []string
+	for _, packageName := range r.sortedPackageNames() {
+		if _, isSubPackage := r.SubPackages.AllVersions[packageName]; !isSubPackage {
+			origins = append(origins, packageName)
+		}
+	}
+	for _, subPackageName := range r.SubPackages.sortedPackageNames() {
+		origins = append(origins, r.SubPackages.LatestVersion[subPackageName].Origin)
+	}
+	origins = removeDuplicates(origins)
+	sort.Strings(origins)
+	return origins
+}
+
+// packageTreeLatest is the JSON representation of the latest version of an origin package and its sub packages
+type packageTreeLatest struct {
+	Latest      *PackageMeta           `json:",omitempty"`
+	SubPackages map[string]PackageMeta `json:",omitempty"`
+}
+
+// packageTreeAllVersions is the JSON representation of all versions of an origin package and its sub packages
+type packageTreeAllVersions struct {
+	Versions    []PackageMeta            `json:",omitempty"`
+	SubPackages map[string][]PackageMeta `json:",omitempty"`
+}
+
+// printTree renders the matching packages as a tree of origin packages with their sub packages nested beneath them
+func (r *Results) printTree(w io.Writer, options PrintOptions) error {
+	if options.JSON {
+		tree := make(map[string]interface{})
+		for _, origin := range r.treeOrigins() {
+			if options.AllVersions {
+				node := packageTreeAllVersions{Versions: r.printedVersions(origin, options)}
+				for _, subPackageName := range r.subPackagesOf(origin) {
+					if node.SubPackages == nil {
+						node.SubPackages = make(map[string][]PackageMeta)
+					}
+					node.SubPackages[subPackageName] = r.SubPackages.printedVersions(subPackageName, options)
+				}
+				tree[origin] = node
+			} else {
+				node := packageTreeLatest{}
+				if _, found := r.LatestVersion[origin]; found {
+					packageMeta := r.printedLatestVersion(origin, options)
+					node.Latest = &packageMeta
+				}
+				for _, subPackageName := range r.subPackagesOf(origin) {
+					if node.SubPackages == nil {
+						node.SubPackages = make(map[string]PackageMeta)
+					}
+					node.SubPackages[subPackageName] = r.SubPackages.printedLatestVersion(subPackageName, options)
+				}
+				tree[origin] = node
+			}
+		}
+		jsonOutput, err := json.MarshalIndent(tree, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(jsonOutput))
+		return err
+	}
+
+	for _, origin := range r.treeOrigins() {
+		if _, found := r.AllVersions[origin]; !found {
+			fmt.Fprintf(w, "Package %s:\n", highlightName(origin, options.Highlight))
+		} else if options.AllVersions {
+			fmt.Fprintf(w, "The versions of package %s are:\n", highlightName(origin, options.Highlight))
+			for _, packageMeta := range r.printedVersions(origin, options) {
+				fmt.Fprintf(w, "%s (%s) in %s%s\n", packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+			}
+		} else {
+			packageMeta := r.printedLatestVersion(origin, options)
+			fmt.Fprintf(w, "The latest version of package %s is %s (%s) in %s%s\n", highlightName(origin, options.Highlight), packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+		}
+		subPackageNames := r.subPackagesOf(origin)
+		for i, subPackageName := range subPackageNames {
+			branch, indent := "├── ", "│   "
+			if i == len(subPackageNames)-1 {
+				branch, indent = "└── ", "    "
+			}
+			if options.AllVersions {
+				fmt.Fprintf(w, "%sThe versions of sub package %s are:\n", branch, highlightName(subPackageName, options.Highlight))
+				for _, packageMeta := range r.SubPackages.printedVersions(subPackageName, options) {
+					fmt.Fprintf(w, "%s%s (%s) in %s%s\n", indent, packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+				}
+			} else {
+				packageMeta := r.SubPackages.printedLatestVersion(subPackageName, options)
+				fmt.Fprintf(w, "%sThe latest version of sub package %s is %s (%s) in %s%s\n", branch, highlightName(subPackageName, options.Highlight), packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+			}
+		}
+	}
+	return nil
+}
+
+// AddPackageMeta records a matching package found in the named repository, keeping track of both all versions and the
+// latest version of the package
+func (r *Results) AddPackageMeta(pkg *repository.Package, repositoryName string, matchedQueries []string) {
+	if (r.MinVersion != "" && versionGreaterThan(r.MinVersion, pkg.Version)) || (r.MaxVersion != "" && versionGreaterThan(pkg.Version, r.MaxVersion)) {
+		return
+	}
+	packageMeta := PackageMeta{
+		Version:          pkg.Version,
+		BuildTime:        pkg.BuildTime,
+		Repository:       repositoryName,
+		Origin:           pkg.Origin,
+		Size:             pkg.Size,
+		InstalledSize:    pkg.InstalledSize,
+		Description:      pkg.Description,
+		URL:              pkg.URL,
+		Commit:           pkg.RepoCommit,
+		Replaces:         pkg.Replaces,
+		InstallIf:        pkg.InstallIf,
+		ProviderPriority: pkg.ProviderPriority,
+		MatchedQueries:   matchedQueries,
+		Checksum:         pkg.Checksum,
+	}
+	if definitionsURL, found := r.DefinitionsURLs[repositoryName]; found {
+		packageMeta.DefinitionURL = definitionURL(definitionsURL, pkg)
+	}
+	r.AllVersions[pkg.Name] = append(r.AllVersions[pkg.Name], packageMeta)
+
+	// Now check to see if this is the latest version
+	latestVersion, latestVersionFound := r.LatestVersion[pkg.Name]
+	if !latestVersionFound || latestVersion.Version == "" || versionGreaterThan(pkg.Version, latestVersion.Version) {
+		r.LatestVersion[pkg.Name] = packageMeta
+	}
+}
+
+// SetVersionRange bounds the versions added to the results, and their sub packages, to those from minVersion up to
+// maxVersion. An empty bound is unbounded.
+func (r *Results) SetVersionRange(minVersion string, maxVersion string) error {
+	for _, bound := range []string{minVersion, maxVersion} {
+		if _, err := version.NewVersion(bound); bound != "" && err != nil {
+			return fmt.Errorf("invalid version %s: %w", bound, err)
+		}
+	}
+	r.MinVersion, r.MaxVersion = minVersion, maxVersion
+	r.SubPackages.MinVersion, r.SubPackages.MaxVersion = minVersion, maxVersion
+	return nil
+}
+
+// SetDefinitionsURLs links the packages added to the results, and their sub packages, to the build definitions of the
+// repositories which publish them
+func (r *Results) SetDefinitionsURLs(repositories []Repository) {
+	definitionsURLs := make(map[string]string)
+	for _, apkRepository := range repositories {
+		if apkRepository.DefinitionsURL != "" {
+			definitionsURLs[apkRepository.Name] = apkRepository.DefinitionsURL
+		}
+	}
+	r.DefinitionsURLs = definitionsURLs
+	r.SubPackages.DefinitionsURLs = definitionsURLs
+}
+
+// SetSiblings records on each version of the sub packages in the results, and their sub packages, the other packages
+// with the same origin. packagesByOrigin maps each origin package to the names of all packages built from it.
+func (r *Results) SetSiblings(packagesByOrigin map[string]map[string]struct{}) {
+	for _, results := range []*Results{r, r.SubPackages} {
+		for packageName, versions := range results.AllVersions {
+			origin := results.LatestVersion[packageName].Origin
+			if origin == "" || origin == packageName {
+				continue
+			}
+			siblings := []string{}
+			for siblingName := range packagesByOrigin[origin] {
+				if siblingName != packageName {
+					siblings = append(siblings, siblingName)
+				}
+			}
+			sort.Strings(siblings)
+			for i := range versions {
+				versions[i].Siblings = siblings
+			}
+			latestVersion := results.LatestVersion[packageName]
+			latestVersion.Siblings = siblings
+			results.LatestVersion[packageName] = latestVersion
+		}
+	}
+}
+
+// SetReplacesPriorities records on each version in the results, and their sub packages, which replaces other packages
+// the replaces_priority read by replacesPriority
+func (r *Results) SetReplacesPriorities(replacesPriority func(packageName string, packageMeta PackageMeta) uint64) {
+	for _, results := range []*Results{r, r.SubPackages} {
+		for packageName, versions := range results.AllVersions {
+			for i := range versions {
+				if len(versions[i].Replaces) > 0 {
+					versions[i].ReplacesPriority = replacesPriority(packageName, versions[i])
+				}
+			}
+			// the latest version is a copy of one of the versions
+			latestVersion := results.LatestVersion[packageName]
+			for _, packageMeta := range versions {
+				if packageMeta.Repository == latestVersion.Repository && packageMeta.Version == latestVersion.Version {
+					latestVersion.ReplacesPriority = packageMeta.ReplacesPriority
+				}
+			}
+			results.LatestVersion[packageName] = latestVersion
+		}
+	}
+}
+
+// definitionURL returns the URL of the build definition of the origin package of pkg in the definitions at
+// definitionsURL
+func definitionURL(definitionsURL string, pkg *repository.Package) string {
+	origin := pkg.Origin
+	if origin == "" {
+		origin = pkg.Name
+	}
+	return definitionsURL + origin + ".yaml"
+}
+
+// versionGreaterThan compares two apk version strings
+func versionGreaterThan(a string, b string) bool {
+	semver_a, _ := version.NewVersion(a)
+	semver_b, _ := version.NewVersion(b)
+	return semver_a.GreaterThan(semver_b)
+}
+
+// sortedPackageNames returns the package names in results in alphabetical order
+func (r *Results) sortedPackageNames() []string {
+	packageNameKeys := make([]string, 0, len(r.AllVersions))
+	for k := range r.AllVersions {
+		packageNameKeys = append(packageNameKeys, k)
+	}
+	sort.Strings(packageNameKeys)
+	return packageNameKeys
+}
+
+// orderedPackageNames returns the package names in results in the order selected by the print options, by default in
+// alphabetical order
+func (r *Results) orderedPackageNames(options PrintOptions) []string {
+	packageNames := r.sortedPackageNames()
+	if options.Sort == "buildtime" {
+		// packages are ordered by their most recently built version, which is not always the latest version
+		lastBuildTimes := make(map[string]time.Time, len(packageNames))
+		for _, packageName := range packageNames {
+			for _, packageMeta := range r.AllVersions[packageName] {
+				if packageMeta.BuildTime.After(lastBuildTimes[packageName]) {
+					lastBuildTimes[packageName] = packageMeta.BuildTime
+				}
+			}
+		}
+		sort.SliceStable(packageNames, func(i, j int) bool {
+			return lastBuildTimes[packageNames[i]].After(lastBuildTimes[packageNames[j]])
+		})
+	}
+	return packageNames
+}
+
+// sortedVersions returns all versions of the named package ordered from oldest to newest. The same version in several
+// repositories is ordered by repository name, so saved JSON outputs never differ only in ordering.
+func (r *Results) sortedVersions(packageName string) []PackageMeta {
+	versions := r.AllVersions[packageName]
+	sort.SliceStable(versions, func(i, j int) bool {
+		if versionGreaterThan(versions[j].Version, versions[i].Version) {
+			return true
+		}
+		if versionGreaterThan(versions[i].Version, versions[j].Version) {
+			return false
+		}
+		if versions[i].Repository != versions[j].Repository {
+			return versions[i].Repository < versions[j].Repository
+		}
+		// equal versions spelled differently, e.g. 1.0 and 1.0.0
+		return versions[i].Version < versions[j].Version
+	})
+	return versions
+}
+
+// printedVersions returns the versions of the named package to print, oldest to newest - all of them, or only the
+// newest options.Last, with the same version in several repositories merged when options.MergeRepositories is set
+func (r *Results) printedVersions(packageName string, options PrintOptions) []PackageMeta {
+	versions := r.sortedVersions(packageName)
+	if options.MergeRepositories {
+		versions = mergeRepositories(versions)
+	}
+	if options.Last > 0 && len(versions) > options.Last {
+		return versions[len(versions)-options.Last:]
+	}
+	return versions
+}
+
+// packageAnnotations renders the optional suffix of a text output line
+func packageAnnotations(packageMeta PackageMeta, options PrintOptions) string {
+	annotations := ""
+	if options.ShowParentPackage {
+		annotations += " - Parent/Origin package: " + packageMeta.Origin
+	}
+	if options.ShowURL && packageMeta.URL != "" {
+		annotations += " - Homepage: " + packageMeta.URL
+	}
+	if options.ShowDefinitions && packageMeta.DefinitionURL != "" {
+		annotations += " - Definition: " + packageMeta.DefinitionURL
+	}
+	if len(packageMeta.ChosenProviderFor) > 0 {
+		annotations += fmt.Sprintf(" - Chosen by apk for %s (provider priority %d)", strings.Join(packageMeta.ChosenProviderFor, ", "), packageMeta.ProviderPriority)
+	}
+	if options.ShowSiblings && len(packageMeta.Siblings) > 0 {
+		annotations += " - Siblings: " + strings.Join(packageMeta.Siblings, ", ")
+	}
+	if options.ShowSizes {
+		annotations += sizeAnnotation(packageMeta.Size, packageMeta.InstalledSize)
+	}
+	if packageMeta.ChecksumMismatch {
+		annotations += " - Checksum mismatch between repositories"
+	}
+	if options.ShowMatchedQueries && len(packageMeta.MatchedQueries) > 0 {
+		annotations += " - Matched queries: " + strings.Join(packageMeta.MatchedQueries, ", ")
+	}
+	if options.Explain && packageMeta.Explanation != nil {
+		annotations += explanationAnnotation(packageMeta.Explanation)
+	}
+	return annotations
+}
+
+// sizeAnnotation renders the human readable size and installed size of a package version
+func sizeAnnotation(size uint64, installedSize uint64) string {
+	return fmt.Sprintf(" - Size %s, installed size %s", humanize.IBytes(size), humanize.IBytes(installedSize))
+}
+
+// errorsDocument is the JSON output with JSONErrors, keeping the errors apart from the results so they can never be
+// mistaken for, or collide with, a package named errors
+type errorsDocument struct {
+	Results json.RawMessage `json:"results"`
+	Errors  []ReportedError `json:"errors"`
+}
+
+// Print renders the results to w either as text or JSON. With JSONErrors the JSON document is nested under a results
+// key alongside an errors array.
+func (r *Results) Print(w io.Writer, options PrintOptions) error {
+	if !options.JSON || !options.JSONErrors {
+		return r.print(w, options)
+	}
+	var document bytes.Buffer
+	if err := r.print(&document, options); err != nil {
+		return err
+	}
+	output := errorsDocument{Results: document.Bytes(), Errors: r.Errors}
+	if output.Errors == nil {
+		output.Errors = []ReportedError{}
+	}
+	jsonOutput, err := json.MarshalIndent(output, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonOutput))
+	return err
+}
+
+func (r *Results) print(w io.Writer, options PrintOptions) error {
+	if options.GroupBy == "query" {
+		return r.printGroupedByQuery(w, options)
+	}
+	if options.ShowSubPackages && !options.APK {
+		return r.printTree(w, options)
+	}
+	if options.JSON {
+		var jsonOutput []byte
+		var err error
+		if options.AllVersions {
+			sortedMatchingPackagesAllVersions := make(map[string][]PackageMeta)
+			for _, packageName := range r.sortedPackageNames() {
+				sortedMatchingPackagesAllVersions[packageName] = r.printedVersions(packageName, options)
+			}
+			jsonOutput, err = json.MarshalIndent(sortedMatchingPackagesAllVersions, "", "  ")
+		} else {
+			latestVersions := r.LatestVersion
+			if options.MergeRepositories {
+				latestVersions = make(map[string]PackageMeta, len(r.LatestVersion))
+				for packageName := range r.LatestVersion {
+					latestVersions[packageName] = r.printedLatestVersion(packageName, options)
+				}
+			}
+			jsonOutput, err = json.MarshalIndent(latestVersions, "", "  ")
+		}
+		if err != nil {
+			return fmt.Errorf("error marshalling JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(jsonOutput))
+		return err
+	}
+
+	if options.APK {
+		// mimic `apk search -v` output - name-version - description
+		for _, packageName := range r.orderedPackageNames(options) {
+			if options.AllVersions {
+				for _, packageMeta := range r.printedVersions(packageName, options) {
+					fmt.Fprintf(w, "%s-%s - %s\n", packageName, packageMeta.Version, packageMeta.Description)
+				}
+			} else {
+				packageMeta := r.LatestVersion[packageName]
+				fmt.Fprintf(w, "%s-%s - %s\n", packageName, packageMeta.Version, packageMeta.Description)
+			}
+		}
+		return nil
+	}
+
+	for _, packageName := range r.orderedPackageNames(options) {
+		if options.AllVersions {
+			fmt.Fprintf(w, "The versions of package %s are:\n", highlightName(packageName, options.Highlight))
+			for _, packageMeta := range r.printedVersions(packageName, options) {
+				fmt.Fprintf(w, "%s (%s) in %s%s\n", packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+			}
+		} else {
+			packageMeta := r.printedLatestVersion(packageName, options)
+			fmt.Fprintf(w, "The latest version of package %s is %s (%s) in %s%s\n", highlightName(packageName, options.Highlight), packageMeta.Version, formatTime(packageMeta.BuildTime), repositoryLabel(packageMeta), packageAnnotations(packageMeta, options))
+		}
+	}
+	return nil
+}
+
+// CountPerRepository returns the number of distinct matching packages found in each repository
+func (r *Results) CountPerRepository() map[string]int {
+	counts := make(map[string]int)
+	for _, versions := range r.AllVersions {
+		seenRepositories := make(map[string]struct{})
+		for _, packageMeta := range versions {
+			if _, seen := seenRepositories[packageMeta.Repository]; !seen {
+				seenRepositories[packageMeta.Repository] = struct{}{}
+				counts[packageMeta.Repository]++
+			}
+		}
+	}
+	return counts
+}
+
+// PrintCount renders only the number of matching packages, optionally broken down per repository
+func (r *Results) PrintCount(w io.Writer, perRepository bool, asJSON bool) error {
+	total := len(r.AllVersions)
+	if asJSON {
+		countOutput := map[string]interface{}{"Total": total}
+		if perRepository {
+			countOutput["Repositories"] = r.CountPerRepository()
+		}
+		jsonOutput, err := json.MarshalIndent(countOutput, "", "  ")
+		if err != nil {
+			return fmt.Errorf("error marshalling JSON: %w", err)
+		}
+		_, err = fmt.Fprintln(w, string(jsonOutput))
+		return err
+	}
+	if perRepository {
+		counts := r.CountPerRepository()
+		repositoryNames := make([]string, 0, len(counts))
+		for repositoryName := range counts {
+			repositoryNames = append(repositoryNames, repositoryName)
+		}
+		sort.Strings(repositoryNames)
+		for _, repositoryName := range repositoryNames {
+			fmt.Fprintf(w, "%s: %d\n", repositoryName, counts[repositoryName])
+		}
+		fmt.Fprintf(w, "total: %d\n", total)
+		return nil
+	}
+	_, err := fmt.Fprintln(w, total)
+	return err
+}
+
+// PrintSummary renders summary statistics of the results, useful to sanity check broad regex queries. When
+// allVersionsListed is false only the latest version of each package was listed.
+func (r *Results) PrintSummary(w io.Writer, allVersionsListed bool) {
+	versionsListed := 0
+	var newestBuildTime, oldestBuildTime time.Time
+	for _, versions := range r.AllVersions {
+		if allVersionsListed {
+			versionsListed += len(versions)
+		} else {
+			versionsListed++
+		}
+		for _, packageMeta := range versions {
+			if newestBuildTime.IsZero() || packageMeta.BuildTime.After(newestBuildTime) {
+				newestBuildTime = packageMeta.BuildTime
+			}
+			if oldestBuildTime.IsZero() || packageMeta.BuildTime.Before(oldestBuildTime) {
+				oldestBuildTime = packageMeta.BuildTime
+			}
+		}
+	}
+	fmt.Fprintln(w, "Summary:")
+	fmt.Fprintf(w, "\tPackages matched: %d\n", len(r.AllVersions))
+	fmt.Fprintf(w, "\tVersions listed: %d\n", versionsListed)
+	fmt.Fprintf(w, "\tRepositories queried: %d\n", len(r.Repositories))
+	if versionsListed > 0 {
+		fmt.Fprintf(w, "\tNewest build time: %s\n", formatTime(newestBuildTime))
+		fmt.Fprintf(w, "\tOldest build time: %s\n", formatTime(oldestBuildTime))
+	}
+	for _, repositoryTiming := range r.Repositories {
+		fmt.Fprintf(w, "\tTime taken for %s repository: %s\n", repositoryTiming.Name, repositoryTiming.Elapsed.Round(time.Millisecond))
+	}
+	fmt.Fprintf(w, "\tTotal time taken: %s\n", r.Elapsed.Round(time.Millisecond))
+}
+
+// PrintTimings prints the time taken by each stage of processing each repository and the memory used, to diagnose slow
+// runs
+func (r *Results) PrintTimings(w io.Writer) {
+	fmt.Fprintln(w, "Timings:")
+	var peakHeapInUse uint64
+	for _, repositoryTiming := range r.Repositories {
+		fmt.Fprintf(w, "\t%s repository: download %s, decompress+parse %s, match %s, total %s\n",
+			repositoryTiming.Name,
+			repositoryTiming.Download.Round(time.Millisecond),
+			repositoryTiming.Parse.Round(time.Millisecond),
+			repositoryTiming.Match.Round(time.Millisecond),
+			repositoryTiming.Elapsed.Round(time.Millisecond))
+		if repositoryTiming.HeapInUse > peakHeapInUse {
+			peakHeapInUse = repositoryTiming.HeapInUse
+		}
+	}
+	var memStats runtime.MemStats
+	runtime.ReadMemStats(&memStats)
+	fmt.Fprintf(w, "\tTotal time taken: %s\n", r.Elapsed.Round(time.Millisecond))
+	fmt.Fprintf(w, "\tPeak heap in use: %s\n", humanize.IBytes(peakHeapInUse))
+	fmt.Fprintf(w, "\tMemory obtained from the OS: %s\n", humanize.IBytes(memStats.Sys))
+}
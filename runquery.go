@@ -0,0 +1,264 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+var (
+	// errNoMatches is returned with the results when no package matched the queries or a named package does not exist
+	errNoMatches = errors.New("no package matched the queries")
+	// errVersionMissing is returned with the results when a package has no build of the queried version, in any
+	// repository or in one of the repositories with the package
+	errVersionMissing = errors.New("the queried version is missing")
+	// errBroadQueries is returned with the results when, with Strict, a query matched more packages than MaxMatches
+	errBroadQueries = errors.New("a query matched more packages than the maximum")
+)
+
+// QueryOptions are the options of a query of the packages of the repositories
+type QueryOptions struct {
+	// PackageNames are the queries, when there are none and no Origin every package is queried
+	PackageNames []string
+	// Matchers and OriginMatchers match the package names and the origin, see newMatchers
+	Matchers       []Matcher
+	OriginMatchers []Matcher
+	MatchAsRegex   bool
+	Origin         string
+	// MatchOrigin matches the queries against the origin of each package rather than its name
+	MatchOrigin bool
+	// Version, when not empty, only includes this version of each package
+	Version    string
+	MinVersion string
+	MaxVersion string
+	// ResolveParent includes the origin package of the sub packages which matched
+	ResolveParent   bool
+	ShowSubPackages bool
+	ShowSiblings    bool
+	Explain         bool
+	// MaxMatches, when not zero, reports the queries which matched more packages, failing the query with Strict
+	MaxMatches int
+	Strict     bool
+	// KeepAll adds every package to the results when there are no queries, for counts and summaries
+	KeepAll bool
+	// List, when not nil, is called for every package as it is loaded when there are no queries
+	List func(apkRepository Repository, pkg *repository.Package)
+}
+
+// runQuery loads the packages of the repositories and returns those matching the queries. Nothing is written, the
+// results are rendered by the caller. Alongside the results it returns errNoMatches, errVersionMissing,
+// errBroadQueries, or an error wrapping errLoadFailed for the repositories skipped with partialResults, with each
+// problem recorded in the Errors of the results. Without results it returns an error which stopped the query.
+func runQuery(repositories []Repository, authToken string, options QueryOptions) (*Results, error) {
+	packageNames, matchers, originMatchers := options.PackageNames, options.Matchers, options.OriginMatchers
+	results := NewResults()
+	if err := results.SetVersionRange(options.MinVersion, options.MaxVersion); err != nil {
+		return nil, fmt.Errorf("invalid --min-version or --max-version: %w", err)
+	}
+	results.SetDefinitionsURLs(repositories)
+	// the names of all packages are kept to suggest close names for queries which match nothing
+	loadedPackageNames := make(map[string]struct{})
+	// with ResolveParent the origin packages are kept, as a parent may come before its sub packages, and the queries
+	// which matched the sub packages of each parent are recorded
+	type repositoryPackage struct {
+		repositoryName string
+		pkg            *repository.Package
+	}
+	var originPackages []repositoryPackage
+	parentQueries := make(map[string][]string)
+	// packagesByOrigin holds the names of the packages built from each origin package, for ShowSiblings
+	packagesByOrigin := make(map[string]map[string]struct{})
+	// otherVersionRepositories are the repositories of each package which only have other versions than Version
+	otherVersionRepositories := make(map[string]map[string]struct{})
+	// packageExplanations record why each package was included, with Explain
+	packageExplanations := make(explanations)
+	fetchStartTime := time.Now()
+	var err error
+	results.Repositories, err = forEachPackage(repositories, authToken, func(apkRepository Repository, _package *repository.Package) {
+		APKINDEXFriendlyName := apkRepository.Name
+		if len(packageNames) > 0 {
+			loadedPackageNames[_package.Name] = struct{}{}
+			if options.MatchOrigin && _package.Origin != "" {
+				loadedPackageNames[_package.Origin] = struct{}{}
+			}
+		}
+		if options.ShowSiblings && _package.Origin != "" {
+			if packagesByOrigin[_package.Origin] == nil {
+				packagesByOrigin[_package.Origin] = make(map[string]struct{})
+			}
+			packagesByOrigin[_package.Origin][_package.Name] = struct{}{}
+		}
+		if options.Version != "" && _package.Version != options.Version {
+			if otherVersionRepositories[_package.Name] == nil {
+				otherVersionRepositories[_package.Name] = make(map[string]struct{})
+			}
+			otherVersionRepositories[_package.Name][APKINDEXFriendlyName] = struct{}{}
+			return
+		}
+		if len(originMatchers) > 0 {
+			// only packages produced by the matching origin package are of interest
+			if len(matchReference(originMatchers, &repository.Package{Name: _package.Origin})) == 0 {
+				return
+			}
+			if len(packageNames) == 0 {
+				results.AddPackageMeta(_package, APKINDEXFriendlyName, nil)
+				if options.Explain {
+					packageExplanations.record(_package.Name, APKINDEXFriendlyName, "origin", []string{options.Origin}, options.MatchAsRegex)
+				}
+				return
+			}
+		}
+		if len(packageNames) > 0 && options.MatchOrigin {
+			// queries are matched against the origin package, so every package of one melange build is included
+			origin := _package.Origin
+			if origin == "" {
+				origin = _package.Name
+			}
+			matchedQueries := matchReference(matchers, &repository.Package{Name: origin})
+			if len(matchedQueries) == 0 {
+				return
+			}
+			if options.Explain {
+				packageExplanations.record(_package.Name, APKINDEXFriendlyName, "origin", matchedQueries, options.MatchAsRegex)
+			}
+			if origin == _package.Name {
+				results.AddPackageMeta(_package, APKINDEXFriendlyName, matchedQueries)
+			} else {
+				results.SubPackages.AddPackageMeta(_package, APKINDEXFriendlyName, matchedQueries)
+			}
+			return
+		}
+		if len(packageNames) > 0 {
+			matchedQueries := matchReference(matchers, _package)
+			if len(matchedQueries) > 0 {
+				results.AddPackageMeta(_package, APKINDEXFriendlyName, matchedQueries)
+				if options.Explain {
+					packageExplanations.record(_package.Name, APKINDEXFriendlyName, "name", matchedQueries, options.MatchAsRegex)
+				}
+				if options.ResolveParent && _package.Origin != "" && _package.Origin != _package.Name {
+					parentQueries[_package.Origin] = append(parentQueries[_package.Origin], matchedQueries...)
+				}
+			}
+			if options.ResolveParent && _package.Origin == _package.Name {
+				originPackages = append(originPackages, repositoryPackage{repositoryName: APKINDEXFriendlyName, pkg: _package})
+			}
+			//is there an origin of this package and if so does it match the package name filter
+			if options.ShowSubPackages && _package.Origin != "" && _package.Origin != _package.Name {
+				if originQueries := matchReference(matchers, &repository.Package{Name: _package.Origin}); len(originQueries) > 0 {
+					results.SubPackages.AddPackageMeta(_package, APKINDEXFriendlyName, originQueries)
+					if options.Explain {
+						packageExplanations.record(_package.Name, APKINDEXFriendlyName, "origin", originQueries, options.MatchAsRegex)
+					}
+				}
+			}
+		} else {
+			// we are not matching any packages here but we still need to record all of them for counting and summary statistics
+			if options.KeepAll {
+				results.AddPackageMeta(_package, APKINDEXFriendlyName, nil)
+			}
+			if options.List != nil {
+				options.List(apkRepository, _package)
+			}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+	results.Elapsed = time.Since(fetchStartTime)
+	// parents which matched a query themselves are already in the results
+	for _, originPackage := range originPackages {
+		if queries, isParent := parentQueries[originPackage.pkg.Name]; isParent && len(matchReference(matchers, originPackage.pkg)) == 0 {
+			results.AddPackageMeta(originPackage.pkg, originPackage.repositoryName, removeDuplicates(queries))
+			if options.Explain {
+				packageExplanations.record(originPackage.pkg.Name, originPackage.repositoryName, "sub package", removeDuplicates(queries), options.MatchAsRegex)
+			}
+		}
+	}
+	results.MarkChosenProviders(packageNames, repositoryNames(repositories))
+	if options.ShowSiblings {
+		results.SetSiblings(packagesByOrigin)
+	}
+	if options.Explain {
+		results.SetExplanations(packageExplanations)
+	}
+
+	results.Errors = loadErrors
+	// missingVersions counts the packages, and repositories of packages, without the Version build
+	missingVersions := 0
+	// unmatchedNames counts the package names, rather than patterns, which matched no package
+	unmatchedNames := 0
+	for _, unmatchedQuery := range results.UnmatchedQueries(packageNames) {
+		reportedError := ReportedError{Kind: "unmatched", Query: unmatchedQuery, Message: "no package matched the query " + unmatchedQuery}
+		if _, otherVersions := otherVersionRepositories[unmatchedQuery]; otherVersions {
+			missingVersions++
+			reportedError.Message = fmt.Sprintf("package %s has no version %s in any repository", unmatchedQuery, options.Version)
+		} else if isNameQuery(unmatchedQuery, options.MatchAsRegex) {
+			unmatchedNames++
+			// only names, rather than regular expressions, patterns or virtual provides, can be misspelled
+			reportedError.Suggestions = suggestPackageNames(unmatchedQuery, loadedPackageNames)
+			if len(reportedError.Suggestions) > 0 {
+				reportedError.Message += " - did you mean " + strings.Join(reportedError.Suggestions, ", ") + "?"
+			}
+		}
+		results.Errors = append(results.Errors, reportedError)
+	}
+	// a build which has not propagated to every repository with the package is reported per repository
+	for _, packageName := range results.sortedPackageNames() {
+		var repositoryNames []string
+		for repositoryName := range otherVersionRepositories[packageName] {
+			repositoryNames = append(repositoryNames, repositoryName)
+		}
+		sort.Strings(repositoryNames)
+		for _, repositoryName := range repositoryNames {
+			if results.inRepository(packageName, repositoryName) {
+				continue
+			}
+			missingVersions++
+			results.Errors = append(results.Errors, ReportedError{Kind: "version", Repository: repositoryName, Query: packageName, Message: fmt.Sprintf("version %s of package %s is not in %s repository", options.Version, packageName, repositoryName)})
+		}
+	}
+	// a query matching more packages than expected is usually a regex missing an anchor
+	broadQueries := 0
+	if options.MaxMatches > 0 {
+		matchCounts := results.MatchCounts(packageNames)
+		for _, query := range removeDuplicates(packageNames) {
+			if matchCounts[query] > options.MaxMatches {
+				broadQueries++
+				results.Errors = append(results.Errors, ReportedError{Kind: "broad", Query: query, Message: fmt.Sprintf("the query %s matched %d packages, more than --max-matches %d - is it missing an anchor such as ^ or $?", query, matchCounts[query], options.MaxMatches)})
+			}
+		}
+	}
+
+	// partial results are still a failure, as is silently matching nothing when nothing matched or a named package
+	// does not exist
+	switch {
+	case broadQueries > 0 && options.Strict:
+		return results, errBroadQueries
+	case len(loadErrors) > 0:
+		return results, fmt.Errorf("%w of %d repositories", errLoadFailed, len(loadErrors))
+	case (len(packageNames) > 0 || options.Origin != "") && len(results.AllVersions) == 0 && len(results.SubPackages.AllVersions) == 0:
+		return results, errNoMatches
+	case unmatchedNames > 0:
+		return results, errNoMatches
+	case missingVersions > 0:
+		return results, errVersionMissing
+	}
+	return results, nil
+}
+
+// queryExitCode returns the exit code for the error runQuery returned alongside the results
+func queryExitCode(err error) int {
+	switch {
+	case errors.Is(err, errLoadFailed):
+		return exitFetchFailed
+	case errors.Is(err, errNoMatches):
+		return exitNoMatches
+	case errors.Is(err, errVersionMissing), errors.Is(err, errBroadQueries):
+		return exitConstraintViolated
+	}
+	return exitUsage
+}
@@ -0,0 +1,349 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"sync"
+	"syscall"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// ServeOptions are the options of the serve command
+type ServeOptions struct {
+	// Socket is the path of the Unix socket the daemon listens on
+	Socket string
+	// Interval is the time between refreshes of the indices
+	Interval time.Duration
+	// ListenAddress is a TCP address the API is also served on, e.g. for dashboards, when not empty
+	ListenAddress string
+}
+
+// serveReadHeaderTimeout bounds how long a client of the daemon may take to send the headers of a request, so clients
+// which connect and send nothing cannot hold connections open
+const serveReadHeaderTimeout = 10 * time.Second
+
+// daemonSocket is the Unix socket of a running daemon which packages are loaded from instead of downloading the
+// APKINDEX of each repository, empty when no daemon is used
+var daemonSocket string
+
+// defaultDaemonSocket returns the path of the Unix socket the daemon listens on when --socket is not specified
+func defaultDaemonSocket() (string, error) {
+	directory, err := stateDir()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(directory, "daemon.sock"), nil
+}
+
+// daemonIndex is the parsed APKINDEX of a repository held in memory by the daemon
+type daemonIndex struct {
+	Repository Repository
+	Packages   []*repository.Package
+	Refreshed  time.Time
+}
+
+// daemon keeps the parsed indices of the repositories in memory and answers queries on them
+type daemon struct {
+	repositories []Repository
+	authToken    string
+	mutex        sync.RWMutex
+	// indices are keyed by repository ID
+	indices map[string]*daemonIndex
+	// catalog indexes the packages of all indices for the GraphQL API, it is rebuilt on every refresh
+	catalog *packageCatalog
+	// publicCatalog indexes only the packages of the repositories which do not need an auth token, for the API served
+	// over TCP
+	publicCatalog *packageCatalog
+}
+
+// refresh fetches the APKINDEX of every repository. A repository which fails to fetch keeps serving its previous index.
+func (d *daemon) refresh() error {
+	var refreshErrors []error
+	for _, apkRepository := range d.repositories {
+		apkIndex, err := fetchAPKINDEX(apkRepository, d.authToken)
+		if err != nil {
+			refreshErrors = append(refreshErrors, fmt.Errorf("failed to load APKINDEX of %s repository: %w", apkRepository.Name, err))
+			continue
+		}
+		d.mutex.Lock()
+		d.indices[apkRepository.ID] = &daemonIndex{Repository: apkRepository, Packages: apkIndex.Packages, Refreshed: time.Now()}
+		d.mutex.Unlock()
+		logVerbose("Refreshed %s repository - %d packages", apkRepository.Name, len(apkIndex.Packages))
+	}
+	d.mutex.Lock()
+	d.catalog = newPackageCatalog(d.repositories, d.indices)
+	d.publicCatalog = newPackageCatalog(publicRepositories(d.repositories), d.indices)
+	d.mutex.Unlock()
+	return errors.Join(refreshErrors...)
+}
+
+// packageCatalog returns the catalog of the packages as of the last refresh
+func (d *daemon) packageCatalog() *packageCatalog {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.catalog
+}
+
+// publicPackageCatalog returns the catalog of the packages of the public repositories as of the last refresh
+func (d *daemon) publicPackageCatalog() *packageCatalog {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.publicCatalog
+}
+
+// index returns the in memory index of the repository, nil when it has not been fetched yet
+func (d *daemon) index(repositoryID string) *daemonIndex {
+	d.mutex.RLock()
+	defer d.mutex.RUnlock()
+	return d.indices[repositoryID]
+}
+
+// DaemonRepository describes a repository served by the daemon
+type DaemonRepository struct {
+	ID        string
+	Name      string
+	URL       string
+	Packages  int
+	Refreshed time.Time
+}
+
+// DaemonMatch is a package matching a query to the daemon
+type DaemonMatch struct {
+	Repository     string
+	Package        *repository.Package
+	MatchedQueries []string
+}
+
+// writeJSON writes the value as the JSON response
+func writeJSON(w http.ResponseWriter, value interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(value); err != nil {
+		logVerbose("Failed to write response: %v", err)
+	}
+}
+
+// handler returns the REST API of the daemon:
+//
+//	GET  /repositories                  the repositories and when each was last refreshed
+//	GET  /repositories/{id}/packages    every package of the repository, used by the command line to skip downloading
+//	GET  /packages?q=QUERY[&regex=true] the packages matching the queries, like the package names of the command line
+//	POST /refresh                       refresh the indices now
+//	GET|POST /graphql                   GraphQL queries, see newGraphQLSchema
+//
+// The handler of a remote listener, which anyone who can reach the address may query without the auth token the
+// daemon holds, only serves the read-only routes and only the repositories which do not need an auth token.
+func (d *daemon) handler(remote bool) (http.Handler, error) {
+	repositories, catalog := d.repositories, d.packageCatalog
+	if remote {
+		repositories, catalog = publicRepositories(d.repositories), d.publicPackageCatalog
+	}
+	served := make(map[string]bool)
+	for _, apkRepository := range repositories {
+		served[apkRepository.ID] = true
+	}
+	schema, err := newGraphQLSchema(catalog)
+	if err != nil {
+		return nil, err
+	}
+	mux := http.NewServeMux()
+	mux.Handle("/graphql", graphQLHandler(schema))
+	mux.HandleFunc("GET /repositories", func(w http.ResponseWriter, r *http.Request) {
+		daemonRepositories := []DaemonRepository{}
+		for _, apkRepository := range repositories {
+			if index := d.index(apkRepository.ID); index != nil {
+				daemonRepositories = append(daemonRepositories, DaemonRepository{ID: apkRepository.ID, Name: apkRepository.Name, URL: apkRepository.URL, Packages: len(index.Packages), Refreshed: index.Refreshed})
+			}
+		}
+		writeJSON(w, daemonRepositories)
+	})
+	mux.HandleFunc("GET /repositories/{id}/packages", func(w http.ResponseWriter, r *http.Request) {
+		var index *daemonIndex
+		if served[r.PathValue("id")] {
+			index = d.index(r.PathValue("id"))
+		}
+		// the packages are only served for the same repository URL, a client configured with another URL or mirror
+		// fetches the repository itself
+		if index == nil || (r.URL.Query().Has("url") && r.URL.Query().Get("url") != index.Repository.URL) {
+			http.NotFound(w, r)
+			return
+		}
+		writeJSON(w, index.Packages)
+	})
+	mux.HandleFunc("GET /packages", func(w http.ResponseWriter, r *http.Request) {
+		matchers := newRemoteMatchers(r.URL.Query()["q"], r.URL.Query().Get("regex") == "true")
+		matches := []DaemonMatch{}
+		for _, apkRepository := range repositories {
+			index := d.index(apkRepository.ID)
+			if index == nil {
+				continue
+			}
+			for _, pkg := range index.Packages {
+				if isIgnored(pkg.Name) {
+					continue
+				}
+				if matchedQueries := matchReference(matchers, pkg); len(matchedQueries) > 0 {
+					matches = append(matches, DaemonMatch{Repository: apkRepository.Name, Package: pkg, MatchedQueries: matchedQueries})
+				}
+			}
+		}
+		writeJSON(w, matches)
+	})
+	if remote {
+		return mux, nil
+	}
+	mux.HandleFunc("POST /refresh", func(w http.ResponseWriter, r *http.Request) {
+		if err := d.refresh(); err != nil {
+			http.Error(w, err.Error(), http.StatusBadGateway)
+			return
+		}
+		w.WriteHeader(http.StatusNoContent)
+	})
+	return mux, nil
+}
+
+// runServe runs a daemon which keeps the parsed indices of the repositories in memory, refreshing them on an interval
+// or on demand, and answers queries on a Unix socket. Other runs load packages from the daemon instead of downloading
+// the indices again.
+func runServe(args []string, repositories []Repository, authToken string, options ServeOptions) int {
+	if len(args) > 0 || options.Interval <= 0 {
+		fmt.Fprintf(stderr, "Usage: %s serve [--socket PATH] [--interval DURATION] [--listen ADDRESS]\n", os.Args[0])
+		return exitUsage
+	}
+	d := &daemon{repositories: repositories, authToken: authToken, indices: make(map[string]*daemonIndex)}
+	if err := d.refresh(); err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(options.Socket), 0o755); err != nil {
+		fmt.Fprintf(stderr, "Failed to create socket directory: %v\n", err)
+		return exitUsage
+	}
+	// a socket left behind by a daemon which did not shut down cleanly would make listening fail
+	if err := os.Remove(options.Socket); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		fmt.Fprintf(stderr, "Failed to remove stale socket: %v\n", err)
+		return exitUsage
+	}
+	listener, err := net.Listen("unix", options.Socket)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to listen on %s: %v\n", options.Socket, err)
+		return exitUsage
+	}
+	// the daemon holds the auth token so only the user running it may query it
+	if err := os.Chmod(options.Socket, 0o600); err != nil {
+		fmt.Fprintf(stderr, "Failed to restrict socket permissions: %v\n", err)
+		return exitUsage
+	}
+	handler, err := d.handler(false)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to create the GraphQL schema: %v\n", err)
+		return exitUsage
+	}
+	servers := []*http.Server{{Handler: handler, ReadHeaderTimeout: serveReadHeaderTimeout}}
+	// the TCP listener is created before serving so an address which cannot be listened on fails the command rather
+	// than a goroutine
+	var remoteListener net.Listener
+	if options.ListenAddress != "" {
+		remoteHandler, err := d.handler(true)
+		if err != nil {
+			listener.Close()
+			fmt.Fprintf(stderr, "Failed to create the GraphQL schema: %v\n", err)
+			return exitUsage
+		}
+		remoteListener, err = net.Listen("tcp", options.ListenAddress)
+		if err != nil {
+			listener.Close()
+			fmt.Fprintf(stderr, "Failed to listen on %s: %v\n", options.ListenAddress, err)
+			return exitUsage
+		}
+		servers = append(servers, &http.Server{Handler: remoteHandler, ReadHeaderTimeout: serveReadHeaderTimeout})
+	}
+	// both servers are shut down together, on a signal or when either fails
+	var shutdownOnce sync.Once
+	shutdown := func() {
+		shutdownOnce.Do(func() {
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+			for _, server := range servers {
+				_ = server.Shutdown(ctx)
+			}
+		})
+	}
+	remoteErrors := make(chan error, 1)
+	if remoteListener != nil {
+		go func() {
+			if err := servers[1].Serve(remoteListener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+				remoteErrors <- err
+				shutdown()
+			}
+		}()
+		fmt.Fprintf(stderr, "Serving read-only queries of the public repositories on http://%s\n", remoteListener.Addr())
+	}
+
+	go func() {
+		for range time.Tick(options.Interval) {
+			if err := d.refresh(); err != nil {
+				fmt.Fprintf(stderr, "%v\n", err)
+			}
+		}
+	}()
+	go func() {
+		signals := make(chan os.Signal, 1)
+		signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+		<-signals
+		shutdown()
+	}()
+
+	fmt.Fprintf(stderr, "Serving queries on %s\n", options.Socket)
+	if err := servers[0].Serve(listener); err != nil && !errors.Is(err, http.ErrServerClosed) {
+		shutdown()
+		fmt.Fprintf(stderr, "Failed to serve: %v\n", err)
+		return exitUsage
+	}
+	select {
+	case err := <-remoteErrors:
+		fmt.Fprintf(stderr, "Failed to serve on %s: %v\n", options.ListenAddress, err)
+		return exitUsage
+	default:
+	}
+	return 0
+}
+
+// daemonClient is an HTTP client which connects to the daemon socket whatever the host of the URL
+func daemonClient(socket string) *http.Client {
+	return &http.Client{
+		Timeout: 30 * time.Second,
+		Transport: &http.Transport{
+			DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+				return (&net.Dialer{}).DialContext(ctx, "unix", socket)
+			},
+		},
+	}
+}
+
+// daemonPackages loads the packages of the repository from the daemon
+func daemonPackages(apkRepository Repository) ([]*repository.Package, error) {
+	resp, err := daemonClient(daemonSocket).Get("http://daemon/repositories/" + url.PathEscape(apkRepository.ID) + "/packages?url=" + url.QueryEscape(apkRepository.URL))
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("daemon returned status %s", resp.Status)
+	}
+	var packages []*repository.Package
+	if err := json.NewDecoder(resp.Body).Decode(&packages); err != nil {
+		return nil, err
+	}
+	return packages, nil
+}
@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"strings"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// EpochDrift is a package whose latest version has the same upstream version in several repositories but a different
+// -rN epoch, which usually indicates an advisory rebuild that has not propagated to every repository
+type EpochDrift struct {
+	Name            string
+	UpstreamVersion string
+	// Versions maps each repository to the latest version of the package in it
+	Versions map[string]string
+}
+
+// Downgrade is a package whose latest version in a non public repository is older than its latest version in a public
+// repository, so pinning the non public repository can silently downgrade images
+type Downgrade struct {
+	Name             string
+	Version          string
+	Repository       string
+	PublicVersion    string
+	PublicRepository string
+}
+
+// SkewReport lists the version skew of packages between repositories
+type SkewReport struct {
+	Downgrades []Downgrade
+	EpochDrift []EpochDrift
+}
+
+// splitEpoch splits an apk version into the upstream version and the -rN epoch
+func splitEpoch(packageVersion string) (string, string) {
+	if i := strings.LastIndex(packageVersion, "-r"); i >= 0 {
+		return packageVersion[:i], packageVersion[i+1:]
+	}
+	return packageVersion, ""
+}
+
+// latestVersionPerRepository returns the latest version of the named package in each repository it is found in
+func (r *Results) latestVersionPerRepository(packageName string) map[string]string {
+	latestVersions := make(map[string]string)
+	for _, packageMeta := range r.AllVersions[packageName] {
+		latestVersion, found := latestVersions[packageMeta.Repository]
+		if !found || versionGreaterThan(packageMeta.Version, latestVersion) {
+			latestVersions[packageMeta.Repository] = packageMeta.Version
+		}
+	}
+	return latestVersions
+}
+
+// Skew computes the version skew report of the packages in the results found in the repositories
+func (r *Results) Skew(repositories []Repository) SkewReport {
+	var report SkewReport
+	for _, packageName := range r.sortedPackageNames() {
+		latestVersions := r.latestVersionPerRepository(packageName)
+		if len(latestVersions) < 2 {
+			continue
+		}
+		report.Downgrades = append(report.Downgrades, downgrades(packageName, latestVersions, repositories)...)
+		upstreamVersions := make(map[string]struct{})
+		epochs := make(map[string]struct{})
+		var upstreamVersion string
+		for _, latestVersion := range latestVersions {
+			var epoch string
+			upstreamVersion, epoch = splitEpoch(latestVersion)
+			upstreamVersions[upstreamVersion] = struct{}{}
+			epochs[epoch] = struct{}{}
+		}
+		if len(upstreamVersions) == 1 && len(epochs) > 1 {
+			report.EpochDrift = append(report.EpochDrift, EpochDrift{Name: packageName, UpstreamVersion: upstreamVersion, Versions: latestVersions})
+		}
+	}
+	return report
+}
+
+// downgrades compares the latest version of the named package in each non public repository with its newest version in
+// the public repositories
+func downgrades(packageName string, latestVersions map[string]string, repositories []Repository) []Downgrade {
+	var publicVersion, publicRepository string
+	for _, apkRepository := range repositories {
+		latestVersion, found := latestVersions[apkRepository.Name]
+		if found && !apkRepository.RequiresAuth && (publicVersion == "" || versionGreaterThan(latestVersion, publicVersion)) {
+			publicVersion, publicRepository = latestVersion, apkRepository.Name
+		}
+	}
+	if publicVersion == "" {
+		return nil
+	}
+	var found []Downgrade
+	for _, apkRepository := range repositories {
+		latestVersion, inRepository := latestVersions[apkRepository.Name]
+		if inRepository && apkRepository.RequiresAuth && versionGreaterThan(publicVersion, latestVersion) {
+			found = append(found, Downgrade{Name: packageName, Version: latestVersion, Repository: apkRepository.Name, PublicVersion: publicVersion, PublicRepository: publicRepository})
+		}
+	}
+	return found
+}
+
+// runSkew reports the version skew between repositories of all packages, or only those matching the queries. With
+// strict set, downgrades are reported with a non zero exit code.
+func runSkew(args []string, repositories []Repository, authToken string, matchAsRegex bool, asJSON bool, strict bool) int {
+	matchers := newMatchers(args, matchAsRegex)
+	results := NewResults()
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if len(matchers) == 0 {
+			results.AddPackageMeta(pkg, apkRepository.Name, nil)
+		} else if matchedQueries := matchReference(matchers, pkg); len(matchedQueries) > 0 {
+			results.AddPackageMeta(pkg, apkRepository.Name, matchedQueries)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	report := results.Skew(repositories)
+	exitCode := 0
+	if strict && len(report.Downgrades) > 0 {
+		exitCode = exitConstraintViolated
+	}
+
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return exitCode
+	}
+	// downgrades come first as they can silently break images
+	if len(report.Downgrades) > 0 {
+		fmt.Fprintln(stdout, "DOWNGRADES - non public repository older than a public repository, pinning it downgrades the package:")
+		for _, downgrade := range report.Downgrades {
+			fmt.Fprintf(stdout, "%s %s in %s repository is older than %s in %s repository\n", downgrade.Name, downgrade.Version, downgrade.Repository, downgrade.PublicVersion, downgrade.PublicRepository)
+		}
+	}
+	if len(report.EpochDrift) == 0 {
+		fmt.Fprintln(stdout, "No epoch drift between repositories")
+	} else {
+		fmt.Fprintln(stdout, "Epoch drift - same upstream version but a different epoch between repositories:")
+		for _, epochDrift := range report.EpochDrift {
+			repositoryNames := make([]string, 0, len(epochDrift.Versions))
+			for repositoryName := range epochDrift.Versions {
+				repositoryNames = append(repositoryNames, repositoryName)
+			}
+			sort.Strings(repositoryNames)
+			versions := make([]string, 0, len(repositoryNames))
+			for _, repositoryName := range repositoryNames {
+				versions = append(versions, fmt.Sprintf("%s in %s repository", epochDrift.Versions[repositoryName], repositoryName))
+			}
+			fmt.Fprintf(stdout, "%s %s: %s\n", epochDrift.Name, epochDrift.UpstreamVersion, strings.Join(versions, ", "))
+		}
+	}
+	return exitCode
+}
@@ -0,0 +1,111 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"sort"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// streamNameRegex matches the name of a versioned stream of an upstream project, e.g. python-3.12 or postgresql-16
+var streamNameRegex = regexp.MustCompile(`^(.+)-([0-9]+(\.[0-9]+)*)$`)
+
+// Stream is one versioned stream of an upstream project and the latest version built of it
+type Stream struct {
+	Name          string
+	StreamVersion string
+	Version       string
+	Repository    string
+}
+
+// ProjectStreams lists the streams of an upstream project which coexist, newest stream first
+type ProjectStreams struct {
+	Project string
+	Streams []Stream
+}
+
+// collectStreams groups the versioned stream packages by upstream project, keeping only projects with several streams.
+// Only origin packages are considered so sub packages such as python-3.12-dev are not mistaken for streams.
+func collectStreams(repositories []Repository, authToken string, projects []string) ([]ProjectStreams, error) {
+	wantedProjects := make(map[string]struct{}, len(projects))
+	for _, project := range projects {
+		wantedProjects[project] = struct{}{}
+	}
+	streams := make(map[string]map[string]Stream)
+	_, err := forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if pkg.Origin != "" && pkg.Origin != pkg.Name {
+			return
+		}
+		match := streamNameRegex.FindStringSubmatch(pkg.Name)
+		if match == nil {
+			return
+		}
+		project := match[1]
+		if _, wanted := wantedProjects[project]; len(wantedProjects) > 0 && !wanted {
+			return
+		}
+		if streams[project] == nil {
+			streams[project] = make(map[string]Stream)
+		}
+		if stream, found := streams[project][pkg.Name]; !found || versionGreaterThan(pkg.Version, stream.Version) {
+			streams[project][pkg.Name] = Stream{Name: pkg.Name, StreamVersion: match[2], Version: pkg.Version, Repository: apkRepository.Name}
+		}
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	var report []ProjectStreams
+	for project, projectStreams := range streams {
+		if len(projectStreams) < 2 {
+			continue
+		}
+		entry := ProjectStreams{Project: project}
+		for _, stream := range projectStreams {
+			entry.Streams = append(entry.Streams, stream)
+		}
+		sort.Slice(entry.Streams, func(i, j int) bool {
+			return versionGreaterThan(entry.Streams[i].StreamVersion, entry.Streams[j].StreamVersion)
+		})
+		report = append(report, entry)
+	}
+	sort.Slice(report, func(i, j int) bool {
+		return report[i].Project < report[j].Project
+	})
+	return report, nil
+}
+
+// runStreams lists the upstream projects, all or only those named, which have several versioned streams available
+// side by side, e.g. python-3.12 and python-3.13, to help pick the right stream
+func runStreams(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	report, err := collectStreams(repositories, authToken, args)
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	if asJSON {
+		if report == nil {
+			report = []ProjectStreams{}
+		}
+		jsonOutput, err := json.MarshalIndent(report, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	} else {
+		for _, projectStreams := range report {
+			fmt.Fprintf(stdout, "%s has %d streams:\n", projectStreams.Project, len(projectStreams.Streams))
+			for _, stream := range projectStreams.Streams {
+				fmt.Fprintf(stdout, "\t%s latest version %s in %s repository\n", stream.Name, stream.Version, stream.Repository)
+			}
+		}
+	}
+	if len(report) == 0 {
+		return exitNoMatches
+	}
+	return 0
+}
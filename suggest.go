@@ -0,0 +1,72 @@
+package main
+
+import (
+	"sort"
+	"strings"
+)
+
+// maximumSuggestions is the most package names suggested for a query which matched nothing
+const maximumSuggestions = 3
+
+// levenshteinDistance returns the number of single character insertions, deletions and substitutions needed to turn a
+// into b
+func levenshteinDistance(a string, b string) int {
+	ar, br := []rune(a), []rune(b)
+	previous := make([]int, len(br)+1)
+	current := make([]int, len(br)+1)
+	for j := range previous {
+		previous[j] = j
+	}
+	for i := 1; i <= len(ar); i++ {
+		current[0] = i
+		for j := 1; j <= len(br); j++ {
+			substitution := previous[j-1]
+			if ar[i-1] != br[j-1] {
+				substitution++
+			}
+			current[j] = min(previous[j]+1, current[j-1]+1, substitution)
+		}
+		previous, current = current, previous
+	}
+	return previous[len(br)]
+}
+
+// suggestPackageNames returns the package names closest to a query which matched nothing, e.g. python-3.13 for the
+// typo pyhton-3.13. Names starting with the query are suggested first, followed by names within an edit distance of a
+// third of the length of the query.
+func suggestPackageNames(query string, packageNames map[string]struct{}) []string {
+	type suggestion struct {
+		name string
+		// prefix is set for names starting with the query, distance is then the number of characters after the query
+		prefix   bool
+		distance int
+	}
+	maximumDistance := max(1, len(query)/3)
+	var suggestions []suggestion
+	for packageName := range packageNames {
+		if strings.HasPrefix(packageName, query) {
+			suggestions = append(suggestions, suggestion{name: packageName, prefix: true, distance: len(packageName) - len(query)})
+			continue
+		}
+		if distance := levenshteinDistance(query, packageName); distance <= maximumDistance {
+			suggestions = append(suggestions, suggestion{name: packageName, distance: distance})
+		}
+	}
+	sort.Slice(suggestions, func(i, j int) bool {
+		if suggestions[i].prefix != suggestions[j].prefix {
+			return suggestions[i].prefix
+		}
+		if suggestions[i].distance != suggestions[j].distance {
+			return suggestions[i].distance < suggestions[j].distance
+		}
+		return suggestions[i].name < suggestions[j].name
+	})
+	var names []string
+	for _, s := range suggestions {
+		if len(names) == maximumSuggestions {
+			break
+		}
+		names = append(names, s.name)
+	}
+	return names
+}
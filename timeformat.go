@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/dustin/go-humanize"
+)
+
+// timeFormat selects how times are rendered in text output, set by --time-format. The empty default renders both the
+// humanized and the full time.
+var timeFormat string
+
+// timeLocation is the time zone times are rendered in, set by --utc or --timezone. When nil times are rendered in the
+// time zone they were parsed in.
+var timeLocation *time.Location
+
+// setTimeFormat validates and sets the time format and time zone of text output
+func setTimeFormat(format string, utc bool, timezone string) error {
+	if utc && timezone != "" {
+		return fmt.Errorf("--utc and --timezone cannot be used together")
+	}
+	timeFormat = format
+	timeLocation = nil
+	if utc {
+		timeLocation = time.UTC
+	}
+	if timezone != "" {
+		location, err := time.LoadLocation(timezone)
+		if err != nil {
+			return fmt.Errorf("unknown time zone %s: %w", timezone, err)
+		}
+		timeLocation = location
+	}
+	return nil
+}
+
+// formatTime renders a time in text output - relative, rfc3339, unix seconds or any other format as a Go time layout
+func formatTime(t time.Time) string {
+	if timeLocation != nil {
+		t = t.In(timeLocation)
+	}
+	switch timeFormat {
+	case "":
+		return fmt.Sprintf("%s - %s", humanize.Time(t), t)
+	case "relative":
+		return humanize.Time(t)
+	case "rfc3339":
+		return t.Format(time.RFC3339)
+	case "unix":
+		return strconv.FormatInt(t.Unix(), 10)
+	default:
+		return t.Format(timeFormat)
+	}
+}
+
+// formatRelativeTime is formatTime for output which, by default, only renders the humanized time
+func formatRelativeTime(t time.Time) string {
+	if timeFormat == "" {
+		return humanize.Time(t)
+	}
+	return formatTime(t)
+}
@@ -0,0 +1,58 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/signal"
+	"strings"
+
+	"golang.org/x/term"
+)
+
+// errNotTerminal is returned when the auth token would be prompted for but there is no terminal to type it in
+var errNotTerminal = errors.New("stdin is not a terminal, specify the auth token via --auth-token or the HTTP_AUTH environment variable")
+
+// isTerminal reports whether fd is a terminal
+func isTerminal(fd uintptr) bool {
+	return term.IsTerminal(int(fd))
+}
+
+// promptForToken prints the prompt to stderr and reads the auth token from the terminal without echoing it, so the
+// token never lands in the terminal scrollback or a screen share
+func promptForToken(prompt string) (string, error) {
+	fd := int(os.Stdin.Fd())
+	if !term.IsTerminal(fd) {
+		return "", errNotTerminal
+	}
+	state, err := term.GetState(fd)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the terminal state: %w", err)
+	}
+	// interrupting the prompt would otherwise leave the terminal without echo
+	interrupts := make(chan os.Signal, 1)
+	signal.Notify(interrupts, os.Interrupt)
+	done := make(chan struct{})
+	defer func() {
+		signal.Stop(interrupts)
+		close(done)
+	}()
+	go func() {
+		select {
+		case <-interrupts:
+			_ = term.Restore(fd, state)
+			fmt.Fprintln(stderr)
+			exit(exitUsage)
+		case <-done:
+		}
+	}()
+
+	fmt.Fprint(stderr, prompt)
+	token, err := term.ReadPassword(fd)
+	// the newline typed was not echoed either
+	fmt.Fprintln(stderr)
+	if err != nil {
+		return "", fmt.Errorf("failed to read the auth token: %w", err)
+	}
+	return strings.TrimSpace(string(token)), nil
+}
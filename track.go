@@ -0,0 +1,193 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+	"gopkg.in/yaml.v3"
+)
+
+// defaultTrackedFile is the tracked packages file read by track report when no file is given
+const defaultTrackedFile = "tracked.yaml"
+
+// TrackedFile is the list of packages a team tracks, e.g.
+//
+//	stale_after: 90d
+//	packages:
+//	  - name: python-3.12
+//	    constraint: ">=3.12.4"
+//	    owner: python-team
+type TrackedFile struct {
+	// StaleAfter is the build age after which the latest version of a package is reported as stale, e.g. 90d or 720h
+	StaleAfter string           `yaml:"stale_after"`
+	Packages   []TrackedPackage `yaml:"packages"`
+}
+
+// TrackedPackage is a tracked package with an optional version constraint, e.g. >=3.12.4 or ~3.12, and owner
+type TrackedPackage struct {
+	Name       string `yaml:"name"`
+	Constraint string `yaml:"constraint"`
+	Owner      string `yaml:"owner"`
+	StaleAfter string `yaml:"stale_after"`
+}
+
+// TrackedPackageStatus is the state of a tracked package in the repositories
+type TrackedPackageStatus struct {
+	Name               string
+	Owner              string `json:",omitempty"`
+	Constraint         string `json:",omitempty"`
+	Found              bool
+	LatestVersion      string     `json:",omitempty"`
+	LatestBuildTime    *time.Time `json:",omitempty"`
+	Repository         string     `json:",omitempty"`
+	ViolatesConstraint bool
+	Stale              bool
+}
+
+// parseAge parses a duration which, in addition to the units of time.ParseDuration, may be a number of days such as 90d
+func parseAge(age string) (time.Duration, error) {
+	if days, found := strings.CutSuffix(age, "d"); found {
+		numberOfDays, err := strconv.Atoi(days)
+		if err != nil {
+			return 0, fmt.Errorf("invalid age %q", age)
+		}
+		return time.Duration(numberOfDays) * 24 * time.Hour, nil
+	}
+	return time.ParseDuration(age)
+}
+
+// readTrackedFile reads and validates a tracked packages file
+func readTrackedFile(path string) (*TrackedFile, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	var trackedFile TrackedFile
+	if err := yaml.Unmarshal(content, &trackedFile); err != nil {
+		return nil, fmt.Errorf("failed to parse %s: %w", path, err)
+	}
+	for _, trackedPackage := range trackedFile.Packages {
+		if trackedPackage.Name == "" {
+			return nil, fmt.Errorf("tracked package without a name in %s", path)
+		}
+		if _, err := trackedPackage.constraint(); err != nil {
+			return nil, err
+		}
+		for _, staleAfter := range []string{trackedFile.StaleAfter, trackedPackage.StaleAfter} {
+			if _, err := parseAge(staleAfter); staleAfter != "" && err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &trackedFile, nil
+}
+
+// constraint returns the version constraint of the tracked package, a constraint without an operator is satisfied by
+// any version
+func (t TrackedPackage) constraint() (Constraint, error) {
+	return parseConstraint(t.Name + t.Constraint)
+}
+
+// trackedStatus returns the state of each tracked package given the latest version of the packages in the repositories
+func trackedStatus(trackedFile *TrackedFile, results *Results, now time.Time) []TrackedPackageStatus {
+	statuses := make([]TrackedPackageStatus, 0, len(trackedFile.Packages))
+	for _, trackedPackage := range trackedFile.Packages {
+		status := TrackedPackageStatus{Name: trackedPackage.Name, Owner: trackedPackage.Owner, Constraint: trackedPackage.Constraint}
+		latestVersion, found := results.LatestVersion[trackedPackage.Name]
+		if found {
+			status.Found = true
+			status.LatestVersion = latestVersion.Version
+			status.LatestBuildTime = &latestVersion.BuildTime
+			status.Repository = latestVersion.Repository
+			constraint, _ := trackedPackage.constraint()
+			status.ViolatesConstraint = !constraint.Satisfied(latestVersion.Version)
+			staleAfter := trackedPackage.StaleAfter
+			if staleAfter == "" {
+				staleAfter = trackedFile.StaleAfter
+			}
+			if staleAfter != "" {
+				maximumAge, _ := parseAge(staleAfter)
+				status.Stale = now.Sub(latestVersion.BuildTime) > maximumAge
+			}
+		}
+		statuses = append(statuses, status)
+	}
+	return statuses
+}
+
+// runTrack reports the current version, constraint violations and staleness of each package of a tracked packages
+// file. It returns exitConstraintViolated when a tracked package is missing, violates its constraint or is stale.
+func runTrack(args []string, repositories []Repository, authToken string, asJSON bool) int {
+	if len(args) == 0 || args[0] != "report" || len(args) > 2 {
+		fmt.Fprintf(stderr, "Usage: %s track report [FILE]\n", os.Args[0])
+		return exitUsage
+	}
+	trackedPath := defaultTrackedFile
+	if len(args) == 2 {
+		trackedPath = args[1]
+	}
+	trackedFile, err := readTrackedFile(trackedPath)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read tracked packages: %v\n", err)
+		return exitUsage
+	}
+
+	trackedPackageNames := make(map[string]struct{})
+	for _, trackedPackage := range trackedFile.Packages {
+		trackedPackageNames[trackedPackage.Name] = struct{}{}
+	}
+	results := NewResults()
+	_, err = forEachPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if _, found := trackedPackageNames[pkg.Name]; found {
+			results.AddPackageMeta(pkg, apkRepository.Name, nil)
+		}
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+
+	statuses := trackedStatus(trackedFile, results, time.Now())
+	drifted := false
+	for _, status := range statuses {
+		if !status.Found || status.ViolatesConstraint || status.Stale {
+			drifted = true
+		}
+	}
+	if asJSON {
+		jsonOutput, err := json.MarshalIndent(statuses, "", "  ")
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return exitUsage
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+	} else {
+		for _, status := range statuses {
+			owner := ""
+			if status.Owner != "" {
+				owner = fmt.Sprintf(" (owner %s)", status.Owner)
+			}
+			if !status.Found {
+				fmt.Fprintf(stdout, "Package %s%s not found in any repository\n", status.Name, owner)
+				continue
+			}
+			problems := ""
+			if status.ViolatesConstraint {
+				problems += fmt.Sprintf(" - VIOLATES constraint %s", status.Constraint)
+			}
+			if status.Stale {
+				problems += " - STALE"
+			}
+			fmt.Fprintf(stdout, "Package %s%s is %s (built %s in %s repository)%s\n", status.Name, owner, status.LatestVersion, formatRelativeTime(*status.LatestBuildTime), status.Repository, problems)
+		}
+	}
+	if drifted {
+		return exitConstraintViolated
+	}
+	return 0
+}
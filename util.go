@@ -0,0 +1,64 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+// verbose is set by the --verbose flag
+var verbose bool
+
+// logVerbose prints to stderr, but only when verbose output is enabled
+func logVerbose(format string, args ...interface{}) {
+	if verbose {
+		fmt.Fprintf(stderr, format+"\n", args...)
+	}
+}
+
+// stringSliceFlag is a flag which can be specified multiple times
+type stringSliceFlag []string
+
+func (s *stringSliceFlag) String() string {
+	return strings.Join(*s, ",")
+}
+
+func (s *stringSliceFlag) Set(value string) error {
+	*s = append(*s, value)
+	return nil
+}
+
+// readInputLines reads all lines from the file at path, or from stdin when path is "-"
+func readInputLines(path string) ([]string, error) {
+	input := io.Reader(os.Stdin)
+	if path != "-" {
+		inputFile, err := os.Open(path)
+		if err != nil {
+			return nil, err
+		}
+		defer inputFile.Close()
+		input = inputFile
+	}
+	var lines []string
+	scanner := bufio.NewScanner(input)
+	for scanner.Scan() {
+		lines = append(lines, scanner.Text())
+	}
+	return lines, scanner.Err()
+}
+
+func removeDuplicates(stringsList []string) []string {
+	seen := make(map[string]struct{})
+	var result []string
+
+	for _, item := range stringsList {
+		if _, found := seen[item]; !found {
+			seen[item] = struct{}{}
+			result = append(result, item)
+		}
+	}
+
+	return result
+}
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// runVerify checks the control section checksum of a local apk file against the checksum recorded for the same package
+// name and version in the repositories. It returns a non zero exit code on a mismatch or when the package is not found.
+func runVerify(args []string, repositories []Repository, authToken string) int {
+	if len(args) != 1 {
+		fmt.Fprintf(stderr, "Usage: %s verify FILE.apk\n", os.Args[0])
+		return exitUsage
+	}
+	apkReader, err := os.Open(args[0])
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to open %s: %v\n", args[0], err)
+		return exitUsage
+	}
+	defer apkReader.Close()
+	apk, err := readAPK(apkReader)
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read %s: %v\n", args[0], err)
+		return exitUsage
+	}
+	fields, _, err := apk.Control()
+	if err != nil {
+		fmt.Fprintf(stderr, "Failed to read .PKGINFO of %s: %v\n", args[0], err)
+		return exitUsage
+	}
+	var packageName, packageVersion string
+	for _, field := range fields {
+		switch field.Key {
+		case "pkgname":
+			packageName = field.Value
+		case "pkgver":
+			packageVersion = field.Value
+		}
+	}
+	if packageName == "" || packageVersion == "" {
+		fmt.Fprintf(stderr, "%s has no pkgname or pkgver in its .PKGINFO\n", args[0])
+		return exitUsage
+	}
+
+	found := false
+	mismatched := false
+	_, err = forEachIndexedPackage(repositories, authToken, func(apkRepository Repository, pkg *repository.Package) {
+		if pkg.Name != packageName || pkg.Version != packageVersion {
+			return
+		}
+		found = true
+		if err := verifyChecksum(apk, pkg); err != nil {
+			mismatched = true
+			fmt.Fprintf(stdout, "%v in %s repository\n", err, apkRepository.Name)
+			return
+		}
+		fmt.Fprintf(stdout, "%s checksum %s matches %s repository\n", pkg.Filename(), apk.ControlChecksum(), apkRepository.Name)
+	})
+	if err != nil {
+		fmt.Fprintf(stderr, "%v\n", err)
+		return exitFetchFailed
+	}
+	if !found {
+		fmt.Fprintf(stderr, "Package %s version %s not found in any repository\n", packageName, packageVersion)
+		return exitNoMatches
+	}
+	if mismatched {
+		return exitConstraintViolated
+	}
+	return 0
+}
@@ -0,0 +1,102 @@
+//go:build js && wasm
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"syscall/js"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// wasmQuery is the JSON request accepted by queryPackages
+type wasmQuery struct {
+	Queries     []string `json:"queries"`
+	Regex       bool     `json:"regex"`
+	AllVersions bool     `json:"allVersions"`
+}
+
+// wasmError is returned by the exported functions, as JSON, when a call fails
+type wasmError struct {
+	Error string `json:"error"`
+}
+
+// wasmIndex is a repository whose APKINDEX has been loaded with loadIndex
+type wasmIndex struct {
+	repositoryName string
+	packages       []*repository.Package
+}
+
+// wasmIndices are the loaded repositories in the order they were loaded
+var wasmIndices []wasmIndex
+
+// wasmErrorJSON returns the error as a JSON string
+func wasmErrorJSON(err error) string {
+	jsonOutput, _ := json.Marshal(wasmError{Error: err.Error()})
+	return string(jsonOutput)
+}
+
+// loadIndex is exported to JavaScript as loadIndex(repositoryName, apkindex) where apkindex is a Uint8Array of the
+// contents of an APKINDEX.tar.gz, e.g. fetched by the page. Loading a repository again replaces its packages. It returns
+// the number of packages loaded, or a JSON error.
+func loadIndex(this js.Value, args []js.Value) any {
+	if len(args) != 2 || args[0].Type() != js.TypeString || args[1].Type() != js.TypeObject {
+		return wasmErrorJSON(fmt.Errorf("usage: loadIndex(repositoryName, Uint8Array)"))
+	}
+	repositoryName := args[0].String()
+	archive := make([]byte, args[1].Get("length").Int())
+	js.CopyBytesToGo(archive, args[1])
+	index := wasmIndex{repositoryName: repositoryName}
+	if err := streamAPKINDEXArchive(bytes.NewReader(archive), repositoryName, func(pkg *repository.Package) {
+		index.packages = append(index.packages, pkg)
+	}); err != nil {
+		return wasmErrorJSON(err)
+	}
+	for i := range wasmIndices {
+		if wasmIndices[i].repositoryName == repositoryName {
+			wasmIndices[i] = index
+			return len(index.packages)
+		}
+	}
+	wasmIndices = append(wasmIndices, index)
+	return len(index.packages)
+}
+
+// queryPackages is exported to JavaScript as queryPackages(request) where request is a JSON wasmQuery, e.g.
+// {"queries":["python-3.12"]}. The matching packages of the loaded repositories are returned as the same JSON as the
+// --json output of the CLI, or a JSON error.
+func queryPackages(this js.Value, args []js.Value) any {
+	if len(args) != 1 || args[0].Type() != js.TypeString {
+		return wasmErrorJSON(fmt.Errorf("usage: queryPackages(JSON request)"))
+	}
+	var query wasmQuery
+	if err := json.Unmarshal([]byte(args[0].String()), &query); err != nil {
+		return wasmErrorJSON(fmt.Errorf("invalid request: %w", err))
+	}
+	if len(query.Queries) == 0 {
+		return wasmErrorJSON(fmt.Errorf("no queries in request"))
+	}
+	matchers := newMatchers(query.Queries, query.Regex)
+	results := NewResults()
+	for _, index := range wasmIndices {
+		for _, pkg := range index.packages {
+			if matchedQueries := matchReference(matchers, pkg); len(matchedQueries) > 0 {
+				results.AddPackageMeta(pkg, index.repositoryName, matchedQueries)
+			}
+		}
+	}
+	var output bytes.Buffer
+	if err := results.Print(&output, PrintOptions{JSON: true, AllVersions: query.AllVersions}); err != nil {
+		return wasmErrorJSON(err)
+	}
+	return output.String()
+}
+
+// main registers the exported functions and keeps the Go runtime alive so JavaScript can call them
+func main() {
+	js.Global().Set("loadIndex", js.FuncOf(loadIndex))
+	js.Global().Set("queryPackages", js.FuncOf(queryPackages))
+	select {}
+}
@@ -0,0 +1,169 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+
+	"gitlab.alpinelinux.org/alpine/go/repository"
+)
+
+// maximumFeedEntries is the number of most recent changes kept for the feed
+const maximumFeedEntries = 200
+
+// WatchOptions are the options of the watch command
+type WatchOptions struct {
+	MatchAsRegex bool
+	JSON         bool
+	// Interval is the time between polls of the repositories
+	Interval time.Duration
+	// ListenAddress is the address the Atom feed of changes is served on, no feed is served when empty
+	ListenAddress string
+	// Notifiers are sent the changes found by each poll
+	Notifiers []notifier
+}
+
+// PackageChange is a new package version found in a repository by watch
+type PackageChange struct {
+	Name       string
+	Version    string
+	Repository string
+	Origin     string
+	BuildTime  time.Time
+	DetectedAt time.Time
+	// pkg is kept so feed subscribers can filter the changes with the same queries as the command line
+	pkg *repository.Package
+}
+
+// changeLog is the most recent changes found by watch, safe for concurrent use by the feed handler
+type changeLog struct {
+	mutex   sync.Mutex
+	changes []PackageChange
+}
+
+func (c *changeLog) add(changes []PackageChange) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.changes = append(c.changes, changes...)
+	if len(c.changes) > maximumFeedEntries {
+		c.changes = c.changes[len(c.changes)-maximumFeedEntries:]
+	}
+}
+
+// recent returns the changes matching the matchers, newest first. All changes are returned when there are no matchers.
+func (c *changeLog) recent(matchers []Matcher) []PackageChange {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	var recent []PackageChange
+	for i := len(c.changes) - 1; i >= 0; i-- {
+		if len(matchers) == 0 || len(matchReference(matchers, c.changes[i].pkg)) > 0 {
+			recent = append(recent, c.changes[i])
+		}
+	}
+	return recent
+}
+
+// watcher polls the repositories and remembers which package versions each repository had on the previous poll
+type watcher struct {
+	repositories []Repository
+	authToken    string
+	matchers     []Matcher
+	// known is the name=version of every package in each repository, a repository is absent until its first
+	// successful poll
+	known map[string]map[string]struct{}
+}
+
+// poll fetches the repositories and returns the package versions which were not in them on the previous poll. Nothing
+// is reported for the first poll of a repository, which only records its packages. A repository which fails to fetch
+// is retried on the next poll.
+func (w *watcher) poll(now time.Time) []PackageChange {
+	var changes []PackageChange
+	for _, apkRepository := range w.repositories {
+		apkIndex, err := fetchAPKINDEX(apkRepository, w.authToken)
+		if err != nil {
+			fmt.Fprintf(stderr, "Failed to load APKINDEX of %s repository: %v\n", apkRepository.Name, err)
+			continue
+		}
+		previous, polled := w.known[apkRepository.Name]
+		current := make(map[string]struct{})
+		for _, pkg := range apkIndex.Packages {
+			if isIgnored(pkg.Name) || (len(w.matchers) > 0 && len(matchReference(w.matchers, pkg)) == 0) {
+				continue
+			}
+			reference := pkg.Name + "=" + pkg.Version
+			current[reference] = struct{}{}
+			if _, found := previous[reference]; polled && !found {
+				changes = append(changes, PackageChange{
+					Name:       pkg.Name,
+					Version:    pkg.Version,
+					Repository: apkRepository.Name,
+					Origin:     pkg.Origin,
+					BuildTime:  pkg.BuildTime,
+					DetectedAt: now,
+					pkg:        pkg,
+				})
+			}
+		}
+		w.known[apkRepository.Name] = current
+		logVerbose("Polled %s repository - %d package versions", apkRepository.Name, len(current))
+	}
+	return changes
+}
+
+// printChange prints a change as text or, for consumption by other tools, as one JSON object per line
+func printChange(change PackageChange, asJSON bool) {
+	if asJSON {
+		jsonOutput, err := json.Marshal(change)
+		if err != nil {
+			fmt.Fprintf(stderr, "Error marshalling JSON: %v\n", err)
+			return
+		}
+		fmt.Fprintln(stdout, string(jsonOutput))
+		return
+	}
+	fmt.Fprintf(stdout, "New version %s of package %s in %s repository (built %s)\n", change.Version, change.Name, change.Repository, formatRelativeTime(change.BuildTime))
+}
+
+// runWatch polls the repositories on an interval and reports new versions of all, or only the matching, packages. When
+// a listen address is specified the changes are also served as an Atom feed.
+func runWatch(args []string, repositories []Repository, authToken string, options WatchOptions) int {
+	if options.Interval <= 0 {
+		fmt.Fprintf(stderr, "Usage: %s watch [--interval DURATION] [--listen ADDRESS] [package names]\n", os.Args[0])
+		return exitUsage
+	}
+	w := &watcher{
+		repositories: repositories,
+		authToken:    authToken,
+		matchers:     newMatchers(args, options.MatchAsRegex),
+		known:        make(map[string]map[string]struct{}),
+	}
+	changes := &changeLog{}
+	if options.ListenAddress != "" {
+		http.Handle("/feed.atom", feedHandler(changes, options.MatchAsRegex))
+		go func() {
+			fmt.Fprintf(stderr, "Serving the Atom feed of package changes on http://%s/feed.atom\n", options.ListenAddress)
+			if err := http.ListenAndServe(options.ListenAddress, nil); err != nil {
+				fmt.Fprintf(stderr, "Failed to serve the Atom feed: %v\n", err)
+				exit(exitUsage)
+			}
+		}()
+	}
+
+	for {
+		newChanges := w.poll(time.Now())
+		for _, change := range newChanges {
+			printChange(change, options.JSON)
+		}
+		changes.add(newChanges)
+		for _, n := range options.Notifiers {
+			// a failed notification is reported but does not stop watching
+			if err := n.Notify(newChanges); err != nil {
+				fmt.Fprintf(stderr, "Failed to send notification: %v\n", err)
+			}
+		}
+		time.Sleep(options.Interval)
+	}
+}
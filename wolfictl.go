@@ -0,0 +1,39 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+)
+
+// wolfictlUpdate is a package with a new upstream version, in the form consumed by the wolfictl update tooling - the
+// versions are melange package versions without the -rN epoch
+type wolfictlUpdate struct {
+	Package        string `json:"package"`
+	CurrentVersion string `json:"current_version"`
+	NewVersion     string `json:"new_version"`
+}
+
+// wolfictlUpdates returns the outdated packages whose upstream version changed. Packages which were only rebuilt, with
+// the same upstream version and a new epoch, need no update.
+func wolfictlUpdates(outdatedPackages []OutdatedPackage) []wolfictlUpdate {
+	updates := []wolfictlUpdate{}
+	for _, outdatedPackage := range outdatedPackages {
+		currentVersion, _ := splitEpoch(outdatedPackage.InstalledVersion)
+		newVersion, _ := splitEpoch(outdatedPackage.LatestVersion)
+		if currentVersion != newVersion {
+			updates = append(updates, wolfictlUpdate{Package: outdatedPackage.Name, CurrentVersion: currentVersion, NewVersion: newVersion})
+		}
+	}
+	return updates
+}
+
+// writeWolfictl writes the updates as a JSON array to w
+func writeWolfictl(w io.Writer, updates []wolfictlUpdate) error {
+	jsonOutput, err := json.MarshalIndent(updates, "", "  ")
+	if err != nil {
+		return fmt.Errorf("error marshalling JSON: %w", err)
+	}
+	_, err = fmt.Fprintln(w, string(jsonOutput))
+	return err
+}